@@ -9,20 +9,26 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/mbiwapa/metric/internal/agent/client"
 	"github.com/mbiwapa/metric/internal/agent/collector"
+	"github.com/mbiwapa/metric/internal/agent/compressor"
 	"github.com/mbiwapa/metric/internal/agent/encoder"
 	"github.com/mbiwapa/metric/internal/agent/sender"
 	"github.com/mbiwapa/metric/internal/agent/source/gopsutilsource"
 	"github.com/mbiwapa/metric/internal/agent/source/memstatssource"
 	config "github.com/mbiwapa/metric/internal/config/client"
+	"github.com/mbiwapa/metric/internal/lib/tlsconfig"
 	"github.com/mbiwapa/metric/internal/logger"
 	"github.com/mbiwapa/metric/internal/storage/memstorage"
 )
@@ -90,10 +96,57 @@ func main() {
 		logger.Error("Failed to create scrambler", zap.Error(errDecoder))
 	}
 
-	// Initialize HTTP client.
-	client, err := client.New(mainCtx, conf.Addr, conf.Key, logger, scrambler)
-	if err != nil {
-		logger.Error("Failed to create HTTP client", zap.Error(err))
+	// Connect over TLS if configured, trusting the configured CAs and presenting the client certificate.
+	var tlsConf *tls.Config
+	if conf.TLS {
+		tlsConf, err = tlsconfig.ClientConfig(conf.TLSCA, conf.TLSCert, conf.TLSKey)
+		if err != nil {
+			panic("TLS initialization error: " + err.Error())
+		}
+	}
+
+	// Initialize the client of the configured transport.
+	var metricSender sender.MetricSender
+	switch conf.Transport {
+	case config.TransportGRPC:
+		var dialOptions []grpc.DialOption
+		if tlsConf != nil {
+			dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(tlsConf)))
+		}
+		grpcClient, errClient := client.NewGRPC(mainCtx, conf.GRPCAddr, conf.Key, logger, scrambler, dialOptions...)
+		if errClient != nil {
+			logger.Error("Failed to create gRPC client", zap.Error(errClient))
+		} else {
+			grpcClient.Token = conf.Token
+			defer grpcClient.Close()
+		}
+		metricSender = grpcClient
+	case config.TransportStream:
+		streamClient, errClient := client.NewStream(mainCtx, conf.Addr, logger)
+		if errClient != nil {
+			logger.Error("Failed to create stream client", zap.Error(errClient))
+		} else {
+			streamClient.Token = conf.Token
+			if tlsConf != nil {
+				streamClient.Client.Transport = &http.Transport{TLSClientConfig: tlsConf}
+			}
+		}
+		metricSender = streamClient
+	default:
+		comp, errCompressor := compressor.NewWithEncoding(logger, conf.Compression, conf.CompressionLevel)
+		if errCompressor != nil {
+			logger.Error("Failed to create compressor, using gzip", zap.Error(errCompressor))
+			comp = compressor.New(logger)
+		}
+		clientOptions := []client.Option{client.WithCompressor(comp), client.WithToken(conf.Token)}
+		if tlsConf != nil {
+			clientOptions = append(clientOptions, client.WithTLSConfig(tlsConf))
+		}
+		httpClient, errClient := client.New(mainCtx, conf.Addr, conf.Key, logger, scrambler, clientOptions...)
+		if errClient != nil {
+			logger.Error("Failed to create HTTP client", zap.Error(errClient))
+		}
+		metricSender = httpClient
 	}
 
 	// Channel to capture errors from collector and sender.
@@ -103,7 +156,7 @@ func main() {
 	collector.Start(mainCtx, storage, conf.PollInterval, logger, errorChanel, memSource, psutilSource)
 
 	// Start the sender routine.
-	sender.Start(mainCtx, storage, client, conf.ReportInterval, logger, conf.WorkerCount, errorChanel)
+	sender.Start(mainCtx, storage, metricSender, conf.ReportInterval, logger, conf.WorkerCount, errorChanel)
 
 	// Goroutine to log errors from the error channel.
 	go func() {
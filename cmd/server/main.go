@@ -7,6 +7,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"net"
 	"net/http"
@@ -19,20 +20,56 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	config "github.com/mbiwapa/metric/internal/config/server"
+	"github.com/mbiwapa/metric/internal/lib/api/metricpb"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+	"github.com/mbiwapa/metric/internal/lib/changefeed"
+	"github.com/mbiwapa/metric/internal/lib/cumulative"
+	"github.com/mbiwapa/metric/internal/lib/grpccodec"
+	"github.com/mbiwapa/metric/internal/lib/history"
+	"github.com/mbiwapa/metric/internal/lib/idempotency"
+	"github.com/mbiwapa/metric/internal/lib/ratelimit"
+	"github.com/mbiwapa/metric/internal/lib/s3"
+	"github.com/mbiwapa/metric/internal/lib/tlsconfig"
 	"github.com/mbiwapa/metric/internal/logger"
 	"github.com/mbiwapa/metric/internal/server/backuper"
+	"github.com/mbiwapa/metric/internal/server/backuper/target"
 	"github.com/mbiwapa/metric/internal/server/decoder"
+	"github.com/mbiwapa/metric/internal/server/graphite"
+	adminHandlers "github.com/mbiwapa/metric/internal/server/handlers/admin"
 	"github.com/mbiwapa/metric/internal/server/handlers/home"
+	"github.com/mbiwapa/metric/internal/server/handlers/influx"
+	"github.com/mbiwapa/metric/internal/server/handlers/metrics"
+	"github.com/mbiwapa/metric/internal/server/handlers/otlp"
 	"github.com/mbiwapa/metric/internal/server/handlers/ping"
+	"github.com/mbiwapa/metric/internal/server/handlers/query"
+	"github.com/mbiwapa/metric/internal/server/handlers/remotewrite"
+	"github.com/mbiwapa/metric/internal/server/handlers/stream"
 	"github.com/mbiwapa/metric/internal/server/handlers/update"
 	"github.com/mbiwapa/metric/internal/server/handlers/updates"
 	"github.com/mbiwapa/metric/internal/server/handlers/value"
+	"github.com/mbiwapa/metric/internal/server/handlers/watch"
+	icAuth "github.com/mbiwapa/metric/internal/server/interceptor/auth"
+	icLogger "github.com/mbiwapa/metric/internal/server/interceptor/logger"
+	icSignature "github.com/mbiwapa/metric/internal/server/interceptor/signature"
+	icTrusted "github.com/mbiwapa/metric/internal/server/interceptor/trusted"
+	"github.com/mbiwapa/metric/internal/server/middleware/admin"
+	"github.com/mbiwapa/metric/internal/server/middleware/auth"
 	mwDecoder "github.com/mbiwapa/metric/internal/server/middleware/decoder"
 	"github.com/mbiwapa/metric/internal/server/middleware/decompressor"
+	mwIdempotency "github.com/mbiwapa/metric/internal/server/middleware/idempotency"
+	"github.com/mbiwapa/metric/internal/server/middleware/limit"
 	mwLogger "github.com/mbiwapa/metric/internal/server/middleware/logger"
+	mwRateLimit "github.com/mbiwapa/metric/internal/server/middleware/ratelimit"
 	signatureCheck "github.com/mbiwapa/metric/internal/server/middleware/signature/check"
+	mwTrusted "github.com/mbiwapa/metric/internal/server/middleware/trusted"
+	"github.com/mbiwapa/metric/internal/server/rpc"
+	metricstorage "github.com/mbiwapa/metric/internal/storage"
 	"github.com/mbiwapa/metric/internal/storage/memstorage"
 	"github.com/mbiwapa/metric/internal/storage/postgre"
 )
@@ -77,8 +114,14 @@ func main() {
 		logger.Error("Can't create decoder", zap.Error(err))
 	}
 
+	// Changes written to the storage are published to the watchers of /watch
+	// and recorded for the rate function of /query.
+	feed := changefeed.New(logger, changefeed.DefaultBuffer)
+	recorder := history.New(history.DefaultRetention)
+	notifier := metricstorage.Notifiers{feed, recorder}
+
 	// Initialize in-memory storage.
-	storage, err := memstorage.New()
+	storage, err := memstorage.New(memstorage.WithNotifier(notifier))
 	if err != nil {
 		logger.Error("Can't create storage", zap.Error(err))
 	}
@@ -86,13 +129,42 @@ func main() {
 	// Initialize PostgreSQL storage if DatabaseDSN is provided.
 	var pgstorage *postgre.Storage
 	if conf.DatabaseDSN != "" {
-		pgstorage, err = postgre.New(conf.DatabaseDSN)
+		pgstorage, err = postgre.New(conf.DatabaseDSN, postgre.WithNotifier(notifier))
 		if err != nil {
 			logger.Error("Can't create postgree storage", zap.Error(err))
 		}
 		defer pgstorage.Close()
 	}
 
+	// Load the backup encryption key from the environment or from a file. A configured key
+	// file that cannot be used stops the server rather than letting it write plaintext backups.
+	backupKey := []byte(conf.BackupKey)
+	if len(backupKey) == 0 && conf.BackupKeyPath != "" {
+		backupKey, err = os.ReadFile(conf.BackupKeyPath)
+		if err != nil {
+			panic("Backup key loading error: " + err.Error())
+		}
+		if len(bytes.TrimSpace(backupKey)) == 0 {
+			panic("Backup key loading error: " + conf.BackupKeyPath + " is empty")
+		}
+	}
+
+	backupOptions := []backuper.Option{
+		backuper.WithCompression(conf.BackupCompression),
+		backuper.WithEncryptionKey(bytes.TrimSpace(backupKey)),
+		backuper.WithFormat(conf.BackupFormat),
+	}
+
+	// Send backups to S3-compatible storage if configured.
+	if conf.BackupTarget == "s3" {
+		s3client, errS3 := s3.New(conf.S3Endpoint, conf.S3Region, conf.S3AccessKey, conf.S3SecretKey)
+		if errS3 != nil {
+			panic("S3 client initialization error: " + errS3.Error())
+		}
+		backupOptions = append(backupOptions,
+			backuper.WithTarget(target.NewS3(s3client, conf.S3Bucket, conf.S3Prefix, conf.S3Keep)))
+	}
+
 	// Initialize the backup mechanism.
 	var backup *backuper.Buckuper
 	if conf.DatabaseDSN == "" {
@@ -100,16 +172,18 @@ func main() {
 			storage,
 			conf.StoreInterval,
 			conf.StoragePath,
-			logger)
+			logger,
+			backupOptions...)
 	} else {
 		backup, err = backuper.New(
 			pgstorage,
 			conf.StoreInterval,
 			conf.StoragePath,
-			logger)
+			logger,
+			backupOptions...)
 	}
 	if err != nil {
-		logger.Error("Can't create saver", zap.Error(err))
+		panic("Backup initialization error: " + err.Error())
 	}
 	defer backup.SaveToFile()
 	if conf.Restore {
@@ -119,57 +193,281 @@ func main() {
 		go backup.Start()
 	}
 
+	// Prepare the type mapping of the Prometheus remote_write endpoint.
+	remoteWriteRules, err := remotewrite.ParseRules(conf.RemoteWriteRules)
+	if err != nil {
+		panic("Remote write rules initialization error: " + err.Error())
+	}
+	remoteWriteMapper := remotewrite.NewMapper(remoteWriteRules)
+	otlpCounters := cumulative.New()
+
+	batchMode, err := updates.ParseMode(conf.BatchMode)
+	if err != nil {
+		panic("Batch mode initialization error: " + err.Error())
+	}
+
+	// Batches retried by the agent after a lost response are answered with the original
+	// result instead of being applied again.
+	dedupe := func(next http.Handler) http.Handler { return next }
+	if conf.IdempotencyKeys > 0 {
+		var idempotencyStore idempotency.Store = idempotency.NewMemory(conf.IdempotencyKeys, idempotency.DefaultTTL)
+		if conf.DatabaseDSN != "" {
+			pgIdempotencyStore, err := postgre.NewIdempotencyStore(context.Background(), pgstorage, conf.IdempotencyKeys, idempotency.DefaultTTL)
+			if err != nil {
+				logger.Error("Can't create idempotency store", zap.Error(err))
+			} else {
+				idempotencyStore = pgIdempotencyStore
+			}
+		}
+		dedupe = mwIdempotency.New(idempotencyStore, logger)
+	}
+
+	// Metrics are written only by the agents of the trusted subnet, if one is configured.
+	var trustedSubnet *net.IPNet
+	trusted := func(next http.Handler) http.Handler { return next }
+	if conf.TrustedSubnet != "" {
+		_, trustedSubnet, err = net.ParseCIDR(conf.TrustedSubnet)
+		if err != nil {
+			panic("Trusted subnet parsing error: " + err.Error())
+		}
+		trusted = mwTrusted.New(trustedSubnet, logger)
+	}
+
+	// API tokens, if configured, are required by the endpoints: the read scope by the ones
+	// returning metrics, write by the ones storing them and admin by the administrative ones.
+	var tokenStore apitoken.Store
+	if conf.TokenDB {
+		if pgstorage == nil {
+			panic("Token store initialization error: API tokens in the database require a database DSN")
+		}
+		tokenStore, err = postgre.NewTokenStore(context.Background(), pgstorage)
+		if err != nil {
+			panic("Token store initialization error: " + err.Error())
+		}
+	} else if conf.TokenFile != "" {
+		tokenStore = apitoken.NewFile(conf.TokenFile)
+	}
+	readAuth := func(next http.Handler) http.Handler { return next }
+	writeAuth := func(next http.Handler) http.Handler { return next }
+	adminAuth := admin.New(conf.AdminToken, logger)
+	if tokenStore != nil {
+		readAuth = auth.New(tokenStore, apitoken.ScopeRead, logger)
+		writeAuth = auth.New(tokenStore, apitoken.ScopeWrite, logger)
+		adminAuth = auth.New(tokenStore, apitoken.ScopeAdmin, logger)
+	}
+
 	// Set up the HTTP router and middleware.
-	router := chi.NewRouter()
-	router.Use(
+	root := chi.NewRouter()
+	root.Use(
 		middleware.RequestID,
 		mwLogger.New(logger),
 		middleware.URLFormat,
-		middleware.Compress(5, "application/json", "text/html"),
-		decompressor.New(logger),
+	)
+
+	// Every client, known by its API token or address, is limited to its own rate, and the
+	// server to the number of requests it handles at once. The long-lived /watch streams do
+	// not take a request slot.
+	if conf.RateLimit > 0 {
+		root.Use(mwRateLimit.New(ratelimit.New(conf.RateLimit, conf.RateBurst), mwRateLimit.Client, logger))
+	}
+	capped := func(next http.Handler) http.Handler { return next }
+	if conf.MaxConcurrent > 0 {
+		capped = mwRateLimit.NewConcurrency(ratelimit.NewSemaphore(conf.MaxConcurrent), logger)
+	}
+	// An /updates/stream request holds its slot for as long as the agent keeps it open, so
+	// streams are limited by -max-streams instead and cannot take the slots of other requests.
+	streamCapped := func(next http.Handler) http.Handler { return next }
+	if conf.MaxStreams > 0 {
+		streamCapped = mwRateLimit.NewConcurrency(ratelimit.NewSemaphore(conf.MaxStreams), logger)
+	}
+
+	maxDecompressed := int64(conf.MaxDecompressedSize)
+
+	// Request bodies are read whole by the router, so they are bounded on the wire and once
+	// decompressed. The streaming endpoint keeps only a batch in memory and is not bounded.
+	router := chi.NewRouter()
+	router.Use(
+		capped,
+		limit.New(int64(conf.MaxBodySize), logger),
+		middleware.Compress(5, "application/json", "text/html", "text/plain", "application/openmetrics-text"),
+		decompressor.New(logger, decompressor.WithMaxSize(maxDecompressed)),
 		signatureCheck.New(conf.Key, logger),
 		mwDecoder.New(decoder),
 	)
 	router.Post("/", undefinedType)
 
+	updatesOptions := []updates.Option{updates.WithMode(batchMode), updates.WithMaxItems(conf.MaxBatchItems)}
+
+	// Every read endpoint renders the formats of one registry.
+	serializers := serializer.NewDefault()
+
+	// reader serves the endpoints that return the stored metrics, writer the ones that change them.
+	reader := router.With(readAuth)
+	writer := router.With(trusted, writeAuth)
+
 	// Set up routes based on the storage type.
 	if conf.DatabaseDSN == "" {
-		router.Post("/update/{type}/{name}/{value}", update.New(logger, storage, backup))
-		router.Post("/update/", update.NewJSON(logger, storage, backup, conf.Key))
-		router.Get("/value/{type}/{name}", value.New(logger, storage, conf.Key))
-		router.Post("/value/", value.NewJSON(logger, storage, conf.Key))
-		router.Get("/", home.New(logger, storage, conf.Key))
-		router.Post("/updates/", updates.NewJSON(logger, storage, backup, conf.Key))
+		writer.Post("/update/{type}/{name}/{value}", update.New(logger, storage, backup))
+		writer.Post("/update/", update.NewJSON(logger, storage, backup, conf.Key))
+		reader.Get("/value/{type}/{name}", value.New(logger, storage, serializers, conf.Key))
+		reader.Post("/value/", value.NewJSON(logger, storage, serializers, conf.Key))
+		reader.Get("/values/", value.NewList(logger, storage, serializers, conf.Key))
+		reader.Post("/values/", value.NewBulkJSON(logger, storage, conf.Key))
+		reader.Get("/", home.New(logger, storage, conf.Key))
+		reader.Get("/metrics", metrics.New(logger, storage, serializers, conf.Key))
+		writer.With(dedupe).Post("/updates/", updates.NewJSON(logger, storage, backup, conf.Key, updatesOptions...))
+		writer.Post("/api/v1/write", remotewrite.New(logger, storage, backup, remoteWriteMapper, remotewrite.WithMaxSize(maxDecompressed)))
+		writer.Post("/write", influx.New(logger, storage, backup))
+		writer.Post("/v1/metrics", otlp.New(logger, storage, backup, otlpCounters))
+		reader.Get("/query", query.New(logger, storage, recorder))
 	} else {
-		router.Post("/{type}/{name}/{value}", update.New(logger, pgstorage, backup))
-		router.Post("/update/", update.NewJSON(logger, pgstorage, backup, conf.Key))
-		router.Get("/value/{type}/{name}", value.New(logger, pgstorage, conf.Key))
-		router.Post("/value/", value.NewJSON(logger, pgstorage, conf.Key))
-		router.Get("/", home.New(logger, pgstorage, conf.Key))
+		writer.Post("/{type}/{name}/{value}", update.New(logger, pgstorage, backup))
+		writer.Post("/update/", update.NewJSON(logger, pgstorage, backup, conf.Key))
+		reader.Get("/value/{type}/{name}", value.New(logger, pgstorage, serializers, conf.Key))
+		reader.Post("/value/", value.NewJSON(logger, pgstorage, serializers, conf.Key))
+		reader.Get("/values/", value.NewList(logger, pgstorage, serializers, conf.Key))
+		reader.Post("/values/", value.NewBulkJSON(logger, pgstorage, conf.Key))
+		reader.Get("/", home.New(logger, pgstorage, conf.Key))
+		reader.Get("/metrics", metrics.New(logger, pgstorage, serializers, conf.Key))
 		router.Get("/ping", ping.New(logger, pgstorage))
-		router.Post("/updates/", updates.NewJSON(logger, pgstorage, backup, conf.Key))
+		writer.With(dedupe).Post("/updates/", updates.NewJSON(logger, pgstorage, backup, conf.Key, updatesOptions...))
+		writer.Post("/api/v1/write", remotewrite.New(logger, pgstorage, backup, remoteWriteMapper, remotewrite.WithMaxSize(maxDecompressed)))
+		writer.Post("/write", influx.New(logger, pgstorage, backup))
+		writer.Post("/v1/metrics", otlp.New(logger, pgstorage, backup, otlpCounters))
+		reader.Get("/query", query.New(logger, pgstorage, recorder))
 	}
 
+	root.With(readAuth).Get("/watch", watch.New(logger, feed))
+
+	// Administrative endpoints are protected by the admin credential, or by API tokens
+	// with the admin scope if they are configured. A restore replaces the stored metrics,
+	// so they are also limited to the trusted subnet like every other write.
+	// A backup is far larger than a metrics request and carries its own compression and
+	// encryption, so the endpoints are served next to the router: an uploaded backup is
+	// bounded by -max-restore-size instead of -max-body-size and is not decompressed,
+	// checked against HashSHA256 or decrypted with the private key on the way.
+	root.Group(func(r chi.Router) {
+		r.Use(capped, limit.New(int64(conf.MaxRestoreSize), logger), trusted, adminAuth)
+		r.Get("/admin/backup", adminHandlers.NewBackup(logger, backup, conf.Key))
+		r.Get("/admin/backups", adminHandlers.NewGenerations(logger, backup))
+		r.Post("/admin/restore", adminHandlers.NewRestore(logger, backup))
+	})
+
+	// Streaming ingestion acknowledges batches while the request is still being read, so it is
+	// served next to the router whose middlewares buffer whole bodies. Encrypted bodies cannot
+	// be streamed, the endpoint is disabled when a private key is configured.
+	if conf.PrivateKeyPath == "" {
+		var streamStorage stream.Updater = storage
+		if conf.DatabaseDSN != "" {
+			streamStorage = pgstorage
+		}
+		root.Group(func(r chi.Router) {
+			r.Use(streamCapped, trusted, writeAuth, decompressor.New(logger))
+			r.Post("/updates/stream", stream.New(logger, streamStorage, backup))
+		})
+	} else {
+		logger.Warn("Streaming ingestion is disabled because request encryption is enabled")
+	}
+	root.Mount("/", router)
+
 	// Create and start the HTTP server.
 	srv := &http.Server{
 		Addr:    conf.Addr,
-		Handler: router,
+		Handler: root,
 		BaseContext: func(_ net.Listener) context.Context {
 			return mainCtx
 		},
 	}
 
+	// HTTPS, and gRPC over TLS, are served if a certificate is configured. The certificate and
+	// the CA bundle of client certificates are reloaded when their files change.
+	var certs *tlsconfig.Reloader
+	if conf.TLSCert != "" {
+		certs, err = tlsconfig.NewReloader(conf.TLSCert, conf.TLSKey, conf.TLSClientCA, logger)
+		if err != nil {
+			panic("TLS initialization error: " + err.Error())
+		}
+		srv.TLSConfig = certs.ServerConfig()
+	} else if conf.TLSClientCA != "" {
+		logger.Warn("Client certificates are not verified because TLS is disabled")
+	}
+
 	go func() {
 		g, gCtx := errgroup.WithContext(mainCtx)
 		g.Go(func() error {
-			logger.Info("Starting server: ", zap.String("Addr", srv.Addr))
+			logger.Info("Starting server: ", zap.String("Addr", srv.Addr), zap.Bool("tls", certs != nil))
+			if certs != nil {
+				return srv.ListenAndServeTLS("", "")
+			}
 			return srv.ListenAndServe()
 		})
+		if certs != nil {
+			g.Go(func() error {
+				certs.Watch(gCtx, tlsconfig.DefaultInterval)
+				return nil
+			})
+		}
 		g.Go(func() error {
 			<-gCtx.Done()
 			logger.Info("Shutdown server!")
 			return srv.Shutdown(context.Background())
 		})
+		if conf.GraphiteAddr != "" {
+			var graphiteStorage graphite.Updater = storage
+			if conf.DatabaseDSN != "" {
+				graphiteStorage = pgstorage
+			}
+			graphiteServer := graphite.New(conf.GraphiteAddr, graphiteStorage, backup, logger,
+				graphite.WithUDP(conf.GraphiteUDP),
+				graphite.WithMaxConnections(conf.GraphiteMaxConns),
+				graphite.WithTrustedSubnet(trustedSubnet))
+			g.Go(func() error {
+				return graphiteServer.ListenAndServe(gCtx)
+			})
+		}
+		if conf.GRPCAddr != "" {
+			var rpcStorage rpc.Storage = storage
+			if conf.DatabaseDSN != "" {
+				rpcStorage = pgstorage
+			}
+			interceptors := []grpc.UnaryServerInterceptor{
+				icLogger.New(logger),
+				icSignature.New(conf.Key, logger),
+			}
+			if tokenStore != nil {
+				interceptors = append(interceptors, icAuth.New(tokenStore, map[string]apitoken.Scope{
+					metricpb.Metrics_UpdateBatch_FullMethodName: apitoken.ScopeWrite,
+					metricpb.Metrics_GetMetric_FullMethodName:   apitoken.ScopeRead,
+					metricpb.Metrics_List_FullMethodName:        apitoken.ScopeRead,
+				}, logger))
+			}
+			if trustedSubnet != nil {
+				interceptors = append(interceptors, icTrusted.New(trustedSubnet, logger, metricpb.Metrics_UpdateBatch_FullMethodName))
+			}
+			grpcOptions := []grpc.ServerOption{
+				grpc.ForceServerCodec(grpccodec.NewDecrypting(decoder)),
+				grpc.ChainUnaryInterceptor(interceptors...),
+			}
+			if certs != nil {
+				grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(certs.ServerConfig())))
+			}
+			grpcServer := grpc.NewServer(grpcOptions...)
+			metricpb.RegisterMetricsServer(grpcServer, rpc.New(logger, rpcStorage, backup))
+			g.Go(func() error {
+				ln, errListen := net.Listen("tcp", conf.GRPCAddr)
+				if errListen != nil {
+					return errListen
+				}
+				logger.Info("Starting gRPC server: ", zap.String("Addr", conf.GRPCAddr))
+				return grpcServer.Serve(ln)
+			})
+			g.Go(func() error {
+				<-gCtx.Done()
+				logger.Info("Shutdown gRPC server!")
+				grpcServer.GracefulStop()
+				return nil
+			})
+		}
 		if err := g.Wait(); err != nil {
 			logger.Info("Exit reason: ", zap.Error(err))
 		}
@@ -183,7 +481,7 @@ func main() {
 	logger.Info("Good bye!")
 }
 
-// undefinedType handles requests with undefined metric types by returning a 400 Bad Request status.
+// undefinedType handles requests with undefined metric types by returning a 400 Bad Request problem.
 func undefinedType(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusBadRequest)
+	problem.Write(w, r, http.StatusBadRequest, problem.CodeUnknownType, "metric type must be gauge or counter")
 }
@@ -0,0 +1,137 @@
+// Package main is the command managing the API tokens of the server. It creates, lists and
+// revokes the tokens kept in the token file or in the database the server reads them from.
+//
+// Usage:
+//
+//	token [-token-file path | -d dsn] create -name name -scopes read,write
+//	token [-token-file path | -d dsn] list
+//	token [-token-file path | -d dsn] revoke id
+//
+// The store may also be set with the TOKEN_FILE and DATABASE_DSN environment variables.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+	"github.com/mbiwapa/metric/internal/storage/postgre"
+)
+
+// main parses the flags, opens the token store and runs the command.
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "token:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command line against the configured store and writes the result to out.
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	tokenFile := fs.String("token-file", os.Getenv("TOKEN_FILE"), "Путь к файлу с API-токенами")
+	dsn := fs.String("d", os.Getenv("DATABASE_DSN"), "DSN строка для соединения с базой данных, если токены хранятся в ней")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: token [-token-file path | -d dsn] create -name name -scopes read,write | list | revoke id")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var store apitoken.Store
+	switch {
+	case *dsn != "":
+		storage, err := postgre.New(*dsn)
+		if err != nil {
+			return err
+		}
+		defer storage.Close()
+		store, err = postgre.NewTokenStore(ctx, storage)
+		if err != nil {
+			return err
+		}
+	case *tokenFile != "":
+		store = apitoken.NewFile(*tokenFile)
+	default:
+		return errors.New("either -token-file or -d must be set")
+	}
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "create":
+		return create(ctx, store, cmdArgs, out)
+	case "list":
+		return list(ctx, store, out)
+	case "revoke":
+		if len(cmdArgs) != 1 {
+			return errors.New("usage: token revoke id")
+		}
+		if err := store.Revoke(ctx, cmdArgs[0]); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "revoked", cmdArgs[0])
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// create issues a token and prints it; the token cannot be shown again.
+func create(ctx context.Context, store apitoken.Store, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	name := fs.String("name", "", "Описание владельца токена")
+	scopes := fs.String("scopes", string(apitoken.ScopeRead), "Права токена через запятую: read, write, admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	parsed, err := apitoken.ParseScopes(*scopes)
+	if err != nil {
+		return err
+	}
+	secret, token, err := apitoken.Generate(*name, parsed)
+	if err != nil {
+		return err
+	}
+	if err = store.Add(ctx, token); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "id:     %s\n", token.ID)
+	fmt.Fprintf(out, "token:  %s\n", secret)
+	fmt.Fprintln(out, "The token is shown only once, keep it safe.")
+	return nil
+}
+
+// list prints the stored tokens, without their hashes.
+func list(ctx context.Context, store apitoken.Store, out io.Writer) error {
+	tokens, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED")
+	for _, t := range tokens {
+		scopes := make([]string, len(t.Scopes))
+		for i, s := range t.Scopes {
+			scopes[i] = string(s)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(scopes, ","), t.CreatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
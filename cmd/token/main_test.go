@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"-token-file", path, "create", "-name", "agent", "-scopes", "read,write"}, &out))
+	id := regexp.MustCompile(`id:\s+(\S+)`).FindStringSubmatch(out.String())
+	secret := regexp.MustCompile(`token:\s+(\S+)`).FindStringSubmatch(out.String())
+	require.Len(t, id, 2)
+	require.Len(t, secret, 2)
+
+	token, err := apitoken.NewFile(path).Lookup(context.Background(), apitoken.Hash(secret[1]))
+	require.NoError(t, err, "the printed token is the stored one")
+	require.Equal(t, []apitoken.Scope{apitoken.ScopeRead, apitoken.ScopeWrite}, token.Scopes)
+
+	out.Reset()
+	require.NoError(t, run([]string{"-token-file", path, "list"}, &out))
+	require.Contains(t, out.String(), id[1])
+	require.NotContains(t, out.String(), token.Hash)
+
+	require.NoError(t, run([]string{"-token-file", path, "revoke", id[1]}, &out))
+	require.ErrorIs(t, run([]string{"-token-file", path, "revoke", id[1]}, &out), apitoken.ErrNotFound)
+
+	require.Error(t, run([]string{"-token-file", path, "create", "-scopes", "root"}, &out))
+	require.Error(t, run([]string{"-token-file", path, "rotate"}, &out))
+	require.Error(t, run([]string{"list"}, &out), "a store is required")
+}
@@ -6,10 +6,17 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/Rican7/retry"
 	"github.com/Rican7/retry/strategy"
@@ -17,6 +24,8 @@ import (
 
 	"github.com/mbiwapa/metric/internal/agent/compressor"
 	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/contentcoding"
+	"github.com/mbiwapa/metric/internal/lib/realip"
 	"github.com/mbiwapa/metric/internal/lib/retry/backoff"
 	"github.com/mbiwapa/metric/internal/lib/signature"
 )
@@ -30,6 +39,8 @@ type Client struct {
 	Compressor *compressor.Compressor // Compressor is used to compress the data before sending.
 	Key        string                 // Key is used for generating SHA256 hashes for request validation.
 	Encoder    Encoder                // Encoder is used to encrypt the data before sending.
+	RealIP     string                 // RealIP is the address of the agent, reported in the X-Real-IP header.
+	Token      string                 // Token is the API token sent as a bearer credential, if set.
 	context    context.Context        // context is the context for the client.
 }
 
@@ -37,6 +48,31 @@ type Encoder interface {
 	EncryptData(data []byte) ([]byte, error)
 }
 
+// Option configures optional behaviour of the Client.
+type Option func(*Client)
+
+// WithCompressor sets the compressor of the request bodies, gzip at its fastest level by default.
+func WithCompressor(c *compressor.Compressor) Option {
+	return func(client *Client) {
+		client.Compressor = c
+	}
+}
+
+// WithToken sets the API token sent in the Authorization header.
+func WithToken(token string) Option {
+	return func(client *Client) {
+		client.Token = token
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the connections to an https server:
+// the CAs of the server certificate and the client certificate for mutual TLS.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(client *Client) {
+		client.Client.Transport = &http.Transport{TLSClientConfig: cfg}
+	}
+}
+
 // New initializes and returns a new instance of the Client struct.
 // It sets up the URL, HTTP client, logger, compressor, and key for the client.
 //
@@ -44,11 +80,13 @@ type Encoder interface {
 //   - url: The base URL for the client to send requests to.
 //   - key: The key used for generating SHA256 hashes for request validation.
 //   - logger: A zap.Logger instance for logging purposes.
+//   - encoder: encrypts the request bodies.
+//   - opts: optional settings.
 //
 // Returns:
 //   - *Client: A pointer to the newly created Client instance.
 //   - error: An error if there is an issue during the creation of the Client instance.
-func New(ctx context.Context, url string, key string, logger *zap.Logger, encoder Encoder) (*Client, error) {
+func New(ctx context.Context, url string, key string, logger *zap.Logger, encoder Encoder, opts ...Option) (*Client, error) {
 	var client Client
 	client.URL = url
 	client.Client = &http.Client{
@@ -58,12 +96,17 @@ func New(ctx context.Context, url string, key string, logger *zap.Logger, encode
 	client.Compressor = compressor.New(logger)
 	client.Key = key
 	client.Encoder = encoder
+	client.RealIP = outboundIP(hostPort(url), logger)
 	client.context = ctx
+	for _, opt := range opts {
+		opt(&client)
+	}
 
 	return &client, nil
 }
 
 // Send sends metrics to the server. It takes gauge and counter metrics, processes them, compresses the data, and sends it to the server with retry logic.
+// A 429 or 503 response is retried after the wait given in its Retry-After header, other failures after the backoff.Backoff delays.
 //
 // Parameters:
 //   - gauges: A slice of slices containing gauge metrics, where each inner slice contains the metric ID and value as strings.
@@ -108,36 +151,39 @@ func (c *Client) Send(gauges [][]string, counters [][]string) error {
 		logger.Error("Cant encoding request", zap.Error(errJSON))
 		return errJSON
 	}
-	compressedData, errCompress := c.Compressor.GetCompressedData(data)
-	if errCompress != nil {
-		logger.Error("Cant initializing compressed reader", zap.Error(errCompress))
-		return errCompress
+	encryptedData, encoding, errEncode := c.encode(data)
+	if errEncode != nil {
+		return errEncode
 	}
 
-	// Read the compressed data into a byte slice
-	compressedBytes, errBytes := io.ReadAll(compressedData)
-	if errBytes != nil {
-		logger.Error("Cant read compressed data", zap.Error(errBytes))
-		return errBytes
+	// Every attempt carries the same key, so that the server applies the batch once
+	// even if a response is lost and the batch is sent again.
+	idempotencyKey, errKey := newIdempotencyKey()
+	if errKey != nil {
+		logger.Error("Cant generate idempotency key", zap.Error(errKey))
+		return errKey
 	}
 
-	// Encrypt the compressed data
-	encryptedData, errEncrypt := c.Encoder.EncryptData(compressedBytes)
-	if errEncrypt != nil {
-		logger.Error("Cant encrypt data", zap.Error(errEncrypt))
-		return errEncrypt
-	}
+	// retryAfter is the wait requested by the server for the next attempt.
+	var retryAfter time.Duration
 
-	action := func(attempt uint) error {
+	send := func(attempt uint) (*http.Response, error) {
 		req, err := http.NewRequest("POST", c.URL+"/updates/", bytes.NewReader(encryptedData))
 		if err != nil {
 			logger.Error("Cant create request", zap.Error(err), zap.Uint("attempt", attempt))
-			return err
+			return nil, err
 		}
 		req.Close = true // Close the connection after sending the request
 
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Content-Encoding", encoding)
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+		if c.RealIP != "" {
+			req.Header.Set(realip.Header, c.RealIP)
+		}
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
 
 		if c.Key != "" {
 			hashStr := signature.GetHash(c.Key, string(data), logger)
@@ -148,11 +194,42 @@ func (c *Client) Send(gauges [][]string, counters [][]string) error {
 		resp, err := c.Client.Do(req)
 		if err != nil {
 			logger.Error("Cant send metric", zap.Error(err), zap.Uint("attempt", attempt))
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	action := func(attempt uint) error {
+		resp, err := send(attempt)
+		if err != nil {
 			return err
 		}
 
+		// A server that does not support the encoding is sent the batch again at once in gzip.
+		if resp.StatusCode == http.StatusUnsupportedMediaType && encoding != contentcoding.Gzip {
+			resp.Body.Close()
+			logger.Warn("Server rejects the encoding", zap.String("encoding", encoding), zap.String("accepted", resp.Header.Get("Accept-Encoding")))
+			c.Compressor.Fallback()
+			encryptedData, encoding, err = c.encode(data)
+			if err != nil {
+				return err
+			}
+			resp, err = send(attempt)
+			if err != nil {
+				return err
+			}
+		}
+
 		if resp != nil {
 			defer resp.Body.Close()
+			// A throttled or overloaded server is asked again, after the time it requests.
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if wait, ok := backoff.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					retryAfter = wait
+				}
+				logger.Warn("Server asks to retry later", zap.String("status", resp.Status), zap.Duration("retry_after", retryAfter), zap.Uint("attempt", attempt))
+				return fmt.Errorf("%s: %s", op, resp.Status)
+			}
 			if resp.StatusCode != http.StatusOK {
 				logger.Error("No response", zap.String("error", resp.Status), zap.Uint("attempt", attempt))
 			}
@@ -165,7 +242,7 @@ func (c *Client) Send(gauges [][]string, counters [][]string) error {
 	err := retry.Retry(
 		action,
 		strategy.Limit(4),
-		strategy.Backoff(backoff.Backoff()))
+		backoff.RetryAfter(backoff.Backoff(), &retryAfter))
 
 	if err != nil {
 		logger.Error("Cant send metric affter 4 attemt", zap.Error(err))
@@ -174,6 +251,69 @@ func (c *Client) Send(gauges [][]string, counters [][]string) error {
 	return nil
 }
 
+// encode compresses the body with the current encoding of the compressor and encrypts it.
+// Returns the request body and its content coding.
+func (c *Client) encode(data []byte) ([]byte, string, error) {
+	logger := c.Logger.With(zap.String("op", "http-client.send.encode"))
+
+	compressedData, encoding, errCompress := c.Compressor.GetCompressedData(data)
+	if errCompress != nil {
+		logger.Error("Cant initializing compressed reader", zap.Error(errCompress))
+		return nil, "", errCompress
+	}
+
+	// Read the compressed data into a byte slice
+	compressedBytes, errBytes := io.ReadAll(compressedData)
+	if errBytes != nil {
+		logger.Error("Cant read compressed data", zap.Error(errBytes))
+		return nil, "", errBytes
+	}
+
+	// Encrypt the compressed data
+	encryptedData, errEncrypt := c.Encoder.EncryptData(compressedBytes)
+	if errEncrypt != nil {
+		logger.Error("Cant encrypt data", zap.Error(errEncrypt))
+		return nil, "", errEncrypt
+	}
+	return encryptedData, encoding, nil
+}
+
+// outboundIP returns the address of the interface the agent reaches the server through,
+// or an empty string if it cannot be found.
+func outboundIP(addr string, logger *zap.Logger) string {
+	ip, err := realip.Outbound(addr)
+	if err != nil {
+		logger.Warn("Cant find the outbound address", zap.String("server", addr), zap.Error(err))
+		return ""
+	}
+	return ip.String()
+}
+
+// hostPort returns the host and port of the server URL, with the default port of the scheme if it has none.
+func hostPort(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// newIdempotencyKey returns a random key identifying a batch.
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // Worker sends metrics to the server in a streaming mode. It continuously reads jobs from the provided channel and sends the metrics using the Send method.
 //
 // Parameters:
@@ -2,13 +2,21 @@ package client
 
 import (
 	"context"
+	"encoding/pem"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/mbiwapa/metric/internal/agent/compressor"
 	"github.com/mbiwapa/metric/internal/agent/encoder"
+	"github.com/mbiwapa/metric/internal/lib/contentcoding"
+	"github.com/mbiwapa/metric/internal/lib/tlsconfig"
 	"github.com/mbiwapa/metric/internal/logger"
 )
 
@@ -70,3 +78,170 @@ func TestClient_Send(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_SendIdempotencyKey(t *testing.T) {
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			// The batch is received but the response is lost.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	log, err := logger.New("info")
+	require.NoError(t, err)
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+
+	c, err := New(context.Background(), srv.URL, "", log, enc)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Send([][]string{{"test", "0.567"}}, [][]string{{"PollCount", "1"}}))
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "1"}}))
+
+	require.Len(t, keys, 3)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1], "a retried batch keeps its key")
+	require.NotEqual(t, keys[1], keys[2], "every batch has its own key")
+}
+
+func TestClient_SendRetryAfter(t *testing.T) {
+	var sent []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, time.Now())
+		if len(sent) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	log, err := logger.New("info")
+	require.NoError(t, err)
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+
+	c, err := New(context.Background(), srv.URL, "", log, enc)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "1"}}))
+
+	require.Len(t, sent, 2, "a throttled batch is sent again")
+	require.GreaterOrEqual(t, sent[1].Sub(sent[0]), time.Second)
+}
+
+func TestClient_SendEncodingFallback(t *testing.T) {
+	var encodings []string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		encodings = append(encodings, encoding)
+		if encoding != contentcoding.Gzip {
+			w.Header().Set("Accept-Encoding", "gzip")
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		dr, err := contentcoding.NewReader(encoding, r.Body)
+		require.NoError(t, err)
+		body, err = io.ReadAll(dr)
+		require.NoError(t, err)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	log, err := logger.New("info")
+	require.NoError(t, err)
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+	comp, err := compressor.NewWithEncoding(log, contentcoding.Zstd, 3)
+	require.NoError(t, err)
+
+	c, err := New(context.Background(), srv.URL, "", log, enc, WithCompressor(comp))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "1"}}))
+	require.Equal(t, []string{contentcoding.Zstd, contentcoding.Gzip}, encodings, "a rejected encoding is resent in gzip at once")
+	require.JSONEq(t, `[{"id":"PollCount","type":"counter","delta":1}]`, string(body))
+
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "1"}}))
+	require.Equal(t, contentcoding.Gzip, encodings[2], "the next batches keep the fallback")
+}
+
+func TestClient_SendRealIP(t *testing.T) {
+	var realIP string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realIP = r.Header.Get("X-Real-IP")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	log, err := logger.New("info")
+	require.NoError(t, err)
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+
+	c, err := New(context.Background(), srv.URL, "", log, enc)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "1"}}))
+	require.Equal(t, "127.0.0.1", realIP, "the agent reports the interface it reaches the server through")
+}
+
+func TestHostPort(t *testing.T) {
+	require.Equal(t, "localhost:8080", hostPort("http://localhost:8080"))
+	require.Equal(t, "example.com:80", hostPort("http://example.com"))
+	require.Equal(t, "example.com:443", hostPort("https://example.com"))
+	require.Equal(t, "[::1]:8080", hostPort("http://[::1]:8080"))
+}
+
+func TestClient_SendToken(t *testing.T) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	log, err := logger.New("info")
+	require.NoError(t, err)
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+
+	c, err := New(context.Background(), srv.URL, "", log, enc, WithToken("mt_secret"))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "1"}}))
+	require.Equal(t, "Bearer mt_secret", authorization)
+}
+
+func TestClient_SendTLS(t *testing.T) {
+	var received int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+	cfg, err := tlsconfig.ClientConfig(caFile, "", "")
+	require.NoError(t, err)
+
+	log, err := logger.New("info")
+	require.NoError(t, err)
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+
+	c, err := New(context.Background(), srv.URL, "", log, enc, WithTLSConfig(cfg))
+	require.NoError(t, err)
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "1"}}))
+	require.Equal(t, 1, received)
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Rican7/retry"
+	"github.com/Rican7/retry/strategy"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/mbiwapa/metric/internal/lib/api/metricpb"
+	"github.com/mbiwapa/metric/internal/lib/grpccodec"
+	"github.com/mbiwapa/metric/internal/lib/realip"
+	"github.com/mbiwapa/metric/internal/lib/retry/backoff"
+	"github.com/mbiwapa/metric/internal/lib/signature"
+)
+
+// GRPCClient sends metrics to the gRPC Metrics service of the server.
+// It is an alternative transport to Client and is used by the sender the same way.
+type GRPCClient struct {
+	Conn    *grpc.ClientConn // Conn is the connection to the server.
+	Client  pb.MetricsClient // Client is the generated Metrics service client.
+	Logger  *zap.Logger      // Logger is used for logging purposes.
+	Key     string           // Key is used for generating SHA256 hashes for request validation.
+	RealIP  string           // RealIP is the address of the agent, reported in the x-real-ip metadata.
+	Token   string           // Token is the API token sent in the authorization metadata, if set.
+	context context.Context  // context is the context for the client.
+}
+
+// NewGRPC initializes and returns a new instance of the GRPCClient struct.
+// Requests are encrypted with the encoder by the codec of the connection.
+//
+// Parameters:
+//   - ctx: the context that stops the workers.
+//   - addr: the address of the gRPC server, e.g. "localhost:3200".
+//   - key: The key used for generating SHA256 hashes for request validation.
+//   - logger: A zap.Logger instance for logging purposes.
+//   - encoder: encrypts the requests.
+//   - opts: additional dial options, e.g. TLS transport credentials replacing the plaintext ones.
+//
+// Returns:
+//   - *GRPCClient: A pointer to the newly created GRPCClient instance.
+//   - error: An error if the connection cannot be set up.
+func NewGRPC(ctx context.Context, addr string, key string, logger *zap.Logger, encoder Encoder, opts ...grpc.DialOption) (*GRPCClient, error) {
+	const op = "grpc-client.NewGRPC"
+
+	dialOptions := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpccodec.NewEncrypting(encoder))),
+	}, opts...)
+	conn, err := grpc.NewClient(addr, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &GRPCClient{
+		Conn:    conn,
+		Client:  pb.NewMetricsClient(conn),
+		Logger:  logger,
+		Key:     key,
+		RealIP:  outboundIP(addr, logger),
+		context: ctx,
+	}, nil
+}
+
+// Send sends metrics to the server with a single UpdateBatch call and retries it on transient errors.
+//
+// Parameters:
+//   - gauges: A slice of slices containing gauge metrics, where each inner slice contains the metric ID and value as strings.
+//   - counters: A slice of slices containing counter metrics, where each inner slice contains the metric ID and value as strings.
+//
+// Returns:
+//   - error: An error if the metrics cannot be converted or the server rejects them.
+func (c *GRPCClient) Send(gauges [][]string, counters [][]string) error {
+	const op = "grpc-client.send.Send"
+	logger := c.Logger.With(zap.String("op", op))
+
+	req := &pb.UpdateBatchRequest{
+		Metrics: make([]*pb.Metric, 0, len(gauges)+len(counters)),
+	}
+	for _, gauge := range gauges {
+		val, err := strconv.ParseFloat(gauge[1], 64)
+		if err != nil {
+			logger.Error("Cant parse gauge metric", zap.Error(err))
+			return err
+		}
+		req.Metrics = append(req.Metrics, &pb.Metric{Id: gauge[0], Type: pb.MType_MTYPE_GAUGE, Value: val})
+	}
+	for _, counter := range counters {
+		val, err := strconv.ParseInt(counter[1], 10, 64)
+		if err != nil {
+			logger.Error("Cant parse counter metric", zap.Error(err))
+			return err
+		}
+		req.Metrics = append(req.Metrics, &pb.Metric{Id: counter[0], Type: pb.MType_MTYPE_COUNTER, Delta: val})
+	}
+
+	ctx := c.context
+	if c.RealIP != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, realip.MetadataKey, c.RealIP)
+	}
+	if c.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.Token)
+	}
+	if c.Key != "" {
+		hashStr, err := signature.GetMessageHash(c.Key, req, logger)
+		if err != nil {
+			logger.Error("Cant sign request", zap.Error(err))
+			return err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, signature.MetadataKey, hashStr)
+	}
+
+	var sendErr error
+	action := func(attempt uint) error {
+		callCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		_, sendErr = c.Client.UpdateBatch(callCtx, req)
+		if sendErr == nil {
+			logger.Info("Request completed successfully!", zap.Int("metrics", len(req.Metrics)))
+			return nil
+		}
+		logger.Error("Cant send metric", zap.Error(sendErr), zap.Uint("attempt", attempt))
+		switch status.Code(sendErr) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+			return sendErr
+		default:
+			// The server rejected the batch, repeating it will not help.
+			return nil
+		}
+	}
+
+	err := retry.Retry(
+		action,
+		strategy.Limit(4),
+		strategy.Backoff(backoff.Backoff()))
+	if err == nil {
+		err = sendErr
+	}
+	if err != nil {
+		logger.Error("Cant send metric affter 4 attemt", zap.Error(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Worker sends metrics to the server. It continuously reads jobs from the provided channel and sends the metrics using the Send method.
+//
+// Parameters:
+//   - jobs: A channel that provides jobs, where each job is a map containing gauge and counter metrics.
+//   - errorChanel: A channel to send errors if there is an issue during the processing or sending of the metrics.
+func (c *GRPCClient) Worker(jobs <-chan map[string][][]string, errorChanel chan<- error) {
+	for j := range jobs {
+		select {
+		case <-c.context.Done():
+			return
+		default:
+			err := c.Send(j["gauge"], j["counter"])
+			if err != nil {
+				errorChanel <- err
+			}
+		}
+	}
+}
+
+// Close closes the connection to the server.
+func (c *GRPCClient) Close() error {
+	return c.Conn.Close()
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/mbiwapa/metric/internal/agent/encoder"
+	pb "github.com/mbiwapa/metric/internal/lib/api/metricpb"
+	"github.com/mbiwapa/metric/internal/lib/signature"
+)
+
+// metricsServer records the UpdateBatch requests it receives.
+type metricsServer struct {
+	pb.UnimplementedMetricsServer
+	requests []*pb.UpdateBatchRequest
+	hashes   []string
+	err      error
+}
+
+func (s *metricsServer) UpdateBatch(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+	s.requests = append(s.requests, req)
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.hashes = append(s.hashes, md.Get(signature.MetadataKey)...)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pb.UpdateBatchResponse{Accepted: int32(len(req.GetMetrics()))}, nil
+}
+
+func startMetricsServer(t *testing.T, srv *metricsServer) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	pb.RegisterMetricsServer(s, srv)
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+	return ln.Addr().String()
+}
+
+func TestGRPCClient_Send(t *testing.T) {
+	srv := &metricsServer{}
+	addr := startMetricsServer(t, srv)
+
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+	c, err := NewGRPC(context.Background(), addr, "secret", zap.NewNop(), enc)
+	require.NoError(t, err)
+	defer c.Close()
+
+	err = c.Send([][]string{{"Alloc", "0.567"}}, [][]string{{"PollCount", "2"}})
+	require.NoError(t, err)
+
+	require.Len(t, srv.requests, 1)
+	metrics := srv.requests[0].GetMetrics()
+	require.Len(t, metrics, 2)
+	require.Equal(t, "Alloc", metrics[0].GetId())
+	require.Equal(t, pb.MType_MTYPE_GAUGE, metrics[0].GetType())
+	require.Equal(t, 0.567, metrics[0].GetValue())
+	require.Equal(t, "PollCount", metrics[1].GetId())
+	require.Equal(t, int64(2), metrics[1].GetDelta())
+
+	hash, err := signature.GetMessageHash("secret", srv.requests[0], zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, []string{hash}, srv.hashes)
+}
+
+func TestGRPCClient_SendRejected(t *testing.T) {
+	srv := &metricsServer{err: status.Error(codes.InvalidArgument, "bad metric")}
+	addr := startMetricsServer(t, srv)
+
+	enc, err := encoder.New("")
+	require.NoError(t, err)
+	c, err := NewGRPC(context.Background(), addr, "", zap.NewNop(), enc)
+	require.NoError(t, err)
+	defer c.Close()
+
+	err = c.Send([][]string{{"Alloc", "1"}}, nil)
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	// Rejected batches are not retried.
+	require.Len(t, srv.requests, 1)
+	require.Empty(t, srv.hashes)
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Rican7/retry"
+	"github.com/Rican7/retry/strategy"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/realip"
+	"github.com/mbiwapa/metric/internal/lib/retry/backoff"
+)
+
+// errStreamClosed is returned by writes to a stream that the server has ended.
+var errStreamClosed = errors.New("metric stream closed")
+
+// streamAck is an acknowledgement line of the streaming endpoint.
+type streamAck struct {
+	Accepted int    `json:"accepted"`
+	Total    int64  `json:"total"`
+	Line     int64  `json:"line"`
+	Error    string `json:"error"`
+	Done     bool   `json:"done"`
+	Rejected []struct {
+		Name   string `json:"name"`
+		Reason string `json:"reason"`
+	} `json:"rejected"`
+}
+
+// StreamClient sends metrics as newline-delimited JSON over a single long-lived request
+// to the /updates/stream endpoint. The stream is opened on the first Send, reopened when the
+// server ends it and closed gracefully when the context of the client is done.
+type StreamClient struct {
+	URL     string          // URL is the base URL of the server.
+	Client  *http.Client    // Client is the HTTP client used for the stream.
+	Logger  *zap.Logger     // Logger is used for logging purposes.
+	RealIP  string          // RealIP is the address of the agent, reported in the X-Real-IP header.
+	Token   string          // Token is the API token sent as a bearer credential, if set.
+	context context.Context // context is the context for the client.
+
+	mu    sync.Mutex
+	body  *io.PipeWriter // body is the request body of the open stream, nil if there is none.
+	done  chan struct{}  // done is closed when the open stream ends.
+	lines *atomic.Int64  // lines is the number of lines written to the open stream.
+}
+
+// NewStream initializes and returns a new instance of the StreamClient struct.
+//
+// Parameters:
+//   - ctx: the context that closes the stream.
+//   - url: The base URL of the server.
+//   - logger: A zap.Logger instance for logging purposes.
+//
+// Returns:
+//   - *StreamClient: A pointer to the newly created StreamClient instance.
+//   - error: An error if there is an issue during the creation of the StreamClient instance.
+func NewStream(ctx context.Context, url string, logger *zap.Logger) (*StreamClient, error) {
+	c := &StreamClient{
+		URL:     url,
+		Client:  &http.Client{Transport: &http.Transport{}},
+		Logger:  logger,
+		RealIP:  outboundIP(hostPort(url), logger),
+		context: ctx,
+	}
+	go func() {
+		<-ctx.Done()
+		c.Close()
+	}()
+	return c, nil
+}
+
+// Send writes the metrics to the open stream, opening a new one if needed.
+// The server acknowledges them asynchronously; acknowledgements are logged.
+//
+// Parameters:
+//   - gauges: A slice of slices containing gauge metrics, where each inner slice contains the metric ID and value as strings.
+//   - counters: A slice of slices containing counter metrics, where each inner slice contains the metric ID and value as strings.
+//
+// Returns:
+//   - error: An error if the metrics cannot be converted or written after 4 attempts.
+func (c *StreamClient) Send(gauges [][]string, counters [][]string) error {
+	const op = "stream-client.send.Send"
+	logger := c.Logger.With(zap.String("op", op))
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, gauge := range gauges {
+		val, err := strconv.ParseFloat(gauge[1], 64)
+		if err != nil {
+			logger.Error("Cant parse gauge metric", zap.Error(err))
+			return err
+		}
+		if err = enc.Encode(format.Metric{ID: gauge[0], MType: format.Gauge, Value: &val}); err != nil {
+			return err
+		}
+	}
+	for _, counter := range counters {
+		val, err := strconv.ParseInt(counter[1], 10, 64)
+		if err != nil {
+			logger.Error("Cant parse counter metric", zap.Error(err))
+			return err
+		}
+		if err = enc.Encode(format.Metric{ID: counter[0], MType: format.Counter, Delta: &val}); err != nil {
+			return err
+		}
+	}
+	n := int64(len(gauges) + len(counters))
+
+	action := func(attempt uint) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.context.Err() != nil {
+			return nil
+		}
+		if c.body == nil {
+			c.open()
+		}
+		if _, err := c.body.Write(buf.Bytes()); err != nil {
+			logger.Error("Cant write to stream", zap.Error(err), zap.Uint("attempt", attempt))
+			c.body = nil
+			return err
+		}
+		c.lines.Add(n)
+		return nil
+	}
+
+	err := retry.Retry(
+		action,
+		strategy.Limit(4),
+		strategy.Backoff(backoff.Backoff()))
+	if err != nil {
+		logger.Error("Cant send metric affter 4 attemt", zap.Error(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// open starts a new stream. It must be called with mu held.
+func (c *StreamClient) open() {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	lines := new(atomic.Int64)
+	c.body, c.done, c.lines = pw, done, lines
+
+	go func() {
+		defer close(done)
+		err := c.run(pr, lines)
+		if err == nil {
+			err = errStreamClosed
+		}
+		pr.CloseWithError(err)
+	}()
+}
+
+// run performs the streaming request and logs the acknowledgements until the response ends.
+// lines is the number of lines written to this stream.
+func (c *StreamClient) run(body io.Reader, lines *atomic.Int64) error {
+	const op = "stream-client.run"
+	logger := c.Logger.With(zap.String("op", op))
+
+	// The request is not bound to the client context: Close ends it gracefully
+	// so the server acknowledges the last batch.
+	req, err := http.NewRequest(http.MethodPost, c.URL+"/updates/stream", body)
+	if err != nil {
+		logger.Error("Cant create request", zap.Error(err))
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if c.RealIP != "" {
+		req.Header.Set(realip.Header, c.RealIP)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		logger.Error("Cant open stream", zap.Error(err))
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("Stream rejected", zap.String("error", resp.Status))
+		return fmt.Errorf("%s: %w: %s", op, errStreamClosed, resp.Status)
+	}
+	logger.Info("Stream opened")
+
+	var acked, rejected int64
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		var ack streamAck
+		if err = json.Unmarshal(sc.Bytes(), &ack); err != nil {
+			logger.Error("Cant decode acknowledgement", zap.Error(err))
+			continue
+		}
+		acked = ack.Total
+		rejected += int64(len(ack.Rejected))
+		for _, r := range ack.Rejected {
+			logger.Error("Metric rejected", zap.String("name", r.Name), zap.String("reason", r.Reason))
+		}
+		if ack.Error != "" {
+			logger.Error("Server failed to store metrics", zap.String("error", ack.Error), zap.Int64("line", ack.Line))
+		}
+		logger.Info("Metrics acknowledged", zap.Int("accepted", ack.Accepted), zap.Int64("total", ack.Total))
+	}
+
+	if lost := lines.Load() - acked - rejected; lost > 0 {
+		logger.Warn("Stream ended with unacknowledged metrics", zap.Int64("count", lost))
+	}
+	return sc.Err()
+}
+
+// Worker sends metrics to the server. It continuously reads jobs from the provided channel and sends the metrics using the Send method.
+//
+// Parameters:
+//   - jobs: A channel that provides jobs, where each job is a map containing gauge and counter metrics.
+//   - errorChanel: A channel to send errors if there is an issue during the processing or sending of the metrics.
+func (c *StreamClient) Worker(jobs <-chan map[string][][]string, errorChanel chan<- error) {
+	for j := range jobs {
+		select {
+		case <-c.context.Done():
+			return
+		default:
+			err := c.Send(j["gauge"], j["counter"])
+			if err != nil {
+				errorChanel <- err
+			}
+		}
+	}
+}
+
+// Close ends the open stream and waits for the last acknowledgement.
+func (c *StreamClient) Close() error {
+	c.mu.Lock()
+	body, done := c.body, c.done
+	c.body = nil
+	c.mu.Unlock()
+
+	if body == nil {
+		return nil
+	}
+	err := body.Close()
+	<-done
+	return err
+}
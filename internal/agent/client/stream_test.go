@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+// streamServer acknowledges every received line and counts the opened streams.
+type streamServer struct {
+	mu      sync.Mutex
+	metrics []format.Metric
+	streams int
+}
+
+func (s *streamServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	s.mu.Lock()
+	s.streams++
+	s.mu.Unlock()
+
+	var total int64
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		var m format.Metric
+		if json.Unmarshal(sc.Bytes(), &m) != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.metrics = append(s.metrics, m)
+		s.mu.Unlock()
+		total++
+		_ = json.NewEncoder(w).Encode(streamAck{Accepted: 1, Total: total})
+		_ = rc.Flush()
+	}
+}
+
+func TestStreamClient_Send(t *testing.T) {
+	srv := &streamServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewStream(ctx, ts.URL, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, c.Send([][]string{{"Alloc", "0.5"}}, [][]string{{"PollCount", "1"}}))
+	require.NoError(t, c.Send(nil, [][]string{{"PollCount", "2"}}))
+	require.NoError(t, c.Close())
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	// Both reports travel over a single request.
+	require.Equal(t, 1, srv.streams)
+	require.Len(t, srv.metrics, 3)
+	require.Equal(t, format.Gauge, srv.metrics[0].MType)
+	require.Equal(t, 0.5, *srv.metrics[0].Value)
+	require.Equal(t, int64(2), *srv.metrics[2].Delta)
+}
+
+func TestStreamClient_Reopen(t *testing.T) {
+	srv := &streamServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	c, err := NewStream(context.Background(), ts.URL, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, c.Send([][]string{{"Alloc", "1"}}, nil))
+	// A closed stream is not reused, the next Send opens a new one.
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Send([][]string{{"Alloc", "2"}}, nil))
+	require.NoError(t, c.Close())
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	require.Equal(t, 2, srv.streams)
+	require.Len(t, srv.metrics, 2)
+}
@@ -1,50 +1,99 @@
-// Package compressor provides a compressor that compresses data using the gzip algorithm.
+// Package compressor provides a compressor that compresses data with one of the content codings
+// of the contentcoding package, gzip by default.
 // It also provides a function to create a new Compressor instance and a function to get a compressed reader for the given data.
 package compressor
 
 import (
 	"bytes"
-	"compress/gzip"
 	"io"
+	"sync"
 
 	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/contentcoding"
 )
 
 // Compressor struct for compressing data
 type Compressor struct {
 	Logger *zap.Logger
+
+	mu       sync.RWMutex
+	encoding string // encoding is the content coding, as sent in the Content-Encoding header.
+	level    int    // level is the compression level of the encoding.
 }
 
-// New creates a new Compressor instance
+// New creates a new Compressor instance compressing with gzip at its fastest level.
 // log: A zap.Logger instance used for logging errors and information.
 // Returns a pointer to a new Compressor instance.
 func New(log *zap.Logger) *Compressor {
 	return &Compressor{
-		Logger: log,
+		Logger:   log,
+		encoding: contentcoding.Gzip,
 	}
 }
 
-// GetCompressedData returns a compressed reader for the given data.
-// data: A byte slice containing the data to be compressed.
-// Returns an io.Reader containing the compressed data and an error if any occurred during compression.
-func (compressor Compressor) GetCompressedData(data []byte) (io.Reader, error) {
-	b := new(bytes.Buffer)
-	w, err := gzip.NewWriterLevel(b, gzip.BestSpeed)
+// NewWithEncoding creates a new Compressor instance with the given content coding.
+//
+// Parameters:
+//   - log: A zap.Logger instance used for logging errors and information.
+//   - encoding: gzip, deflate, zstd or br.
+//   - level: the compression level in the native range of the encoding, 0 for its fastest level.
+//
+// Returns:
+//   - *Compressor: the compressor.
+//   - error: if the encoding or the level is not supported.
+func NewWithEncoding(log *zap.Logger, encoding string, level int) (*Compressor, error) {
+	coding, err := contentcoding.Parse(encoding)
 	if err != nil {
-		compressor.Logger.Error("error init gzip writer", zap.Error(err))
 		return nil, err
 	}
-	_, err = w.Write(data)
-	if err != nil {
-		compressor.Logger.Error("error compressing data", zap.Error(err))
-		return nil, err
+	if coding == contentcoding.Identity {
+		return nil, contentcoding.ErrUnsupported
 	}
-	err = w.Close()
-	w.Reset(b)
-	if err != nil {
-		compressor.Logger.Error("error closing writer", zap.Error(err))
+	// Check the level once, so that compression cannot fail on it later.
+	if _, err = contentcoding.Encode(coding, level, nil); err != nil {
 		return nil, err
 	}
+	return &Compressor{
+		Logger:   log,
+		encoding: coding,
+		level:    level,
+	}, nil
+}
 
-	return b, nil
+// Encoding returns the content coding of the compressed data.
+func (compressor *Compressor) Encoding() string {
+	compressor.mu.RLock()
+	defer compressor.mu.RUnlock()
+	return compressor.encoding
+}
+
+// Fallback switches the compressor to gzip, which every server supports, after the
+// server has rejected the configured encoding.
+// Returns false if the compressor already uses gzip.
+func (compressor *Compressor) Fallback() bool {
+	compressor.mu.Lock()
+	defer compressor.mu.Unlock()
+	if compressor.encoding == contentcoding.Gzip {
+		return false
+	}
+	compressor.Logger.Warn("falling back to gzip", zap.String("encoding", compressor.encoding))
+	compressor.encoding, compressor.level = contentcoding.Gzip, 0
+	return true
+}
+
+// GetCompressedData returns a compressed reader for the given data.
+// data: A byte slice containing the data to be compressed.
+// Returns the compressed data, the content coding used to compress it and an error if any occurred during compression.
+func (compressor *Compressor) GetCompressedData(data []byte) (io.Reader, string, error) {
+	compressor.mu.RLock()
+	encoding, level := compressor.encoding, compressor.level
+	compressor.mu.RUnlock()
+
+	compressed, err := contentcoding.Encode(encoding, level, data)
+	if err != nil {
+		compressor.Logger.Error("error compressing data", zap.String("encoding", encoding), zap.Error(err))
+		return nil, "", err
+	}
+	return bytes.NewReader(compressed), encoding, nil
 }
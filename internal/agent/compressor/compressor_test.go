@@ -1,19 +1,134 @@
 package compressor
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/contentcoding"
 )
 
+func TestNewWithEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		level    int
+		want     string
+		wantErr  bool
+	}{
+		{name: "gzip", encoding: "gzip", want: contentcoding.Gzip},
+		{name: "alias", encoding: "X-Gzip", want: contentcoding.Gzip},
+		{name: "zstd level", encoding: "zstd", level: 3, want: contentcoding.Zstd},
+		{name: "brotli", encoding: "br", level: 5, want: contentcoding.Brotli},
+		{name: "deflate", encoding: "deflate", level: 9, want: contentcoding.Deflate},
+		{name: "identity", encoding: "identity", wantErr: true},
+		{name: "unknown", encoding: "lz4", wantErr: true},
+		{name: "invalid level", encoding: "gzip", level: 42, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewWithEncoding(zap.NewNop(), tt.encoding, tt.level)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, c.Encoding())
+		})
+	}
+}
+
+func TestCompressor_Fallback(t *testing.T) {
+	c, err := NewWithEncoding(zap.NewNop(), contentcoding.Zstd, 0)
+	require.NoError(t, err)
+
+	require.True(t, c.Fallback())
+	require.Equal(t, contentcoding.Gzip, c.Encoding())
+	require.False(t, c.Fallback())
+
+	data := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+	r, encoding, err := c.GetCompressedData(data)
+	require.NoError(t, err)
+	require.Equal(t, contentcoding.Gzip, encoding)
+
+	dr, err := contentcoding.NewReader(encoding, r)
+	require.NoError(t, err)
+	got, err := io.ReadAll(dr)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+}
+
+// agentPayload returns a batch as sent by the agent: the runtime gauges, PollCount and RandomValue.
+func agentPayload(b *testing.B) []byte {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	gauges := map[string]float64{
+		"Alloc": float64(stats.Alloc), "BuckHashSys": float64(stats.BuckHashSys), "Frees": float64(stats.Frees),
+		"GCCPUFraction": stats.GCCPUFraction, "GCSys": float64(stats.GCSys), "HeapAlloc": float64(stats.HeapAlloc),
+		"HeapIdle": float64(stats.HeapIdle), "HeapInuse": float64(stats.HeapInuse), "HeapObjects": float64(stats.HeapObjects),
+		"HeapReleased": float64(stats.HeapReleased), "HeapSys": float64(stats.HeapSys), "LastGC": float64(stats.LastGC),
+		"Lookups": float64(stats.Lookups), "MCacheInuse": float64(stats.MCacheInuse), "MCacheSys": float64(stats.MCacheSys),
+		"MSpanInuse": float64(stats.MSpanInuse), "MSpanSys": float64(stats.MSpanSys), "Mallocs": float64(stats.Mallocs),
+		"NextGC": float64(stats.NextGC), "NumForcedGC": float64(stats.NumForcedGC), "NumGC": float64(stats.NumGC),
+		"OtherSys": float64(stats.OtherSys), "PauseTotalNs": float64(stats.PauseTotalNs), "StackInuse": float64(stats.StackInuse),
+		"StackSys": float64(stats.StackSys), "Sys": float64(stats.Sys), "TotalAlloc": float64(stats.TotalAlloc),
+		"RandomValue": 0.6046602879796196, "TotalMemory": 16e9, "FreeMemory": 9.3e9,
+	}
+	var metrics []format.Metric
+	for id, value := range gauges {
+		value := value
+		metrics = append(metrics, format.Metric{ID: id, MType: format.Gauge, Value: &value})
+	}
+	for i := 0; i < 8; i++ {
+		value := float64(i * 7)
+		metrics = append(metrics, format.Metric{ID: "CPUutilization" + strconv.Itoa(i+1), MType: format.Gauge, Value: &value})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].ID < metrics[j].ID })
+	delta := int64(5)
+	metrics = append(metrics, format.Metric{ID: "PollCount", MType: format.Counter, Delta: &delta})
+
+	data, err := json.Marshal(metrics)
+	require.NoError(b, err)
+	return data
+}
+
 func BenchmarkGetCompressedData(b *testing.B) {
-	compressor := New(zap.NewNop())
-	data := []byte("This is a test data for compression.")
+	data := agentPayload(b)
+
+	benchmarks := []struct {
+		encoding string
+		levels   []int
+	}{
+		{encoding: contentcoding.Gzip, levels: []int{1, 6, 9}},
+		{encoding: contentcoding.Deflate, levels: []int{1, 6, 9}},
+		{encoding: contentcoding.Zstd, levels: []int{1, 3, 19}},
+		{encoding: contentcoding.Brotli, levels: []int{0, 5, 11}},
+	}
+	for _, bm := range benchmarks {
+		for _, level := range bm.levels {
+			compressor, err := NewWithEncoding(zap.NewNop(), bm.encoding, level)
+			require.NoError(b, err)
 
-	// Benchmark for GetCompressedData function
-	b.Run("GetCompressedData", func(b *testing.B) {
-		for i := 0; i < b.N; i++ {
-			_, _ = compressor.GetCompressedData(data)
+			b.Run(fmt.Sprintf("%s/%d", bm.encoding, level), func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+				var size int64
+				for i := 0; i < b.N; i++ {
+					r, _, err := compressor.GetCompressedData(data)
+					if err != nil {
+						b.Fatal(err)
+					}
+					size, _ = io.Copy(io.Discard, r)
+				}
+				b.ReportMetric(float64(len(data))/float64(size), "ratio")
+			})
 		}
-	})
+	}
 }
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+
+	"github.com/mbiwapa/metric/internal/lib/contentcoding"
 )
 
 // Config holds all the server configurations.
@@ -18,8 +20,27 @@ type Config struct {
 	Key            string `json:"key,omitempty"`             // Key for hash computation
 	WorkerCount    int    `json:"worker_count,omitempty"`    // Number of threads for sending metrics
 	PublicKeyPath  string `json:"crypto_key,omitempty"`      // Path to the public key file
+	Transport      string `json:"transport,omitempty"`       // Transport used to send metrics: http, grpc or stream
+	GRPCAddr       string `json:"grpc_address,omitempty"`    // gRPC server address and port, used with the grpc transport
+
+	Compression      string `json:"compression,omitempty"`       // Content coding of the request bodies: gzip, deflate, zstd or br
+	CompressionLevel int    `json:"compression_level,omitempty"` // Compression level of the coding, 0 selects its fastest level
+
+	Token string `json:"token,omitempty"` // API token with the write scope, sent when the server requires tokens
+
+	TLS     bool   `json:"tls,omitempty"`      // Whether to connect to the server over TLS; set by any of the TLS files
+	TLSCA   string `json:"tls_ca,omitempty"`   // Path to the PEM CA bundle of the server certificate, the system roots if empty
+	TLSCert string `json:"tls_cert,omitempty"` // Path to the PEM client certificate for mutual TLS
+	TLSKey  string `json:"tls_key,omitempty"`  // Path to the PEM private key of the client certificate
 }
 
+// Transports supported by the agent.
+const (
+	TransportHTTP   = "http"
+	TransportGRPC   = "grpc"
+	TransportStream = "stream"
+)
+
 // MustLoadConfig loads the configuration from command-line flags, environment variables, and a JSON file.
 // It returns a pointer to a Config struct and an error if any invalid values are encountered.
 //
@@ -34,6 +55,15 @@ func MustLoadConfig() (*Config, error) {
 	var err error
 	var WorkerCount int
 	var PublicKeyPath string
+	var Transport string
+	var GRPCAddr string
+	var Compression string
+	var CompressionLevel int
+	var Token string
+	var TLS bool
+	var TLSCA string
+	var TLSCert string
+	var TLSKey string
 	var configFilePath string
 
 	// Define command-line flags
@@ -43,6 +73,15 @@ func MustLoadConfig() (*Config, error) {
 	flag.StringVar(&Key, "k", "", "Ключ для вычисления хеша")
 	flag.IntVar(&WorkerCount, "l", 1, "Количество потоков для отправки метрик (по умолчанию 1 поток)")
 	flag.StringVar(&PublicKeyPath, "crypto-key", "", "Путь к файлу с публичным ключом")
+	flag.StringVar(&Transport, "transport", TransportHTTP, "Протокол отправки метрик: http, grpc или stream")
+	flag.StringVar(&GRPCAddr, "grpc-address", "localhost:3200", "Адрес и порт gRPC-сервера по сбору метрик")
+	flag.StringVar(&Compression, "compression", contentcoding.Gzip, "Сжатие тела запроса: gzip, deflate, zstd или br")
+	flag.IntVar(&CompressionLevel, "compression-level", 0, "Уровень сжатия (0 - самый быстрый уровень алгоритма)")
+	flag.StringVar(&Token, "token", "", "API-токен с правом записи метрик")
+	flag.BoolVar(&TLS, "tls", false, "Подключаться к серверу по HTTPS")
+	flag.StringVar(&TLSCA, "tls-ca", "", "Путь к файлу CA для проверки сертификата сервера (по умолчанию системные)")
+	flag.StringVar(&TLSCert, "tls-cert", "", "Путь к файлу клиентского сертификата для mTLS")
+	flag.StringVar(&TLSKey, "tls-key", "", "Путь к файлу закрытого ключа клиентского сертификата")
 	flag.StringVar(&configFilePath, "c", "", "Путь к файлу конфигурации")
 	flag.StringVar(&configFilePath, "config", "", "Путь к файлу конфигурации")
 	flag.Parse()
@@ -54,6 +93,15 @@ func MustLoadConfig() (*Config, error) {
 	envWorkerCount := os.Getenv("RATE_LIMIT")
 	envKey := os.Getenv("KEY")
 	envPublicKeyPath := os.Getenv("CRYPTO_KEY")
+	envTransport := os.Getenv("TRANSPORT")
+	envGRPCAddr := os.Getenv("GRPC_ADDRESS")
+	envCompression := os.Getenv("COMPRESSION")
+	envCompressionLevel := os.Getenv("COMPRESSION_LEVEL")
+	envToken := os.Getenv("TOKEN")
+	envTLS := os.Getenv("TLS")
+	envTLSCA := os.Getenv("TLS_CA")
+	envTLSCert := os.Getenv("TLS_CERT")
+	envTLSKey := os.Getenv("TLS_KEY")
 	envConfigFilePath := os.Getenv("CONFIG")
 
 	if envAddr != "" {
@@ -83,6 +131,39 @@ func MustLoadConfig() (*Config, error) {
 	if envPublicKeyPath != "" {
 		PublicKeyPath = envPublicKeyPath
 	}
+	if envTransport != "" {
+		Transport = envTransport
+	}
+	if envGRPCAddr != "" {
+		GRPCAddr = envGRPCAddr
+	}
+	if envCompression != "" {
+		Compression = envCompression
+	}
+	if envCompressionLevel != "" {
+		CompressionLevel, err = strconv.Atoi(envCompressionLevel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid env value: %s. %s", envCompressionLevel, err)
+		}
+	}
+	if envToken != "" {
+		Token = envToken
+	}
+	if envTLS != "" {
+		TLS, err = strconv.ParseBool(envTLS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid env value: %s. %s", envTLS, err)
+		}
+	}
+	if envTLSCA != "" {
+		TLSCA = envTLSCA
+	}
+	if envTLSCert != "" {
+		TLSCert = envTLSCert
+	}
+	if envTLSKey != "" {
+		TLSKey = envTLSKey
+	}
 	if envConfigFilePath != "" {
 		configFilePath = envConfigFilePath
 	}
@@ -107,6 +188,33 @@ func MustLoadConfig() (*Config, error) {
 				if PublicKeyPath == "" && fileConfig.PublicKeyPath != "" {
 					PublicKeyPath = fileConfig.PublicKeyPath
 				}
+				if Transport == TransportHTTP && fileConfig.Transport != "" {
+					Transport = fileConfig.Transport
+				}
+				if GRPCAddr == "localhost:3200" && fileConfig.GRPCAddr != "" {
+					GRPCAddr = fileConfig.GRPCAddr
+				}
+				if Compression == contentcoding.Gzip && fileConfig.Compression != "" {
+					Compression = fileConfig.Compression
+				}
+				if CompressionLevel == 0 {
+					CompressionLevel = fileConfig.CompressionLevel
+				}
+				if Token == "" {
+					Token = fileConfig.Token
+				}
+				if !TLS {
+					TLS = fileConfig.TLS
+				}
+				if TLSCA == "" {
+					TLSCA = fileConfig.TLSCA
+				}
+				if TLSCert == "" {
+					TLSCert = fileConfig.TLSCert
+				}
+				if TLSKey == "" {
+					TLSKey = fileConfig.TLSKey
+				}
 			}
 			fmt.Println(errDecode)
 			_ = file.Close()
@@ -115,18 +223,57 @@ func MustLoadConfig() (*Config, error) {
 		}
 	}
 
+	if Transport != TransportHTTP && Transport != TransportGRPC && Transport != TransportStream {
+		return nil, fmt.Errorf("invalid transport: %s", Transport)
+	}
+	if Transport == TransportStream && PublicKeyPath != "" {
+		return nil, fmt.Errorf("transport %s does not support encryption", Transport)
+	}
+
+	coding, err := contentcoding.Parse(Compression)
+	if err != nil || coding == contentcoding.Identity {
+		return nil, fmt.Errorf("invalid compression: %s", Compression)
+	}
+	Compression = coding
+
+	if (TLSCert == "") != (TLSKey == "") {
+		return nil, fmt.Errorf("tls-cert and tls-key must be set together")
+	}
+	for _, path := range []string{TLSCA, TLSCert, TLSKey} {
+		if _, err = os.Stat(path); os.IsNotExist(err) && path != "" {
+			return nil, fmt.Errorf("file not found: %s. %s", path, err)
+		}
+	}
+	TLS = TLS || TLSCA != "" || TLSCert != ""
+	scheme := "http://"
+	if TLS {
+		scheme = "https://"
+	}
+
 	if _, err = os.Stat(PublicKeyPath); os.IsNotExist(err) && PublicKeyPath != "" {
 		return nil, fmt.Errorf("file not found: %s. %s", PublicKeyPath, err)
 	}
 
 	// Create the configuration struct
 	cfg := &Config{
-		Addr:           "http://" + Addr,
+		Addr:           scheme + Addr,
 		PollInterval:   PollInterval,
 		ReportInterval: ReportInterval,
 		Key:            Key,
 		WorkerCount:    WorkerCount,
 		PublicKeyPath:  PublicKeyPath,
+		Transport:      Transport,
+		GRPCAddr:       GRPCAddr,
+
+		Compression:      Compression,
+		CompressionLevel: CompressionLevel,
+
+		Token: Token,
+
+		TLS:     TLS,
+		TLSCA:   TLSCA,
+		TLSCert: TLSCert,
+		TLSKey:  TLSKey,
 	}
 
 	return cfg, nil
@@ -18,6 +18,53 @@ type Config struct {
 	DatabaseDSN    string `json:"database_dsn,omitempty"`   // DatabaseDSN DSN string for connecting to the database
 	Key            string // Key for hash computation
 	PrivateKeyPath string `json:"crypto_key,omitempty"` // PrivateKeyPath to the private key file
+
+	BackupCompression string `json:"backup_compression,omitempty"` // BackupCompression Compression of the backup file: none, gzip or zstd
+	BackupKeyPath     string `json:"backup_key_file,omitempty"`    // BackupKeyPath Path to the file with the backup encryption key
+	BackupFormat      string `json:"backup_format,omitempty"`      // BackupFormat Format of the backup file: json, jsonl, gob or csv
+	BackupKey         string // BackupKey Backup encryption key, takes precedence over BackupKeyPath
+
+	BackupTarget string `json:"backup_target,omitempty"` // BackupTarget Destination of backups: file or s3
+	S3Endpoint   string `json:"s3_endpoint,omitempty"`   // S3Endpoint Base URL of the S3-compatible storage
+	S3Region     string `json:"s3_region,omitempty"`     // S3Region Region used to sign S3 requests
+	S3Bucket     string `json:"s3_bucket,omitempty"`     // S3Bucket Bucket for backups
+	S3Prefix     string `json:"s3_prefix,omitempty"`     // S3Prefix Key prefix of backup objects
+	S3Keep       int    `json:"s3_keep,omitempty"`       // S3Keep Number of backup generations to retain, 0 keeps all
+	S3AccessKey  string // S3AccessKey Access key ID for the S3-compatible storage
+	S3SecretKey  string // S3SecretKey Secret access key for the S3-compatible storage
+
+	AdminToken string // AdminToken Credential for the administrative endpoints, disabled if empty
+
+	RemoteWriteRules string `json:"remote_write_rules,omitempty"` // RemoteWriteRules Rules mapping remote_write series to gauge or counter, regexp=type separated by ;
+
+	GraphiteAddr     string `json:"graphite_address,omitempty"`         // GraphiteAddr Listen address of the Graphite plaintext listener, disabled if empty
+	GraphiteUDP      bool   `json:"graphite_udp,omitempty"`             // GraphiteUDP Whether the Graphite listener also accepts UDP datagrams
+	GraphiteMaxConns int    `json:"graphite_max_connections,omitempty"` // GraphiteMaxConns Limit of simultaneous Graphite TCP connections, 0 means no limit
+
+	GRPCAddr string `json:"grpc_address,omitempty"` // GRPCAddr Listen address of the gRPC server, disabled if empty
+
+	BatchMode string `json:"batch_mode,omitempty"` // BatchMode Semantics of batch updates: atomic or best-effort
+
+	IdempotencyKeys int `json:"idempotency_keys,omitempty"` // IdempotencyKeys Number of remembered Idempotency-Key values, 0 disables deduplication
+
+	RateLimit     float64 `json:"rate_limit,omitempty"`              // RateLimit Requests per second allowed to a client, 0 means no limit
+	RateBurst     int     `json:"rate_burst,omitempty"`              // RateBurst Number of requests a client may send at once above the rate
+	MaxConcurrent int     `json:"max_concurrent_requests,omitempty"` // MaxConcurrent Limit of requests handled at once, 0 means no limit
+	MaxStreams    int     `json:"max_streams,omitempty"`             // MaxStreams Limit of open /updates/stream requests, counted apart from MaxConcurrent, 0 means no limit
+
+	MaxBodySize         int `json:"max_body_size,omitempty"`         // MaxBodySize Limit of the request body in bytes as sent, 0 means no limit
+	MaxDecompressedSize int `json:"max_decompressed_size,omitempty"` // MaxDecompressedSize Limit of the decompressed request body in bytes, 0 means no limit
+	MaxBatchItems       int `json:"max_batch_items,omitempty"`       // MaxBatchItems Limit of metrics in a batch of /updates/, 0 means no limit
+	MaxRestoreSize      int `json:"max_restore_size,omitempty"`      // MaxRestoreSize Limit of the backup uploaded to /admin/restore in bytes, applied instead of MaxBodySize, 0 means no limit
+
+	TrustedSubnet string `json:"trusted_subnet,omitempty"` // TrustedSubnet Trusted subnet (CIDR) of the agents allowed to write metrics, disabled if empty
+
+	TokenFile string `json:"token_file,omitempty"` // TokenFile Path to the file of the API tokens, token authentication is disabled if empty and TokenDB is false
+	TokenDB   bool   `json:"token_db,omitempty"`   // TokenDB Keep the API tokens in the database instead of TokenFile
+
+	TLSCert     string `json:"tls_cert,omitempty"`      // TLSCert Path to the PEM certificate of the server, TLS is disabled if empty
+	TLSKey      string `json:"tls_key,omitempty"`       // TLSKey Path to the PEM private key of TLSCert
+	TLSClientCA string `json:"tls_client_ca,omitempty"` // TLSClientCA Path to the PEM CA bundle verifying client certificates (mutual TLS), disabled if empty
 }
 
 // MustLoadConfig loads the configuration from command-line flags, environment variables, and a JSON file.
@@ -34,6 +81,36 @@ func MustLoadConfig() *Config {
 	flag.StringVar(&config.DatabaseDSN, "d", "", "DSN строка для соединения с базой данных")
 	flag.StringVar(&config.Key, "k", "", "Ключ для вычисления хеша")
 	flag.StringVar(&config.PrivateKeyPath, "crypto-key", "", "Путь к файлу с закрытым ключом")
+	flag.StringVar(&config.BackupCompression, "backup-compression", "none", "Сжатие файла резервной копии: none, gzip или zstd")
+	flag.StringVar(&config.BackupFormat, "backup-format", "json", "Формат файла резервной копии: json, jsonl, gob или csv")
+	flag.StringVar(&config.BackupKeyPath, "backup-key-file", "", "Путь к файлу с ключом шифрования резервной копии")
+	flag.StringVar(&config.BackupTarget, "backup-target", "file", "Куда сохранять резервные копии: file или s3")
+	flag.StringVar(&config.S3Endpoint, "s3-endpoint", "", "Адрес S3-совместимого хранилища")
+	flag.StringVar(&config.S3Region, "s3-region", "", "Регион S3-совместимого хранилища")
+	flag.StringVar(&config.S3Bucket, "s3-bucket", "", "Бакет для резервных копий")
+	flag.StringVar(&config.S3Prefix, "s3-prefix", "metrics/", "Префикс ключей резервных копий")
+	flag.IntVar(&config.S3Keep, "s3-keep", 0, "Количество хранимых резервных копий (0 - все)")
+	flag.StringVar(&config.RemoteWriteRules, "remote-write-rules", "", "Правила определения типа метрик remote_write: regexp=gauge|counter через ;")
+	flag.StringVar(&config.GraphiteAddr, "graphite-address", "", "Адрес приёма метрик по протоколу Graphite (пусто - выключено)")
+	flag.BoolVar(&config.GraphiteUDP, "graphite-udp", false, "Принимать метрики Graphite также по UDP")
+	flag.IntVar(&config.GraphiteMaxConns, "graphite-max-connections", 100, "Максимальное количество TCP-соединений Graphite (0 - без ограничений)")
+	flag.StringVar(&config.GRPCAddr, "grpc-address", "", "Адрес и порт gRPC-сервера (пусто - gRPC отключен)")
+	flag.StringVar(&config.BatchMode, "batch-mode", "atomic", "Семантика пакетного обновления /updates/: atomic (всё или ничего) или best-effort (сохранять корректные метрики)")
+	flag.IntVar(&config.IdempotencyKeys, "idempotency-keys", 10000, "Количество запоминаемых ключей Idempotency-Key (0 - без дедупликации)")
+	flag.Float64Var(&config.RateLimit, "rate-limit", 0, "Ограничение запросов одного клиента в секунду (0 - без ограничений)")
+	flag.IntVar(&config.RateBurst, "rate-burst", 10, "Количество запросов, которые клиент может отправить разом")
+	flag.IntVar(&config.MaxConcurrent, "max-concurrent-requests", 0, "Максимальное количество одновременно обрабатываемых запросов (0 - без ограничений)")
+	flag.IntVar(&config.MaxStreams, "max-streams", 0, "Максимальное количество одновременно открытых потоков /updates/stream, не входящих в max-concurrent-requests (0 - без ограничений)")
+	flag.IntVar(&config.MaxBodySize, "max-body-size", 10485760, "Максимальный размер тела запроса в байтах (0 - без ограничений)")
+	flag.IntVar(&config.MaxDecompressedSize, "max-decompressed-size", 33554432, "Максимальный размер распакованного тела запроса в байтах (0 - без ограничений)")
+	flag.IntVar(&config.MaxRestoreSize, "max-restore-size", 1073741824, "Максимальный размер резервной копии, загружаемой в /admin/restore, в байтах; max-body-size к ней не применяется (0 - без ограничений)")
+	flag.IntVar(&config.MaxBatchItems, "max-batch-items", 10000, "Максимальное количество метрик в пакете /updates/ (0 - без ограничений)")
+	flag.StringVar(&config.TrustedSubnet, "t", "", "Доверенная подсеть агентов в формате CIDR, запись открыта всем если пусто")
+	flag.StringVar(&config.TokenFile, "token-file", "", "Путь к файлу с API-токенами, проверка токенов выключена если пусто")
+	flag.BoolVar(&config.TokenDB, "token-db", false, "Хранить API-токены в базе данных")
+	flag.StringVar(&config.TLSCert, "tls-cert", "", "Путь к файлу сертификата сервера, HTTPS выключен если пусто")
+	flag.StringVar(&config.TLSKey, "tls-key", "", "Путь к файлу закрытого ключа сертификата сервера")
+	flag.StringVar(&config.TLSClientCA, "tls-client-ca", "", "Путь к файлу CA для проверки сертификатов клиентов (mTLS), проверка выключена если пусто")
 	flag.StringVar(&configFilePath, "c", "", "Путь к файлу конфигурации")
 	flag.StringVar(&configFilePath, "config", "", "Путь к файлу конфигурации")
 	flag.Parse()
@@ -76,6 +153,176 @@ func MustLoadConfig() *Config {
 		config.PrivateKeyPath = envPrivateKeyPath
 	}
 
+	envBackupCompression := os.Getenv("BACKUP_COMPRESSION")
+	if envBackupCompression != "" {
+		config.BackupCompression = envBackupCompression
+	}
+
+	envBackupKeyPath := os.Getenv("BACKUP_KEY_FILE")
+	if envBackupKeyPath != "" {
+		config.BackupKeyPath = envBackupKeyPath
+	}
+
+	config.BackupKey = os.Getenv("BACKUP_KEY")
+
+	envBackupFormat := os.Getenv("BACKUP_FORMAT")
+	if envBackupFormat != "" {
+		config.BackupFormat = envBackupFormat
+	}
+
+	envBackupTarget := os.Getenv("BACKUP_TARGET")
+	if envBackupTarget != "" {
+		config.BackupTarget = envBackupTarget
+	}
+
+	envS3Endpoint := os.Getenv("S3_ENDPOINT")
+	if envS3Endpoint != "" {
+		config.S3Endpoint = envS3Endpoint
+	}
+
+	envS3Region := os.Getenv("S3_REGION")
+	if envS3Region != "" {
+		config.S3Region = envS3Region
+	}
+
+	envS3Bucket := os.Getenv("S3_BUCKET")
+	if envS3Bucket != "" {
+		config.S3Bucket = envS3Bucket
+	}
+
+	envS3Prefix := os.Getenv("S3_PREFIX")
+	if envS3Prefix != "" {
+		config.S3Prefix = envS3Prefix
+	}
+
+	envS3Keep := os.Getenv("S3_KEEP")
+	if envS3Keep != "" {
+		i, _ := strconv.Atoi(envS3Keep)
+		config.S3Keep = i
+	}
+
+	envRemoteWriteRules := os.Getenv("REMOTE_WRITE_RULES")
+	if envRemoteWriteRules != "" {
+		config.RemoteWriteRules = envRemoteWriteRules
+	}
+
+	envGraphiteAddr := os.Getenv("GRAPHITE_ADDRESS")
+	if envGraphiteAddr != "" {
+		config.GraphiteAddr = envGraphiteAddr
+	}
+
+	envGraphiteUDP := os.Getenv("GRAPHITE_UDP")
+	if envGraphiteUDP != "" {
+		b, _ := strconv.ParseBool(envGraphiteUDP)
+		config.GraphiteUDP = b
+	}
+
+	envGraphiteMaxConns := os.Getenv("GRAPHITE_MAX_CONNECTIONS")
+	if envGraphiteMaxConns != "" {
+		i, _ := strconv.Atoi(envGraphiteMaxConns)
+		config.GraphiteMaxConns = i
+	}
+
+	envGRPCAddr := os.Getenv("GRPC_ADDRESS")
+	if envGRPCAddr != "" {
+		config.GRPCAddr = envGRPCAddr
+	}
+
+	envBatchMode := os.Getenv("BATCH_MODE")
+	if envBatchMode != "" {
+		config.BatchMode = envBatchMode
+	}
+
+	envIdempotencyKeys := os.Getenv("IDEMPOTENCY_KEYS")
+	if envIdempotencyKeys != "" {
+		i, _ := strconv.Atoi(envIdempotencyKeys)
+		config.IdempotencyKeys = i
+	}
+
+	envRateLimit := os.Getenv("RATE_LIMIT")
+	if envRateLimit != "" {
+		f, _ := strconv.ParseFloat(envRateLimit, 64)
+		config.RateLimit = f
+	}
+
+	envRateBurst := os.Getenv("RATE_BURST")
+	if envRateBurst != "" {
+		i, _ := strconv.Atoi(envRateBurst)
+		config.RateBurst = i
+	}
+
+	envMaxConcurrent := os.Getenv("MAX_CONCURRENT_REQUESTS")
+	if envMaxConcurrent != "" {
+		i, _ := strconv.Atoi(envMaxConcurrent)
+		config.MaxConcurrent = i
+	}
+
+	envMaxStreams := os.Getenv("MAX_STREAMS")
+	if envMaxStreams != "" {
+		i, _ := strconv.Atoi(envMaxStreams)
+		config.MaxStreams = i
+	}
+
+	envMaxBodySize := os.Getenv("MAX_BODY_SIZE")
+	if envMaxBodySize != "" {
+		i, _ := strconv.Atoi(envMaxBodySize)
+		config.MaxBodySize = i
+	}
+
+	envMaxRestoreSize := os.Getenv("MAX_RESTORE_SIZE")
+	if envMaxRestoreSize != "" {
+		i, _ := strconv.Atoi(envMaxRestoreSize)
+		config.MaxRestoreSize = i
+	}
+
+	envMaxDecompressedSize := os.Getenv("MAX_DECOMPRESSED_SIZE")
+	if envMaxDecompressedSize != "" {
+		i, _ := strconv.Atoi(envMaxDecompressedSize)
+		config.MaxDecompressedSize = i
+	}
+
+	envMaxBatchItems := os.Getenv("MAX_BATCH_ITEMS")
+	if envMaxBatchItems != "" {
+		i, _ := strconv.Atoi(envMaxBatchItems)
+		config.MaxBatchItems = i
+	}
+
+	envTrustedSubnet := os.Getenv("TRUSTED_SUBNET")
+	if envTrustedSubnet != "" {
+		config.TrustedSubnet = envTrustedSubnet
+	}
+
+	envTokenFile := os.Getenv("TOKEN_FILE")
+	if envTokenFile != "" {
+		config.TokenFile = envTokenFile
+	}
+
+	envTokenDB := os.Getenv("TOKEN_DB")
+	if envTokenDB != "" {
+		b, _ := strconv.ParseBool(envTokenDB)
+		config.TokenDB = b
+	}
+
+	envTLSCert := os.Getenv("TLS_CERT")
+	if envTLSCert != "" {
+		config.TLSCert = envTLSCert
+	}
+
+	envTLSKey := os.Getenv("TLS_KEY")
+	if envTLSKey != "" {
+		config.TLSKey = envTLSKey
+	}
+
+	envTLSClientCA := os.Getenv("TLS_CLIENT_CA")
+	if envTLSClientCA != "" {
+		config.TLSClientCA = envTLSClientCA
+	}
+
+	config.AdminToken = os.Getenv("ADMIN_TOKEN")
+
+	config.S3AccessKey = os.Getenv("S3_ACCESS_KEY")
+	config.S3SecretKey = os.Getenv("S3_SECRET_KEY")
+
 	if _, err := os.Stat(config.PrivateKeyPath); os.IsNotExist(err) && config.PrivateKeyPath != "" {
 		os.Exit(5)
 	}
@@ -110,6 +357,96 @@ func MustLoadConfig() *Config {
 				if config.PrivateKeyPath == "" {
 					config.PrivateKeyPath = fileConfig.PrivateKeyPath
 				}
+				if config.BackupCompression == "none" && fileConfig.BackupCompression != "" {
+					config.BackupCompression = fileConfig.BackupCompression
+				}
+				if config.BackupKeyPath == "" {
+					config.BackupKeyPath = fileConfig.BackupKeyPath
+				}
+				if config.BackupFormat == "json" && fileConfig.BackupFormat != "" {
+					config.BackupFormat = fileConfig.BackupFormat
+				}
+				if config.BackupTarget == "file" && fileConfig.BackupTarget != "" {
+					config.BackupTarget = fileConfig.BackupTarget
+				}
+				if config.S3Endpoint == "" {
+					config.S3Endpoint = fileConfig.S3Endpoint
+				}
+				if config.S3Region == "" {
+					config.S3Region = fileConfig.S3Region
+				}
+				if config.S3Bucket == "" {
+					config.S3Bucket = fileConfig.S3Bucket
+				}
+				if config.S3Prefix == "metrics/" && fileConfig.S3Prefix != "" {
+					config.S3Prefix = fileConfig.S3Prefix
+				}
+				if config.S3Keep == 0 {
+					config.S3Keep = fileConfig.S3Keep
+				}
+				if config.RemoteWriteRules == "" {
+					config.RemoteWriteRules = fileConfig.RemoteWriteRules
+				}
+				if config.GraphiteAddr == "" {
+					config.GraphiteAddr = fileConfig.GraphiteAddr
+				}
+				if !config.GraphiteUDP {
+					config.GraphiteUDP = fileConfig.GraphiteUDP
+				}
+				if config.GraphiteMaxConns == 100 && fileConfig.GraphiteMaxConns != 0 {
+					config.GraphiteMaxConns = fileConfig.GraphiteMaxConns
+				}
+				if config.GRPCAddr == "" {
+					config.GRPCAddr = fileConfig.GRPCAddr
+				}
+				if config.BatchMode == "atomic" && fileConfig.BatchMode != "" {
+					config.BatchMode = fileConfig.BatchMode
+				}
+				if config.IdempotencyKeys == 10000 && fileConfig.IdempotencyKeys != 0 {
+					config.IdempotencyKeys = fileConfig.IdempotencyKeys
+				}
+				if config.RateLimit == 0 {
+					config.RateLimit = fileConfig.RateLimit
+				}
+				if config.RateBurst == 10 && fileConfig.RateBurst != 0 {
+					config.RateBurst = fileConfig.RateBurst
+				}
+				if config.MaxConcurrent == 0 {
+					config.MaxConcurrent = fileConfig.MaxConcurrent
+				}
+				if config.MaxStreams == 0 {
+					config.MaxStreams = fileConfig.MaxStreams
+				}
+				if config.MaxBodySize == 10485760 && fileConfig.MaxBodySize != 0 {
+					config.MaxBodySize = fileConfig.MaxBodySize
+				}
+				if config.MaxDecompressedSize == 33554432 && fileConfig.MaxDecompressedSize != 0 {
+					config.MaxDecompressedSize = fileConfig.MaxDecompressedSize
+				}
+				if config.MaxRestoreSize == 1073741824 && fileConfig.MaxRestoreSize != 0 {
+					config.MaxRestoreSize = fileConfig.MaxRestoreSize
+				}
+				if config.MaxBatchItems == 10000 && fileConfig.MaxBatchItems != 0 {
+					config.MaxBatchItems = fileConfig.MaxBatchItems
+				}
+				if config.TrustedSubnet == "" {
+					config.TrustedSubnet = fileConfig.TrustedSubnet
+				}
+				if config.TokenFile == "" {
+					config.TokenFile = fileConfig.TokenFile
+				}
+				if !config.TokenDB {
+					config.TokenDB = fileConfig.TokenDB
+				}
+				if config.TLSCert == "" {
+					config.TLSCert = fileConfig.TLSCert
+				}
+				if config.TLSKey == "" {
+					config.TLSKey = fileConfig.TLSKey
+				}
+				if config.TLSClientCA == "" {
+					config.TLSClientCA = fileConfig.TLSClientCA
+				}
 			}
 			_ = file.Close()
 		}
@@ -3,6 +3,11 @@
 // and the value of the metric which can be either Delta (for counter) or Value (for gauge).
 package format
 
+import (
+	"errors"
+	"strconv"
+)
+
 // Metric represents a structure for metrics used in request/response.
 // It contains the ID of the metric, the type of the metric (either gauge or counter),
 // and the value of the metric which can be either Delta (for counter) or Value (for gauge).
@@ -22,3 +27,75 @@ const (
 	// It is used to measure values that only go up, such as the number of requests received or errors encountered.
 	Counter = "counter"
 )
+
+// ErrUnknownType is returned for metric types other than gauge and counter.
+var ErrUnknownType = errors.New("unknown metric type")
+
+// NewMetric builds a Metric from a value as returned by the storage.
+//
+// Parameters:
+//   - typ: the type of the metric, gauge or counter.
+//   - id: the name of the metric.
+//   - value: the stored value, a float for gauges and an integer for counters.
+//
+// Returns:
+//   - Metric: the metric with Value or Delta set.
+//   - error: ErrUnknownType or a parse error of the value.
+func NewMetric(typ, id, value string) (Metric, error) {
+	m := Metric{ID: id, MType: typ}
+	switch typ {
+	case Gauge:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return m, err
+		}
+		m.Value = &v
+	case Counter:
+		v, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return m, err
+		}
+		m.Delta = &v
+	default:
+		return m, ErrUnknownType
+	}
+	return m, nil
+}
+
+// StoredValue returns the value of the metric in the form used by the storage.
+func (m Metric) StoredValue() string {
+	switch {
+	case m.Delta != nil:
+		return strconv.FormatInt(*m.Delta, 10)
+	case m.Value != nil:
+		return strconv.FormatFloat(*m.Value, 'f', -1, 64)
+	}
+	return ""
+}
+
+// NewMetrics builds metrics from the gauges and counters returned by the storage,
+// gauges first. Rows whose value cannot be parsed are skipped.
+//
+// Parameters:
+//   - gauges: gauge metrics as name and value pairs.
+//   - counters: counter metrics as name and value pairs.
+//
+// Returns:
+//   - []Metric: the metrics.
+func NewMetrics(gauges [][]string, counters [][]string) []Metric {
+	metrics := make([]Metric, 0, len(gauges)+len(counters))
+	for _, rows := range []struct {
+		typ  string
+		rows [][]string
+	}{{Gauge, gauges}, {Counter, counters}} {
+		for _, row := range rows.rows {
+			if len(row) < 2 {
+				continue
+			}
+			if m, err := NewMetric(rows.typ, row[0], row[1]); err == nil {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,5 @@
+// Package metricpb contains the protobuf messages and the gRPC service of the metric server.
+// The code is generated from metrics.proto.
+package metricpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
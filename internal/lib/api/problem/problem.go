@@ -0,0 +1,222 @@
+// Package problem provides the error model of the HTTP API.
+// Errors are sent as RFC 7807 "application/problem+json" documents extended with
+// a machine-readable code and the ID of the request that failed.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+// ContentType is the media type of problem documents.
+const ContentType = "application/problem+json"
+
+// Machine-readable error codes.
+const (
+	// CodeBadRequest means that the request is malformed in a way not covered by a more specific code.
+	CodeBadRequest = "bad_request"
+
+	// CodeInvalidJSON means that the request body is not valid JSON of the expected shape.
+	CodeInvalidJSON = "invalid_json"
+
+	// CodeUnsupportedEncoding means that the Content-Encoding of the request is not supported; the supported ones are listed in Accept-Encoding.
+	CodeUnsupportedEncoding = "unsupported_encoding"
+
+	// CodeTooLarge means that the request body, once decompressed, or the number of its items exceeds a limit.
+	CodeTooLarge = "payload_too_large"
+
+	// CodeInvalidValue means that a metric value cannot be parsed.
+	CodeInvalidValue = "invalid_value"
+
+	// CodeUnknownType means that the metric type is neither gauge nor counter.
+	CodeUnknownType = "unknown_metric_type"
+
+	// CodeNotFound means that the requested metric does not exist or its name is missing.
+	CodeNotFound = "metric_not_found"
+
+	// CodeValidation means that the request is well-formed but semantically invalid.
+	CodeValidation = "validation_failed"
+
+	// CodePartialWrite means that some parts of the request were rejected; they are listed in invalid_params.
+	CodePartialWrite = "partial_write"
+
+	// CodeBackupNotFound means that the requested backup generation does not exist.
+	CodeBackupNotFound = "backup_not_found"
+
+	// CodeInvalidQuery means that a query expression cannot be parsed or uses an unknown function.
+	CodeInvalidQuery = "invalid_query"
+
+	// CodeNoData means that the stored data is not enough to evaluate a query expression.
+	CodeNoData = "no_data"
+
+	// CodeNotAcceptable means that none of the formats the endpoint offers matches the Accept header.
+	CodeNotAcceptable = "not_acceptable"
+
+	// CodeIdempotencyKeyReused means that the Idempotency-Key was already used for a request with another body.
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+
+	// CodeRateLimited means that the client sends requests faster than it is allowed; it may retry after Retry-After.
+	CodeRateLimited = "rate_limited"
+
+	// CodeOverloaded means that the server handles as many requests as it can; the client may retry after Retry-After.
+	CodeOverloaded = "overloaded"
+
+	// CodeSignatureMismatch means that the HashSHA256 header does not match the body.
+	CodeSignatureMismatch = "signature_mismatch"
+
+	// CodeDecryptionFailed means that the encrypted request body cannot be decrypted with the server key.
+	CodeDecryptionFailed = "decryption_failed"
+
+	// CodeUnauthorized means that the request lacks a valid credential.
+	CodeUnauthorized = "unauthorized"
+
+	// CodeForbidden means that the credential does not grant access to the resource.
+	CodeForbidden = "forbidden"
+
+	// CodeStorageUnavailable means that the storage cannot serve the request at the moment.
+	CodeStorageUnavailable = "storage_unavailable"
+
+	// CodeInternal means an unexpected server error.
+	CodeInternal = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type      string `json:"type"`                 // Type is a URI reference identifying the problem type.
+	Title     string `json:"title"`                // Title is a short summary of the problem type.
+	Status    int    `json:"status"`               // Status is the HTTP status code.
+	Detail    string `json:"detail,omitempty"`     // Detail is a human-readable explanation of this occurrence.
+	Instance  string `json:"instance,omitempty"`   // Instance is the path of the request that failed.
+	Code      string `json:"code"`                 // Code is the machine-readable error code.
+	RequestID string `json:"request_id,omitempty"` // RequestID is the ID assigned by the RequestID middleware.
+
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"` // InvalidParams lists the rejected parts of the request.
+	Accepted      []string       `json:"accepted,omitempty"`       // Accepted lists the parts of a partially applied request that were written.
+}
+
+// InvalidParam describes a single rejected part of the request, such as a line or an array item.
+type InvalidParam struct {
+	Name   string `json:"name"`   // Name identifies the rejected part, e.g. "line 3".
+	Reason string `json:"reason"` // Reason explains why it was rejected.
+}
+
+// New builds a problem document for the given request.
+//
+// Parameters:
+//   - r: the request that failed.
+//   - status: the HTTP status code.
+//   - code: the machine-readable error code.
+//   - detail: a human-readable explanation.
+//
+// Returns:
+//   - Problem: the problem document.
+func New(r *http.Request, status int, code string, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// Write sends a problem document with the given status and code.
+//
+// Parameters:
+//   - w: the response writer.
+//   - r: the request that failed.
+//   - status: the HTTP status code.
+//   - code: the machine-readable error code.
+//   - detail: a human-readable explanation.
+func Write(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	Send(w, New(r, status, code, detail))
+}
+
+// Send writes the problem document with its status.
+//
+// Parameters:
+//   - w: the response writer.
+//   - p: the problem document.
+func Send(w http.ResponseWriter, p Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		w.WriteHeader(p.Status)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// WriteError sends a problem document for a storage error.
+// The status and code are chosen with FromError. The error text is used as the detail of 4xx
+// problems only: server errors may carry internal details such as queries, hosts or file paths,
+// so they get a generic detail and the caller is expected to log the error itself.
+//
+// Parameters:
+//   - w: the response writer.
+//   - r: the request that failed.
+//   - err: the error returned by the storage.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := FromError(err)
+	detail := err.Error()
+	switch {
+	case status == http.StatusServiceUnavailable:
+		detail = "storage is unavailable"
+	case status >= http.StatusInternalServerError:
+		detail = "internal error"
+	}
+	Write(w, r, status, code, detail)
+}
+
+// WriteReadError sends a problem document for an error reading the request body.
+// A body cut off by http.MaxBytesReader is answered with 413 payload_too_large,
+// any other error with 400 and the given code and detail.
+//
+// Parameters:
+//   - w: the response writer.
+//   - r: the request that failed.
+//   - err: the error returned while reading or decoding the body.
+//   - code: the machine-readable error code of other errors.
+//   - detail: a human-readable explanation of other errors.
+func WriteReadError(w http.ResponseWriter, r *http.Request, err error, code string, detail string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		Write(w, r, http.StatusRequestEntityTooLarge, CodeTooLarge,
+			"request body exceeds "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes")
+		return
+	}
+	Write(w, r, http.StatusBadRequest, code, detail)
+}
+
+// FromError maps storage errors to an HTTP status and code:
+// a missing metric is 404, an invalid value is 422, an unavailable storage is 503
+// and anything else is 500.
+//
+// Parameters:
+//   - err: the error returned by the storage.
+//
+// Returns:
+//   - int: the HTTP status code.
+//   - string: the machine-readable error code.
+func FromError(err error) (int, string) {
+	switch {
+	case errors.Is(err, storage.ErrMetricNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, storage.ErrInvalidValue):
+		return http.StatusUnprocessableEntity, CodeValidation
+	case errors.Is(err, storage.ErrStorageUnavailable):
+		return http.StatusServiceUnavailable, CodeStorageUnavailable
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
@@ -0,0 +1,76 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{name: "not found", err: fmt.Errorf("get: %w", storage.ErrMetricNotFound), wantStatus: http.StatusNotFound, wantCode: CodeNotFound, wantDetail: "get: metric not found"},
+		{name: "invalid value", err: fmt.Errorf("update: %w", storage.ErrInvalidValue), wantStatus: http.StatusUnprocessableEntity, wantCode: CodeValidation, wantDetail: "update: invalid metric value"},
+		{name: "unavailable", err: fmt.Errorf("update: %w", storage.ErrStorageUnavailable), wantStatus: http.StatusServiceUnavailable, wantCode: CodeStorageUnavailable, wantDetail: "storage is unavailable"},
+		{name: "unknown", err: errors.New("dial tcp 10.0.0.5:5432: connection refused"), wantStatus: http.StatusInternalServerError, wantCode: CodeInternal, wantDetail: "internal error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/value/gauge/test", nil)
+			w := httptest.NewRecorder()
+
+			WriteError(w, r, tt.err)
+
+			res := w.Result()
+			defer res.Body.Close()
+			require.Equal(t, tt.wantStatus, res.StatusCode)
+			require.Equal(t, ContentType, res.Header.Get("Content-Type"))
+
+			var got Problem
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+			require.Equal(t, tt.wantStatus, got.Status)
+			require.Equal(t, tt.wantCode, got.Code)
+			require.Equal(t, "/value/gauge/test", got.Instance)
+			require.Equal(t, tt.wantDetail, got.Detail)
+		})
+	}
+}
+
+func TestWriteReadError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "too large", err: fmt.Errorf("decode: %w", &http.MaxBytesError{Limit: 10}), wantStatus: http.StatusRequestEntityTooLarge, wantCode: CodeTooLarge},
+		{name: "malformed", err: errors.New("unexpected EOF"), wantStatus: http.StatusBadRequest, wantCode: CodeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			w := httptest.NewRecorder()
+
+			WriteReadError(w, r, tt.err, CodeInvalidJSON, tt.err.Error())
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			var got Problem
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+			require.Equal(t, tt.wantCode, got.Code)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package serializer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/exposition"
+)
+
+// Content types of the formats of this package.
+const (
+	ContentTypeText    = "text/plain; charset=utf-8"
+	ContentTypeJSON    = "application/json"
+	ContentTypeCSV     = "text/csv; charset=utf-8"
+	ContentTypeMsgPack = "application/msgpack"
+)
+
+// Text renders the bare value of a single metric, as the /value/ endpoint always did,
+// and one "id type value" line per metric for lists.
+type Text struct{}
+
+// ContentType implements Serializer.
+func (Text) ContentType() string { return ContentTypeText }
+
+// WriteMetric implements Serializer.
+func (Text) WriteMetric(w io.Writer, m format.Metric) error {
+	_, err := io.WriteString(w, m.StoredValue())
+	return err
+}
+
+// WriteMetrics implements Serializer.
+func (Text) WriteMetrics(w io.Writer, metrics []format.Metric) error {
+	for _, m := range metrics {
+		if _, err := io.WriteString(w, m.ID+" "+m.MType+" "+m.StoredValue()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSON renders metrics in the format of the /value/ and /updates/ endpoints.
+type JSON struct{}
+
+// ContentType implements Serializer.
+func (JSON) ContentType() string { return ContentTypeJSON }
+
+// WriteMetric implements Serializer.
+func (JSON) WriteMetric(w io.Writer, m format.Metric) error {
+	return writeJSON(w, m)
+}
+
+// WriteMetrics implements Serializer.
+func (JSON) WriteMetrics(w io.Writer, metrics []format.Metric) error {
+	if metrics == nil {
+		metrics = []format.Metric{}
+	}
+	return writeJSON(w, metrics)
+}
+
+// writeJSON writes v without the trailing newline of json.Encoder.
+func writeJSON(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// CSV renders metrics as comma-separated id, type and value columns with a header row.
+type CSV struct{}
+
+// ContentType implements Serializer.
+func (CSV) ContentType() string { return ContentTypeCSV }
+
+// WriteMetric implements Serializer.
+func (c CSV) WriteMetric(w io.Writer, m format.Metric) error {
+	return c.WriteMetrics(w, []format.Metric{m})
+}
+
+// WriteMetrics implements Serializer.
+func (CSV) WriteMetrics(w io.Writer, metrics []format.Metric) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "type", "value"}); err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		if err := cw.Write([]string{m.ID, m.MType, m.StoredValue()}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Prometheus renders metrics in the Prometheus text exposition format.
+type Prometheus struct{}
+
+// ContentType implements Serializer.
+func (Prometheus) ContentType() string { return exposition.ContentTypeText }
+
+// WriteMetric implements Serializer.
+func (Prometheus) WriteMetric(w io.Writer, m format.Metric) error {
+	return exposition.WriteText(w, samples([]format.Metric{m}))
+}
+
+// WriteMetrics implements Serializer.
+func (Prometheus) WriteMetrics(w io.Writer, metrics []format.Metric) error {
+	return exposition.WriteText(w, samples(metrics))
+}
+
+// OpenMetrics renders metrics in the OpenMetrics text format.
+type OpenMetrics struct{}
+
+// ContentType implements Serializer.
+func (OpenMetrics) ContentType() string { return exposition.ContentTypeOpenMetrics }
+
+// WriteMetric implements Serializer.
+func (OpenMetrics) WriteMetric(w io.Writer, m format.Metric) error {
+	return exposition.WriteOpenMetrics(w, samples([]format.Metric{m}))
+}
+
+// WriteMetrics implements Serializer.
+func (OpenMetrics) WriteMetrics(w io.Writer, metrics []format.Metric) error {
+	return exposition.WriteOpenMetrics(w, samples(metrics))
+}
+
+// samples converts metrics into exposition samples.
+func samples(metrics []format.Metric) []exposition.Sample {
+	var gauges, counters [][]string
+	for _, m := range metrics {
+		if m.MType == format.Counter {
+			counters = append(counters, []string{m.ID, m.StoredValue()})
+		} else {
+			gauges = append(gauges, []string{m.ID, m.StoredValue()})
+		}
+	}
+	return exposition.NewSamples(gauges, counters)
+}
+
+// MsgPack renders metrics in MessagePack as maps with the keys of the JSON format.
+type MsgPack struct{}
+
+// ContentType implements Serializer.
+func (MsgPack) ContentType() string { return ContentTypeMsgPack }
+
+// WriteMetric implements Serializer.
+func (MsgPack) WriteMetric(w io.Writer, m format.Metric) error {
+	return writeMsgPack(w, m)
+}
+
+// WriteMetrics implements Serializer.
+func (MsgPack) WriteMetrics(w io.Writer, metrics []format.Metric) error {
+	if metrics == nil {
+		metrics = []format.Metric{}
+	}
+	return writeMsgPack(w, metrics)
+}
+
+func writeMsgPack(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
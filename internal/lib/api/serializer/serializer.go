@@ -0,0 +1,218 @@
+// Package serializer renders metrics in the formats the read endpoints offer and picks
+// the format from the Accept header. Every read handler uses the same Registry,
+// so a format registered once is available everywhere.
+package serializer
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+// ErrNotAcceptable is returned when none of the registered formats is acceptable to the client.
+var ErrNotAcceptable = errors.New("no acceptable format")
+
+// Serializer renders metrics in one format.
+type Serializer interface {
+	// ContentType returns the value of the Content-Type header of the rendered body.
+	ContentType() string
+
+	// WriteMetric renders a single metric.
+	WriteMetric(w io.Writer, m format.Metric) error
+
+	// WriteMetrics renders a list of metrics.
+	WriteMetrics(w io.Writer, metrics []format.Metric) error
+}
+
+// entry is a registered serializer with its parsed media type.
+type entry struct {
+	mediaType string
+	version   string
+	s         Serializer
+}
+
+// Registry holds the available formats.
+type Registry struct {
+	entries []entry
+}
+
+// NewRegistry creates a Registry.
+//
+// Parameters:
+//   - serializers: the formats, in order of preference for Accept ranges such as text/*.
+//
+// Returns:
+//   - *Registry: the registry.
+func NewRegistry(serializers ...Serializer) *Registry {
+	r := &Registry{}
+	for _, s := range serializers {
+		r.Register(s)
+	}
+	return r
+}
+
+// NewDefault creates a Registry with every format of this package: plain text, JSON,
+// CSV, Prometheus text, OpenMetrics and MessagePack.
+func NewDefault() *Registry {
+	return NewRegistry(Text{}, JSON{}, CSV{}, Prometheus{}, OpenMetrics{}, MsgPack{})
+}
+
+// Register adds a format. A format with the same media type and version as a
+// registered one replaces it.
+func (r *Registry) Register(s Serializer) {
+	mediaType, params, err := mime.ParseMediaType(s.ContentType())
+	if err != nil {
+		mediaType = s.ContentType()
+	}
+	e := entry{mediaType: mediaType, version: params["version"], s: s}
+	for i := range r.entries {
+		if r.entries[i].mediaType == e.mediaType && r.entries[i].version == e.version {
+			r.entries[i] = e
+			return
+		}
+	}
+	r.entries = append(r.entries, e)
+}
+
+// Lookup returns the format registered for the media type, ignoring parameters other than version.
+func (r *Registry) Lookup(contentType string) (Serializer, bool) {
+	i := r.index(contentType)
+	if i < 0 {
+		return nil, false
+	}
+	return r.entries[i].s, true
+}
+
+// index returns the position of the format registered for the media type or -1.
+func (r *Registry) index(contentType string) int {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return -1
+	}
+	for i, e := range r.entries {
+		if e.mediaType == mediaType && e.version == params["version"] {
+			return i
+		}
+	}
+	return -1
+}
+
+// Negotiate picks the format for the Accept header.
+//
+// Ranges are tried by quality and then by specificity. A range carrying a version
+// parameter matches only the format of that version, so "text/plain; version=0.0.4" is
+// always the Prometheus format, while a plain "text/plain" is the endpoint's own format
+// if that is a text/plain one and plain text otherwise. An empty header or */* selects
+// the endpoint's own format.
+//
+// Parameters:
+//   - accept: the value of the Accept header.
+//   - fallback: the content type of the endpoint's own format; it must be registered.
+//
+// Returns:
+//   - Serializer: the chosen format.
+//   - error: ErrNotAcceptable if nothing registered is acceptable.
+func (r *Registry) Negotiate(accept string, fallback string) (Serializer, error) {
+	if len(r.entries) == 0 {
+		return nil, ErrNotAcceptable
+	}
+	def := r.index(fallback)
+	if def < 0 {
+		def = 0
+	}
+	if strings.TrimSpace(accept) == "" {
+		return r.entries[def].s, nil
+	}
+
+	for _, rng := range parseAccept(accept) {
+		if rng.q <= 0 {
+			continue
+		}
+		if rng.mediaType == "*/*" {
+			return r.entries[def].s, nil
+		}
+		if s, ok := r.match(rng, def); ok {
+			return s, nil
+		}
+	}
+	return nil, ErrNotAcceptable
+}
+
+// match finds a format for one media range, preferring the default format and then
+// the order of registration.
+func (r *Registry) match(rng mediaRange, def int) (Serializer, bool) {
+	if rng.matches(r.entries[def]) {
+		return r.entries[def].s, true
+	}
+	for _, e := range r.entries {
+		if rng.matches(e) {
+			return e.s, true
+		}
+	}
+	return nil, false
+}
+
+// mediaRange is one element of an Accept header.
+type mediaRange struct {
+	mediaType string
+	version   string
+	q         float64
+}
+
+// specificity orders ranges with equal quality: exact types before type/* before */*.
+func (m mediaRange) specificity() int {
+	switch {
+	case m.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(m.mediaType, "/*"):
+		return 1
+	case m.version != "":
+		return 3
+	}
+	return 2
+}
+
+func (m mediaRange) matches(e entry) bool {
+	if strings.HasSuffix(m.mediaType, "/*") {
+		if !strings.HasPrefix(e.mediaType, strings.TrimSuffix(m.mediaType, "*")) {
+			return false
+		}
+	} else if m.mediaType != e.mediaType {
+		return false
+	}
+	// A range without a version matches every version of the media type.
+	return m.version == "" || m.version == e.version
+}
+
+// parseAccept parses the Accept header into ranges ordered by preference.
+// Malformed ranges are skipped.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		rng := mediaRange{mediaType: mediaType, version: params["version"], q: 1}
+		if q, ok := params["q"]; ok {
+			v, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			rng.q = v
+		}
+		ranges = append(ranges, rng)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
@@ -0,0 +1,150 @@
+package serializer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/exposition"
+)
+
+func TestRegistry_Negotiate(t *testing.T) {
+	r := NewDefault()
+
+	tests := []struct {
+		name     string
+		accept   string
+		fallback string
+		want     string
+		wantErr  error
+	}{
+		{name: "no header", fallback: ContentTypeJSON, want: ContentTypeJSON},
+		{name: "any", accept: "*/*", fallback: ContentTypeText, want: ContentTypeText},
+		{name: "exact", accept: "text/csv", fallback: ContentTypeJSON, want: ContentTypeCSV},
+		{name: "msgpack", accept: "application/msgpack", fallback: ContentTypeJSON, want: ContentTypeMsgPack},
+		{name: "quality", accept: "application/json;q=0.5, text/csv", fallback: ContentTypeText, want: ContentTypeCSV},
+		{name: "unsupported before any", accept: "image/png, */*;q=0.1", fallback: ContentTypeJSON, want: ContentTypeJSON},
+		{name: "plain text", accept: "text/plain", fallback: ContentTypeJSON, want: ContentTypeText},
+		{name: "plain text keeps Prometheus default", accept: "text/plain", fallback: exposition.ContentTypeText, want: exposition.ContentTypeText},
+		{name: "Prometheus by version", accept: "text/plain; version=0.0.4", fallback: ContentTypeText, want: exposition.ContentTypeText},
+		{
+			name:     "Prometheus scraper",
+			accept:   "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			fallback: exposition.ContentTypeText,
+			want:     exposition.ContentTypeOpenMetrics,
+		},
+		{name: "type wildcard", accept: "text/*", fallback: ContentTypeJSON, want: ContentTypeText},
+		{name: "excluded", accept: "application/json;q=0", fallback: ContentTypeJSON, wantErr: ErrNotAcceptable},
+		{name: "not acceptable", accept: "image/png", fallback: ContentTypeJSON, wantErr: ErrNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := r.Negotiate(tt.accept, tt.fallback)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, s.ContentType())
+		})
+	}
+}
+
+// upper is a format registered by a test.
+type upper struct{}
+
+func (upper) ContentType() string { return "text/x-upper" }
+
+func (upper) WriteMetric(w io.Writer, m format.Metric) error {
+	_, err := io.WriteString(w, "METRIC")
+	return err
+}
+
+func (upper) WriteMetrics(w io.Writer, metrics []format.Metric) error {
+	_, err := io.WriteString(w, "METRICS")
+	return err
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewDefault()
+	_, err := r.Negotiate("text/x-upper", ContentTypeJSON)
+	require.ErrorIs(t, err, ErrNotAcceptable)
+
+	r.Register(upper{})
+	s, err := r.Negotiate("text/x-upper", ContentTypeJSON)
+	require.NoError(t, err)
+	require.Equal(t, upper{}, s)
+
+	s, ok := r.Lookup("application/json; charset=utf-8")
+	require.True(t, ok)
+	require.Equal(t, JSON{}, s)
+}
+
+func TestFormats(t *testing.T) {
+	gauge, delta := 1.5, int64(42)
+	metrics := []format.Metric{
+		{ID: "Alloc", MType: format.Gauge, Value: &gauge},
+		{ID: "PollCount", MType: format.Counter, Delta: &delta},
+	}
+
+	tests := []struct {
+		s          Serializer
+		wantMetric string
+		wantList   string
+	}{
+		{
+			s:          Text{},
+			wantMetric: "1.5",
+			wantList:   "Alloc gauge 1.5\nPollCount counter 42\n",
+		},
+		{
+			s:          JSON{},
+			wantMetric: `{"id":"Alloc","type":"gauge","value":1.5}`,
+			wantList:   `[{"id":"Alloc","type":"gauge","value":1.5},{"id":"PollCount","type":"counter","delta":42}]`,
+		},
+		{
+			s:          CSV{},
+			wantMetric: "id,type,value\nAlloc,gauge,1.5\n",
+			wantList:   "id,type,value\nAlloc,gauge,1.5\nPollCount,counter,42\n",
+		},
+		{
+			s:          Prometheus{},
+			wantMetric: "# TYPE Alloc gauge\nAlloc 1.5\n",
+			wantList:   "# TYPE Alloc gauge\nAlloc 1.5\n# TYPE PollCount counter\nPollCount 42\n",
+		},
+		{
+			s:          OpenMetrics{},
+			wantMetric: "# TYPE Alloc gauge\nAlloc 1.5\n# EOF\n",
+			wantList:   "# TYPE Alloc gauge\nAlloc 1.5\n# TYPE PollCount counter\nPollCount_total 42\n# EOF\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s.ContentType(), func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, tt.s.WriteMetric(&b, metrics[0]))
+			require.Equal(t, tt.wantMetric, b.String())
+
+			b.Reset()
+			require.NoError(t, tt.s.WriteMetrics(&b, metrics))
+			require.Equal(t, tt.wantList, b.String())
+		})
+	}
+}
+
+func TestMsgPack(t *testing.T) {
+	delta := int64(42)
+	var b bytes.Buffer
+	require.NoError(t, MsgPack{}.WriteMetrics(&b, []format.Metric{{ID: "PollCount", MType: format.Counter, Delta: &delta}}))
+
+	var got []map[string]any
+	require.NoError(t, msgpack.Unmarshal(b.Bytes(), &got))
+	require.Len(t, got, 1)
+	require.Equal(t, "PollCount", got[0]["id"])
+	require.Equal(t, "counter", got[0]["type"])
+	require.EqualValues(t, 42, got[0]["delta"])
+	require.NotContains(t, got[0], "value")
+}
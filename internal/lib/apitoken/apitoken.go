@@ -0,0 +1,134 @@
+// Package apitoken implements the bearer tokens of the API. A token grants scopes: read for
+// the endpoints returning metrics, write for the endpoints storing them and admin for the
+// administrative endpoints. Only the SHA-256 hashes of the tokens are stored, the token itself
+// is shown once, when it is created.
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope is a permission granted by a token.
+type Scope string
+
+// Scopes of the API.
+const (
+	ScopeRead  Scope = "read"  // ScopeRead allows reading metrics.
+	ScopeWrite Scope = "write" // ScopeWrite allows storing metrics.
+	ScopeAdmin Scope = "admin" // ScopeAdmin allows backups and restores, and includes the other scopes.
+)
+
+// Prefix starts every token, so that a leaked token is easy to recognize.
+const Prefix = "mt_"
+
+// ErrNotFound is returned for a token that does not exist or was revoked.
+var ErrNotFound = errors.New("token not found")
+
+// ErrInvalidScope is returned for an unknown scope.
+var ErrInvalidScope = errors.New("invalid scope")
+
+// Token describes an issued token.
+type Token struct {
+	ID        string    `json:"id"`         // ID identifies the token for listing and revoking.
+	Name      string    `json:"name"`       // Name describes the holder of the token.
+	Hash      string    `json:"hash"`       // Hash is the SHA-256 hash of the token, hex-encoded.
+	Scopes    []Scope   `json:"scopes"`     // Scopes are the permissions granted by the token.
+	CreatedAt time.Time `json:"created_at"` // CreatedAt is the time the token was issued.
+}
+
+// Allows reports whether the token grants the scope. The admin scope grants every scope.
+func (t Token) Allows(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Store keeps the issued tokens.
+type Store interface {
+	// Lookup returns the token with the given hash, ErrNotFound if there is none.
+	Lookup(ctx context.Context, hash string) (Token, error)
+
+	// Add stores a new token.
+	Add(ctx context.Context, token Token) error
+
+	// List returns the stored tokens.
+	List(ctx context.Context) ([]Token, error)
+
+	// Revoke deletes the token with the given ID, ErrNotFound if there is none.
+	Revoke(ctx context.Context, id string) error
+}
+
+// ParseScopes parses a comma-separated list of scopes.
+//
+// Parameters:
+//   - value: the list, e.g. "read,write".
+//
+// Returns:
+//   - []Scope: the scopes, without duplicates.
+//   - error: ErrInvalidScope if the list is empty or contains an unknown scope.
+func ParseScopes(value string) ([]Scope, error) {
+	var scopes []Scope
+	seen := make(map[Scope]bool)
+	for _, name := range strings.Split(value, ",") {
+		scope := Scope(strings.ToLower(strings.TrimSpace(name)))
+		switch scope {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, name)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// Hash returns the hash a token is stored and looked up by.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate issues a new token.
+//
+// Parameters:
+//   - name: describes the holder of the token.
+//   - scopes: the permissions granted by the token.
+//
+// Returns:
+//   - string: the token, to be handed to its holder; it cannot be recovered later.
+//   - Token: the description of the token to be stored.
+//   - error: if the random source fails.
+func Generate(name string, scopes []Scope) (string, Token, error) {
+	const op = "apitoken.Generate"
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", Token{}, fmt.Errorf("%s: %w", op, err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", Token{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token := Prefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, Token{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Hash:      Hash(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,116 @@
+package apitoken
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []Scope
+		wantErr bool
+	}{
+		{value: "read", want: []Scope{ScopeRead}},
+		{value: "read, WRITE,read", want: []Scope{ScopeRead, ScopeWrite}},
+		{value: "admin", want: []Scope{ScopeAdmin}},
+		{value: "", wantErr: true},
+		{value: "read,delete", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseScopes(tt.value)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidScope)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestToken_Allows(t *testing.T) {
+	reader := Token{Scopes: []Scope{ScopeRead}}
+	require.True(t, reader.Allows(ScopeRead))
+	require.False(t, reader.Allows(ScopeWrite))
+	require.False(t, reader.Allows(ScopeAdmin))
+
+	admin := Token{Scopes: []Scope{ScopeAdmin}}
+	require.True(t, admin.Allows(ScopeRead))
+	require.True(t, admin.Allows(ScopeWrite))
+	require.True(t, admin.Allows(ScopeAdmin))
+}
+
+func TestGenerate(t *testing.T) {
+	secret, token, err := Generate("agent", []Scope{ScopeWrite})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(secret, Prefix))
+	require.Equal(t, Hash(secret), token.Hash)
+	require.NotContains(t, token.Hash, secret)
+	require.Len(t, token.ID, 16)
+
+	other, _, err := Generate("agent", []Scope{ScopeWrite})
+	require.NoError(t, err)
+	require.NotEqual(t, secret, other)
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	server := NewFile(path)
+	cli := NewFile(path)
+
+	_, err := server.Lookup(ctx, Hash("missing"))
+	require.ErrorIs(t, err, ErrNotFound, "a missing file holds no tokens")
+
+	secret, token, err := Generate("agent", []Scope{ScopeWrite})
+	require.NoError(t, err)
+	require.NoError(t, cli.Add(ctx, token))
+	require.Error(t, cli.Add(ctx, token), "a token is added once")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	got, err := server.Lookup(ctx, Hash(secret))
+	require.NoError(t, err, "a token added by another process is found")
+	require.Equal(t, token.ID, got.ID)
+	require.Equal(t, []Scope{ScopeWrite}, got.Scopes)
+
+	tokens, err := cli.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+
+	require.NoError(t, cli.Revoke(ctx, token.ID))
+	require.ErrorIs(t, cli.Revoke(ctx, token.ID), ErrNotFound)
+	_, err = server.Lookup(ctx, Hash(secret))
+	require.ErrorIs(t, err, ErrNotFound, "a revoked token is rejected")
+}
+
+func TestFromAuthorization(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{value: "Bearer mt_abc", want: "mt_abc", wantOK: true},
+		{value: "bearer  mt_abc ", want: "mt_abc", wantOK: true},
+		{value: "Basic dXNlcjpwYXNz"},
+		{value: "Bearer "},
+		{value: "mt_abc"},
+		{value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := FromAuthorization(tt.value)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
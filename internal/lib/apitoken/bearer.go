@@ -0,0 +1,14 @@
+package apitoken
+
+import "strings"
+
+// FromAuthorization returns the token of an "Authorization: Bearer <token>" header value.
+// It reports false if the value is not a bearer credential.
+func FromAuthorization(value string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(value), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
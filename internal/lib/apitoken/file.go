@@ -0,0 +1,171 @@
+package apitoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File is a Store keeping the tokens in a JSON file. The file is read again when it changes,
+// so that tokens added or revoked with the CLI take effect without restarting the server.
+type File struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	tokens  []Token
+}
+
+// NewFile creates a Store backed by the file at path. A missing file holds no tokens.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Lookup returns the token with the given hash.
+//
+// Parameters:
+//   - ctx: unused, the file is read synchronously.
+//   - hash: the hash of the token.
+//
+// Returns:
+//   - Token: the token.
+//   - error: ErrNotFound if there is no such token, or an error reading the file.
+func (f *File) Lookup(_ context.Context, hash string) (Token, error) {
+	const op = "apitoken.File.Lookup"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return Token{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, t := range f.tokens {
+		if t.Hash == hash {
+			return t, nil
+		}
+	}
+	return Token{}, ErrNotFound
+}
+
+// Add appends the token to the file.
+func (f *File) Add(_ context.Context, token Token) error {
+	const op = "apitoken.File.Add"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, t := range f.tokens {
+		if t.ID == token.ID || t.Hash == token.Hash {
+			return fmt.Errorf("%s: token %s already exists", op, token.ID)
+		}
+	}
+	tokens := append(append([]Token(nil), f.tokens...), token)
+	if err := f.save(tokens); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// List returns the tokens of the file.
+func (f *File) List(_ context.Context) ([]Token, error) {
+	const op = "apitoken.File.List"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return append([]Token(nil), f.tokens...), nil
+}
+
+// Revoke removes the token with the given ID from the file.
+func (f *File) Revoke(_ context.Context, id string) error {
+	const op = "apitoken.File.Revoke"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tokens := make([]Token, 0, len(f.tokens))
+	for _, t := range f.tokens {
+		if t.ID != id {
+			tokens = append(tokens, t)
+		}
+	}
+	if len(tokens) == len(f.tokens) {
+		return ErrNotFound
+	}
+	if err := f.save(tokens); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// load reads the file if it changed since it was last read.
+func (f *File) load() error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		f.tokens, f.modTime, f.size = nil, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size && f.tokens != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	tokens := []Token{}
+	if err = json.Unmarshal(data, &tokens); err != nil {
+		return err
+	}
+	f.tokens, f.modTime, f.size = tokens, info.ModTime(), info.Size()
+	return nil
+}
+
+// save replaces the file with the tokens. The file is written next to the old one and
+// renamed over it, so that a reader never sees it half-written.
+func (f *File) save(tokens []Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp already restricts the file to its owner, the hashes are not for everyone.
+	if err = os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+	// The next load reads the new file.
+	f.modTime, f.size = time.Time{}, 0
+	f.tokens = nil
+	return nil
+}
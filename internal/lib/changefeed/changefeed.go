@@ -0,0 +1,128 @@
+// Package changefeed fans out the changes made by a storage to subscribers.
+// Publishing never blocks the write path: every subscriber has a bounded buffer,
+// and a subscriber that falls behind is dropped instead of slowing down ingestion.
+package changefeed
+
+import (
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+// DefaultBuffer is the number of changes a subscriber may lag behind before it is dropped.
+const DefaultBuffer = 256
+
+// Filter selects the changes delivered to a subscriber. Empty fields match everything.
+type Filter struct {
+	Type   string // Type is the metric type, gauge or counter.
+	Prefix string // Prefix is the beginning of the metric name.
+}
+
+// Match reports whether the change passes the filter.
+func (f Filter) Match(c storage.Change) bool {
+	return (f.Type == "" || f.Type == c.Type) && strings.HasPrefix(c.Name, f.Prefix)
+}
+
+// Subscription receives the changes matching its filter.
+type Subscription struct {
+	filter Filter
+	ch     chan storage.Change
+}
+
+// C returns the channel of changes. It is closed when the subscriber is dropped or unsubscribed,
+// so a channel closed before Unsubscribe means the subscriber was too slow.
+func (s *Subscription) C() <-chan storage.Change {
+	return s.ch
+}
+
+// Hub implements storage.Notifier and delivers the changes to the subscribers.
+type Hub struct {
+	buffer int
+	log    *zap.Logger
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// New creates a Hub.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - buffer: the buffer of every subscriber, DefaultBuffer if not positive.
+//
+// Returns:
+//   - *Hub: the hub, passed to the storage as its notifier.
+func New(log *zap.Logger, buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{
+		buffer: buffer,
+		log:    log.With(zap.String("component", "lib/changefeed")),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber for the changes matching filter.
+// The subscription must be released with Unsubscribe.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	s := &Subscription{filter: filter, ch: make(chan storage.Change, h.buffer)}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Unsubscribe removes the subscriber and closes its channel. It is safe to call it
+// for a subscriber that has already been dropped.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// Notify delivers the changes to every matching subscriber without blocking.
+// A subscriber whose buffer is full is removed and its channel closed.
+func (h *Hub) Notify(changes []storage.Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		for _, c := range changes {
+			if !s.filter.Match(c) {
+				continue
+			}
+			if !send(s.ch, c) {
+				h.log.Warn("Dropping slow subscriber", zap.String("type", s.filter.Type), zap.String("prefix", s.filter.Prefix))
+				delete(h.subs, s)
+				close(s.ch)
+				break
+			}
+		}
+	}
+}
+
+// send puts the change into the channel if there is room for it.
+func send(ch chan storage.Change, c storage.Change) bool {
+	select {
+	case ch <- c:
+		return true
+	default:
+		return false
+	}
+}
+
+// Len returns the number of subscribers.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
@@ -0,0 +1,61 @@
+package changefeed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+func TestHub_Filter(t *testing.T) {
+	h := New(zap.NewNop(), 10)
+	all := h.Subscribe(Filter{})
+	counters := h.Subscribe(Filter{Type: "counter", Prefix: "Poll"})
+	defer h.Unsubscribe(all)
+	defer h.Unsubscribe(counters)
+
+	h.Notify([]storage.Change{
+		{Type: "gauge", Name: "Alloc", Value: "1"},
+		{Type: "counter", Name: "PollCount", Value: "5"},
+		{Type: "counter", Name: "Requests", Value: "2"},
+	})
+
+	require.Len(t, all.C(), 3)
+	require.Len(t, counters.C(), 1)
+	require.Equal(t, storage.Change{Type: "counter", Name: "PollCount", Value: "5"}, <-counters.C())
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	h := New(zap.NewNop(), 2)
+	slow := h.Subscribe(Filter{})
+	fast := h.Subscribe(Filter{})
+	defer h.Unsubscribe(fast)
+
+	for i := 0; i < 3; i++ {
+		h.Notify([]storage.Change{{Type: "gauge", Name: "Alloc", Value: "1"}})
+		<-fast.C()
+	}
+
+	// The slow subscriber got the buffered changes and then its channel was closed.
+	require.Equal(t, 1, h.Len())
+	n := 0
+	for range slow.C() {
+		n++
+	}
+	require.Equal(t, 2, n)
+	h.Unsubscribe(slow)
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	h := New(zap.NewNop(), 0)
+	s := h.Subscribe(Filter{})
+	h.Unsubscribe(s)
+	h.Unsubscribe(s)
+
+	_, ok := <-s.C()
+	require.False(t, ok)
+	require.Equal(t, 0, h.Len())
+	h.Notify([]storage.Change{{Type: "gauge", Name: "Alloc", Value: "1"}})
+}
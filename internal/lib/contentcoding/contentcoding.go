@@ -0,0 +1,157 @@
+// Package contentcoding implements the HTTP content codings understood by the server and
+// the agent: gzip, deflate, zstd and brotli. Both sides use it, so an encoding added here
+// can be sent by the agent and decoded by the server.
+package contentcoding
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings, as written in the Content-Encoding header.
+const (
+	Identity = "identity"
+	Gzip     = "gzip"
+	Deflate  = "deflate"
+	Zstd     = "zstd"
+	Brotli   = "br"
+)
+
+// zstdMaxWindow bounds the memory a zstd frame may ask the decoder for.
+const zstdMaxWindow = 8 << 20
+
+// ErrUnsupported is returned for a content coding this package does not implement.
+var ErrUnsupported = errors.New("unsupported content coding")
+
+// Supported returns the content codings that can be decoded, in order of preference.
+func Supported() []string {
+	return []string{Zstd, Brotli, Gzip, Deflate}
+}
+
+// Parse normalizes the name of a content coding and checks that it is supported.
+// The names are case-insensitive and "x-gzip" is an alias of gzip.
+func Parse(name string) (string, error) {
+	coding := strings.ToLower(strings.TrimSpace(name))
+	switch coding {
+	case "x-gzip":
+		return Gzip, nil
+	case Identity, Gzip, Deflate, Zstd, Brotli:
+		return coding, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnsupported, name)
+}
+
+// NewReader returns a reader decoding r.
+//
+// Parameters:
+//   - coding: the content coding of r.
+//   - r: the encoded data.
+//
+// Returns:
+//   - io.ReadCloser: the decoded data; closing it releases the decoder, not r.
+//   - error: ErrUnsupported for an unknown coding, or the error of a malformed header.
+func NewReader(coding string, r io.Reader) (io.ReadCloser, error) {
+	switch coding {
+	case Identity:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Deflate:
+		// The deflate coding of HTTP is the zlib format.
+		return zlib.NewReader(r)
+	case Zstd:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader{d}, nil
+	case Brotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupported, coding)
+}
+
+// zstdReader adapts zstd.Decoder, whose Close returns nothing, to io.ReadCloser.
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (z zstdReader) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// NewWriter returns a writer encoding into w.
+//
+// Parameters:
+//   - coding: the content coding.
+//   - w: the destination of the encoded data.
+//   - level: the compression level in the native range of the coding: 1-9 for gzip and
+//     deflate, 1-22 for zstd and 0-11 for brotli. 0 selects the fastest level of every coding.
+//
+// Returns:
+//   - io.WriteCloser: the writer; Close flushes the encoded data, it does not close w.
+//   - error: ErrUnsupported for an unknown coding, or an invalid level.
+func NewWriter(coding string, w io.Writer, level int) (io.WriteCloser, error) {
+	switch coding {
+	case Gzip:
+		if level == 0 {
+			level = gzip.BestSpeed
+		}
+		return gzip.NewWriterLevel(w, level)
+	case Deflate:
+		if level == 0 {
+			level = flate.BestSpeed
+		}
+		return zlib.NewWriterLevel(w, level)
+	case Zstd:
+		if level < 0 || level > 22 {
+			return nil, fmt.Errorf("invalid zstd level %d", level)
+		}
+		zl := zstd.SpeedFastest
+		if level > 0 {
+			zl = zstd.EncoderLevelFromZstd(level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zl), zstd.WithEncoderConcurrency(1))
+	case Brotli:
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			return nil, fmt.Errorf("invalid brotli level %d", level)
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupported, coding)
+}
+
+// Encode compresses data in one call.
+//
+// Parameters:
+//   - coding: the content coding.
+//   - level: the compression level, see NewWriter.
+//   - data: the data to compress.
+//
+// Returns:
+//   - []byte: the encoded data.
+//   - error: if the coding or level is invalid.
+func Encode(coding string, level int, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewWriter(coding, &buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
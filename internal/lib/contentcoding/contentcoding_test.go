@@ -0,0 +1,100 @@
+package contentcoding
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "gzip", want: Gzip},
+		{name: " GZIP ", want: Gzip},
+		{name: "x-gzip", want: Gzip},
+		{name: "deflate", want: Deflate},
+		{name: "Zstd", want: Zstd},
+		{name: "br", want: Brotli},
+		{name: "identity", want: Identity},
+		{name: "compress", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrUnsupported)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"id":"Alloc","type":"gauge","value":123456},`, 100))
+
+	tests := []struct {
+		coding string
+		level  int
+	}{
+		{coding: Gzip},
+		{coding: Gzip, level: 9},
+		{coding: Deflate},
+		{coding: Deflate, level: 6},
+		{coding: Zstd},
+		{coding: Zstd, level: 22},
+		{coding: Brotli},
+		{coding: Brotli, level: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.coding, func(t *testing.T) {
+			encoded, err := Encode(tt.coding, tt.level, data)
+			require.NoError(t, err)
+			require.Less(t, len(encoded), len(data))
+
+			r, err := NewReader(tt.coding, bytes.NewReader(encoded))
+			require.NoError(t, err)
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			require.Equal(t, data, got)
+		})
+	}
+}
+
+func TestEncode_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		coding string
+		level  int
+	}{
+		{name: "unknown coding", coding: "lz4"},
+		{name: "identity", coding: Identity},
+		{name: "gzip level", coding: Gzip, level: 10},
+		{name: "deflate level", coding: Deflate, level: -5},
+		{name: "zstd level", coding: Zstd, level: 23},
+		{name: "brotli level", coding: Brotli, level: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Encode(tt.coding, tt.level, []byte("data"))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	_, err := NewReader("lz4", bytes.NewReader(nil))
+	require.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = NewReader(Gzip, strings.NewReader("not gzip"))
+	require.Error(t, err)
+}
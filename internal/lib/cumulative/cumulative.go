@@ -0,0 +1,153 @@
+// Package cumulative converts cumulative counter values into the increases
+// expected by the storage, which adds every stored counter value to the previous one.
+package cumulative
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIdleTimeout is how long a series is remembered without new samples.
+	DefaultIdleTimeout = time.Hour
+
+	// DefaultMaxSeries is the number of series remembered at most.
+	DefaultMaxSeries = 100000
+)
+
+// Option configures optional behaviour of the Tracker.
+type Option func(*settings)
+
+type settings struct {
+	idleTimeout time.Duration
+	maxSeries   int
+}
+
+// WithIdleTimeout sets how long a series is remembered without new samples.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *settings) {
+		if d > 0 {
+			s.idleTimeout = d
+		}
+	}
+}
+
+// WithMaxSeries sets the number of series remembered at most; the least recently seen are forgotten first.
+func WithMaxSeries(n int) Option {
+	return func(s *settings) {
+		if n > 0 {
+			s.maxSeries = n
+		}
+	}
+}
+
+// state is the last committed value of a series.
+type state struct {
+	value int64
+	seen  time.Time
+}
+
+// Tracker remembers the last cumulative value of every counter series.
+//
+// The first sample of a series only sets its baseline: the Tracker does not know how much
+// of the total was already stored before, for example by this server before a restart or
+// from an exporter that reconnected, so counting the whole total would count it twice.
+// Series without samples for the idle timeout are forgotten and start from a new baseline.
+type Tracker struct {
+	mu        sync.Mutex
+	last      map[string]state
+	settings  settings
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates an empty Tracker.
+func New(opts ...Option) *Tracker {
+	s := settings{
+		idleTimeout: DefaultIdleTimeout,
+		maxSeries:   DefaultMaxSeries,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return &Tracker{
+		last:     make(map[string]state),
+		settings: s,
+		now:      time.Now,
+	}
+}
+
+// Apply runs fn with a delta function that returns the increase of a series since the
+// last committed value. The first value of a series returns 0 and becomes the baseline.
+// A value lower than the previous one is treated as a counter reset and returned as is.
+// The new values are committed only if fn returns nil, so that a failed write can be
+// retried without losing or double counting increases.
+// Calls are serialized.
+//
+// Parameters:
+//   - fn: converts and stores a batch, calling delta for every cumulative value.
+//
+// Returns:
+//   - error: the error returned by fn.
+func (t *Tracker) Apply(fn func(delta func(key string, current int64) int64) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make(map[string]int64)
+	delta := func(key string, current int64) int64 {
+		previous, ok := pending[key]
+		if !ok {
+			var last state
+			last, ok = t.last[key]
+			previous = last.value
+		}
+		pending[key] = current
+		if !ok {
+			return 0
+		}
+		if current < previous {
+			return current
+		}
+		return current - previous
+	}
+
+	if err := fn(delta); err != nil {
+		return err
+	}
+
+	now := t.now()
+	for key, v := range pending {
+		t.last[key] = state{value: v, seen: now}
+	}
+	t.evict(now)
+	return nil
+}
+
+// evict forgets idle series and, above the limit, the least recently seen ones.
+// Idle series are looked for at most once per idle timeout.
+func (t *Tracker) evict(now time.Time) {
+	if now.Sub(t.lastSweep) >= t.settings.idleTimeout {
+		t.lastSweep = now
+		for key, s := range t.last {
+			if now.Sub(s.seen) >= t.settings.idleTimeout {
+				delete(t.last, key)
+			}
+		}
+	}
+
+	excess := len(t.last) - t.settings.maxSeries
+	if excess <= 0 {
+		return
+	}
+	keys := make([]string, 0, len(t.last))
+	for key := range t.last {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.last[keys[i]].seen.Before(t.last[keys[j]].seen)
+	})
+	for _, key := range keys[:excess] {
+		delete(t.last, key)
+	}
+}
@@ -0,0 +1,122 @@
+package cumulative
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker_Apply(t *testing.T) {
+	tr := New()
+	apply := func(current int64, fail bool) (int64, error) {
+		var got int64
+		err := tr.Apply(func(delta func(string, int64) int64) error {
+			got = delta("requests", current)
+			if fail {
+				return errors.New("storage is down")
+			}
+			return nil
+		})
+		return got, err
+	}
+
+	got, err := apply(10, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), got, "the first value is a baseline")
+
+	_, err = apply(15, true)
+	require.Error(t, err)
+
+	got, err = apply(15, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), got)
+
+	got, err = apply(3, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), got, "a lower value is a reset")
+}
+
+func TestTracker_FirstSampleFailed(t *testing.T) {
+	tr := New()
+	var deltas []int64
+	require.Error(t, tr.Apply(func(delta func(string, int64) int64) error {
+		deltas = append(deltas, delta("a", 100))
+		return errors.New("storage is down")
+	}))
+	require.NoError(t, tr.Apply(func(delta func(string, int64) int64) error {
+		deltas = append(deltas, delta("a", 120))
+		return nil
+	}))
+	require.NoError(t, tr.Apply(func(delta func(string, int64) int64) error {
+		deltas = append(deltas, delta("a", 130))
+		return nil
+	}))
+	require.Equal(t, []int64{0, 0, 10}, deltas, "an uncommitted baseline is not kept")
+}
+
+func TestTracker_Restart(t *testing.T) {
+	// A server that restarts forgets the baselines, while the exporter keeps its totals.
+	before := New()
+	var stored int64
+	for _, total := range []int64{500, 520, 540} {
+		require.NoError(t, before.Apply(func(delta func(string, int64) int64) error {
+			stored += delta("requests", total)
+			return nil
+		}))
+	}
+	require.Equal(t, int64(40), stored)
+
+	after := New()
+	for _, total := range []int64{560, 580} {
+		require.NoError(t, after.Apply(func(delta func(string, int64) int64) error {
+			stored += delta("requests", total)
+			return nil
+		}))
+	}
+	require.Equal(t, int64(60), stored, "the lifetime total is not counted again after a restart")
+}
+
+func TestTracker_SameSeriesTwice(t *testing.T) {
+	tr := New()
+	var deltas []int64
+	require.NoError(t, tr.Apply(func(delta func(string, int64) int64) error {
+		deltas = append(deltas, delta("a", 4), delta("a", 9))
+		return nil
+	}))
+	require.Equal(t, []int64{0, 5}, deltas)
+}
+
+func TestTracker_Eviction(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := New(WithIdleTimeout(time.Minute), WithMaxSeries(2))
+	tr.now = func() time.Time { return now }
+
+	apply := func(values map[string]int64) map[string]int64 {
+		deltas := make(map[string]int64)
+		require.NoError(t, tr.Apply(func(delta func(string, int64) int64) error {
+			for key, v := range values {
+				deltas[key] = delta(key, v)
+			}
+			return nil
+		}))
+		return deltas
+	}
+
+	apply(map[string]int64{"a": 10, "b": 10})
+	now = now.Add(30 * time.Second)
+	apply(map[string]int64{"b": 20})
+
+	now = now.Add(30 * time.Second)
+	apply(map[string]int64{"c": 5})
+	require.Len(t, tr.last, 2, "the idle series is forgotten")
+	require.NotContains(t, tr.last, "a")
+
+	now = now.Add(time.Second)
+	apply(map[string]int64{"d": 5})
+	require.Len(t, tr.last, 2, "the number of series is capped")
+	require.NotContains(t, tr.last, "b", "the least recently seen series is forgotten first")
+
+	require.Equal(t, map[string]int64{"a": 0}, apply(map[string]int64{"a": 30}), "a forgotten series starts from a new baseline")
+}
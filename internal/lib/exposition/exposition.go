@@ -0,0 +1,197 @@
+// Package exposition renders metrics in the Prometheus text exposition and OpenMetrics formats.
+package exposition
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/series"
+)
+
+const (
+	// ContentTypeText is the media type of the Prometheus text exposition format.
+	ContentTypeText = "text/plain; version=0.0.4; charset=utf-8"
+
+	// ContentTypeOpenMetrics is the media type of the OpenMetrics text format.
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Sample is a single metric prepared for exposition.
+type Sample struct {
+	Name   string            // Name is the sanitized metric name.
+	Type   string            // Type is format.Gauge or format.Counter.
+	Value  string            // Value is the metric value as stored.
+	Labels map[string]string // Labels are rendered in the sample line, sorted by name.
+}
+
+// NewSamples converts gauges and counters returned by the storage into samples
+// sorted by name. Labels encoded in stored names are passed through to the samples.
+// Names are sanitized and, if two series collide after sanitization or one name is
+// used by both a gauge and a counter, only the first one is kept.
+//
+// Parameters:
+//   - gauges: gauge metrics as name and value pairs.
+//   - counters: counter metrics as name and value pairs.
+//
+// Returns:
+//   - []Sample: the samples ready to be written.
+func NewSamples(gauges [][]string, counters [][]string) []Sample {
+	samples := make([]Sample, 0, len(gauges)+len(counters))
+	keys := make([]string, 0, len(gauges)+len(counters))
+	seen := make(map[string]struct{}, len(gauges)+len(counters))
+	families := make(map[string]string)
+
+	add := func(typ string, metrics [][]string) {
+		for _, m := range metrics {
+			if len(m) < 2 {
+				continue
+			}
+			name, labels := series.Parse(m[0])
+			name = SanitizeName(name)
+			if t, ok := families[name]; ok && t != typ {
+				continue
+			}
+			key := series.Format(name, labels)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			families[name] = typ
+			samples = append(samples, Sample{Name: name, Type: typ, Value: m[1], Labels: labels})
+			keys = append(keys, key)
+		}
+	}
+	add(format.Gauge, gauges)
+	add(format.Counter, counters)
+
+	sort.Sort(byKey{samples: samples, keys: keys})
+	return samples
+}
+
+// byKey sorts samples by name and then by labels.
+type byKey struct {
+	samples []Sample
+	keys    []string
+}
+
+func (b byKey) Len() int { return len(b.samples) }
+
+func (b byKey) Less(i, j int) bool {
+	if b.samples[i].Name != b.samples[j].Name {
+		return b.samples[i].Name < b.samples[j].Name
+	}
+	return b.keys[i] < b.keys[j]
+}
+
+func (b byKey) Swap(i, j int) {
+	b.samples[i], b.samples[j] = b.samples[j], b.samples[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// SanitizeName converts an arbitrary metric name into a valid Prometheus metric name.
+// Characters outside [a-zA-Z0-9_:] are replaced with an underscore and a name
+// starting with a digit is prefixed with an underscore.
+func SanitizeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	b.Grow(len(name) + 1)
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+			b.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// sanitizeLabelName converts an arbitrary label name into a valid Prometheus label name.
+func sanitizeLabelName(name string) string {
+	return strings.ReplaceAll(SanitizeName(name), ":", "_")
+}
+
+// WriteText writes samples in the Prometheus text exposition format.
+func WriteText(w io.Writer, samples []Sample) error {
+	return write(w, samples, false)
+}
+
+// WriteOpenMetrics writes samples in the OpenMetrics text format.
+// Counter samples get the mandatory "_total" suffix and the output is terminated with "# EOF".
+func WriteOpenMetrics(w io.Writer, samples []Sample) error {
+	return write(w, samples, true)
+}
+
+// write renders the samples. Samples of a metric family must be adjacent,
+// the TYPE line is written before the first one.
+func write(w io.Writer, samples []Sample, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	previous := ""
+	for i, s := range samples {
+		family, name := s.Name, s.Name
+		if openMetrics && s.Type == format.Counter {
+			family = strings.TrimSuffix(family, "_total")
+			name = family + "_total"
+		}
+		if i == 0 || family != previous {
+			bw.WriteString("# TYPE ")
+			bw.WriteString(family)
+			bw.WriteByte(' ')
+			bw.WriteString(s.Type)
+			bw.WriteByte('\n')
+			previous = family
+		}
+
+		bw.WriteString(name)
+		writeLabels(bw, s.Labels)
+		bw.WriteByte(' ')
+		bw.WriteString(s.Value)
+		bw.WriteByte('\n')
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// writeLabels renders the label set in braces, sorted by label name.
+func writeLabels(bw *bufio.Writer, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(sanitizeLabelName(name))
+		bw.WriteString(`="`)
+		bw.WriteString(escapeLabelValue(labels[name]))
+		bw.WriteByte('"')
+	}
+	bw.WriteByte('}')
+}
+
+// labelValueReplacer escapes backslashes, double quotes and line feeds in label values.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes a label value for the text formats.
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
@@ -0,0 +1,47 @@
+package exposition
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"Alloc":        "Alloc",
+		"http:req_ok":  "http:req_ok",
+		"cpu.usage-1":  "cpu_usage_1",
+		"1st":          "_1st",
+		"":             "_",
+		"температура":  "___________",
+		"with space 2": "with_space_2",
+	}
+	for in, want := range tests {
+		require.Equal(t, want, SanitizeName(in), in)
+	}
+}
+
+func TestWriteText_Labels(t *testing.T) {
+	var b strings.Builder
+	err := WriteText(&b, []Sample{{
+		Name:   "requests",
+		Type:   "counter",
+		Value:  "3",
+		Labels: map[string]string{"path": `/a"b`, "host.name": "x\ny"},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "# TYPE requests counter\nrequests{host_name=\"x\\ny\",path=\"/a\\\"b\"} 3\n", b.String())
+}
+
+func TestNewSamples_StoredLabels(t *testing.T) {
+	counters := [][]string{
+		{`http_requests_total{code="500"}`, "2"},
+		{`http_requests_total{code="200"}`, "7"},
+	}
+	var b strings.Builder
+	require.NoError(t, WriteText(&b, NewSamples(nil, counters)))
+	require.Equal(t, "# TYPE http_requests_total counter\n"+
+		"http_requests_total{code=\"200\"} 7\n"+
+		"http_requests_total{code=\"500\"} 2\n", b.String())
+}
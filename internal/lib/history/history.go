@@ -0,0 +1,104 @@
+// Package history keeps recent values of the metrics in memory, so that expressions
+// such as rate can be evaluated over a time window. The storages keep only the
+// latest value, the history is filled from their changes.
+package history
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+// DefaultRetention is how long the samples are kept.
+const DefaultRetention = time.Hour
+
+// maxSamples bounds the samples kept for one metric, whatever the write rate.
+const maxSamples = 4096
+
+// Sample is the value of a metric at a moment.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+type key struct {
+	typ, name string
+}
+
+// Recorder implements storage.Notifier and records the changes as samples.
+type Recorder struct {
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.RWMutex
+	series map[key][]Sample
+}
+
+// New creates a Recorder.
+//
+// Parameters:
+//   - retention: how long the samples are kept, DefaultRetention if not positive.
+//
+// Returns:
+//   - *Recorder: the recorder, passed to the storage as a notifier.
+func New(retention time.Duration) *Recorder {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Recorder{
+		retention: retention,
+		now:       time.Now,
+		series:    make(map[key][]Sample),
+	}
+}
+
+// Notify records the changes with the current time. Samples older than the retention
+// are discarded on the way.
+func (r *Recorder) Notify(changes []storage.Change) {
+	now := r.now()
+	cutoff := now.Add(-r.retention)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range changes {
+		v, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			continue
+		}
+		k := key{c.Type, c.Name}
+		samples := append(r.series[k], Sample{Time: now, Value: v})
+		skip := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(cutoff) })
+		if n := len(samples) - skip; n > maxSamples {
+			skip = len(samples) - maxSamples
+		}
+		if skip > 0 {
+			samples = append(samples[:0], samples[skip:]...)
+		}
+		r.series[k] = samples
+	}
+}
+
+// Range returns the samples of a metric recorded within the window ending now,
+// oldest first.
+//
+// Parameters:
+//   - typ: the type of the metric (gauge or counter).
+//   - name: the name of the metric.
+//   - window: the length of the window.
+//
+// Returns:
+//   - []Sample: a copy of the samples, empty if there is no history.
+func (r *Recorder) Range(typ, name string, window time.Duration) []Sample {
+	from := r.now().Add(-window)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	samples := r.series[key{typ, name}]
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(from) })
+	return append([]Sample(nil), samples[i:]...)
+}
@@ -0,0 +1,34 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+func TestRecorder(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := New(time.Minute)
+	r.now = func() time.Time { return now }
+
+	for i, v := range []string{"1", "2", "3"} {
+		now = now.Add(time.Duration(i) * 30 * time.Second)
+		r.Notify([]storage.Change{
+			{Type: format.Counter, Name: "PollCount", Value: v},
+			{Type: format.Gauge, Name: "Alloc", Value: "not a number"},
+		})
+	}
+
+	// The first sample is older than the retention and has been discarded.
+	require.Equal(t, []Sample{
+		{Time: now.Add(-60 * time.Second), Value: 2},
+		{Time: now, Value: 3},
+	}, r.Range(format.Counter, "PollCount", time.Hour))
+	require.Equal(t, []Sample{{Time: now, Value: 3}}, r.Range(format.Counter, "PollCount", 30*time.Second))
+	require.Empty(t, r.Range(format.Gauge, "PollCount", time.Hour))
+	require.Empty(t, r.Range(format.Gauge, "Alloc", time.Hour))
+}
@@ -0,0 +1,171 @@
+// Package idempotency remembers the responses to requests carrying an Idempotency-Key
+// header, so that a request retried by a client after a lost response is answered
+// with the original result instead of being applied again.
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Header is the request header carrying the key chosen by the client.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on responses repeated from the store.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// MaxKeyLength bounds the length of a key.
+const MaxKeyLength = 255
+
+// DefaultCapacity is the number of keys remembered by default.
+const DefaultCapacity = 10000
+
+// DefaultTTL is how long a key is remembered.
+const DefaultTTL = 24 * time.Hour
+
+// Response is the remembered result of a request.
+type Response struct {
+	Fingerprint string // Fingerprint identifies the body of the request, a key reused for another body is rejected.
+	Status      int    // Status is the HTTP status code.
+	ContentType string // ContentType is the value of the Content-Type header.
+	Hash        string // Hash is the value of the HashSHA256 header, may be empty.
+	Body        []byte // Body is the body of the response.
+}
+
+// Store keeps the responses by key.
+type Store interface {
+	// Get returns the response remembered for the key and whether there is one.
+	Get(ctx context.Context, key string) (Response, bool, error)
+
+	// Put remembers the response for the key.
+	Put(ctx context.Context, key string, resp Response) error
+}
+
+// entry is a remembered response with its key and the time it was stored.
+type entry struct {
+	key     string
+	resp    Response
+	created time.Time
+}
+
+// Memory is a Store keeping a bounded number of responses in memory. When it is full,
+// the least recently stored key is forgotten.
+type Memory struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	order *list.List // order holds *entry from the newest to the oldest.
+	keys  map[string]*list.Element
+}
+
+// NewMemory creates a Memory store.
+//
+// Parameters:
+//   - capacity: the number of keys remembered, DefaultCapacity if not positive.
+//   - ttl: how long a key is remembered, DefaultTTL if not positive.
+//
+// Returns:
+//   - *Memory: the store.
+func NewMemory(capacity int, ttl time.Duration) *Memory {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Memory{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		keys:     make(map[string]*list.Element),
+	}
+}
+
+// Get implements Store.
+func (m *Memory) Get(_ context.Context, key string) (Response, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.keys[key]
+	if !ok {
+		return Response{}, false, nil
+	}
+	e := el.Value.(*entry)
+	if m.now().Sub(e.created) > m.ttl {
+		m.remove(el)
+		return Response{}, false, nil
+	}
+	return e.resp, true, nil
+}
+
+// Put implements Store.
+func (m *Memory) Put(_ context.Context, key string, resp Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.keys[key]; ok {
+		m.remove(el)
+	}
+	m.keys[key] = m.order.PushFront(&entry{key: key, resp: resp, created: m.now()})
+	for m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Len returns the number of remembered keys.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *Memory) remove(el *list.Element) {
+	delete(m.keys, el.Value.(*entry).key)
+	m.order.Remove(el)
+}
+
+// Locker serializes the requests with the same key, so that a retry arriving while
+// the original request is still being handled waits for its result.
+type Locker struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu      sync.Mutex
+	waiters int
+}
+
+// NewLocker creates a Locker.
+func NewLocker() *Locker {
+	return &Locker{locks: make(map[string]*keyLock)}
+}
+
+// Lock acquires the lock of the key and returns the function releasing it.
+func (l *Locker) Lock(key string) (unlock func()) {
+	l.mu.Lock()
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyLock{}
+		l.locks[key] = kl
+	}
+	kl.waiters++
+	l.mu.Unlock()
+
+	kl.mu.Lock()
+	return func() {
+		kl.mu.Unlock()
+		l.mu.Lock()
+		kl.waiters--
+		if kl.waiters == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
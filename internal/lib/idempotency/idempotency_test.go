@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_GetPut(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2, time.Minute)
+
+	_, ok, err := m.Get(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	resp := Response{Fingerprint: "f", Status: 200, ContentType: "application/json", Body: []byte("[]")}
+	require.NoError(t, m.Put(ctx, "a", resp))
+	got, ok, err := m.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, resp, got)
+}
+
+func TestMemory_Capacity(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2, time.Minute)
+
+	require.NoError(t, m.Put(ctx, "a", Response{Status: 200}))
+	require.NoError(t, m.Put(ctx, "b", Response{Status: 200}))
+	require.NoError(t, m.Put(ctx, "c", Response{Status: 200}))
+	require.Equal(t, 2, m.Len())
+
+	_, ok, _ := m.Get(ctx, "a")
+	require.False(t, ok, "the oldest key is forgotten")
+	_, ok, _ = m.Get(ctx, "c")
+	require.True(t, ok)
+}
+
+func TestMemory_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemory(10, time.Minute)
+	m.now = func() time.Time { return now }
+
+	require.NoError(t, m.Put(ctx, "a", Response{Status: 200}))
+	now = now.Add(2 * time.Minute)
+
+	_, ok, _ := m.Get(ctx, "a")
+	require.False(t, ok)
+	require.Equal(t, 0, m.Len())
+}
+
+func TestLocker(t *testing.T) {
+	l := NewLocker()
+
+	var mu sync.Mutex
+	running := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := l.Lock("key")
+			defer unlock()
+
+			mu.Lock()
+			running++
+			require.Equal(t, 1, running)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	require.Empty(t, l.locks)
+}
@@ -0,0 +1,232 @@
+// Package lineprotocol parses the InfluxDB line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// Field values are floats by default, integers carry an "i" suffix, unsigned
+// integers a "u" suffix, booleans are t/true/f/false and strings are double-quoted.
+package lineprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSyntax is returned for lines that do not follow the line protocol.
+var ErrSyntax = errors.New("line protocol syntax error")
+
+// Field is a single field of a point. Value holds a float64, int64, uint64, bool or string.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Point is a parsed line.
+type Point struct {
+	Line        int // Line is the 1-based line number, set by Parse.
+	Measurement string
+	Tags        map[string]string
+	Fields      []Field
+	Timestamp   time.Time
+}
+
+// LineError describes a line that cannot be parsed.
+type LineError struct {
+	Line int   // Line is the 1-based line number in the body.
+	Err  error // Err is the parse error.
+}
+
+// Error implements the error interface.
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the parse error.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Precision returns the duration of a timestamp unit by its name as used by the
+// "precision" query parameter: ns, us, ms or s. An empty name means nanoseconds.
+func Precision(name string) (time.Duration, error) {
+	switch name {
+	case "", "ns", "n":
+		return time.Nanosecond, nil
+	case "us", "u":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown precision %q", ErrSyntax, name)
+	}
+}
+
+// Parse reads all lines from r. Empty lines and comments are skipped. Lines that
+// cannot be parsed are reported as LineError and do not stop parsing.
+//
+// Parameters:
+//   - r: the request body.
+//   - precision: the unit of the timestamps.
+//   - now: the timestamp of points without one.
+//
+// Returns:
+//   - []Point: the parsed points in the order of the lines.
+//   - []*LineError: the lines that cannot be parsed.
+//   - error: an error reading r.
+func Parse(r io.Reader, precision time.Duration, now time.Time) ([]Point, []*LineError, error) {
+	const op = "lib.lineprotocol.Parse"
+
+	var (
+		points []Point
+		errs   []*LineError
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		p, err := ParseLine(string(line), precision, now)
+		if err != nil {
+			errs = append(errs, &LineError{Line: n, Err: err})
+			continue
+		}
+		p.Line = n
+		points = append(points, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return points, errs, nil
+}
+
+// ParseLine parses a single line.
+func ParseLine(line string, precision time.Duration, now time.Time) (Point, error) {
+	var p Point
+
+	key, rest, ok := cutUnescaped(line, ' ', false)
+	if !ok {
+		return p, fmt.Errorf("%w: missing fields", ErrSyntax)
+	}
+	fields, ts, _ := cutUnescaped(rest, ' ', true)
+
+	parts := splitUnescaped(key, ',', false)
+	p.Measurement = unescape(parts[0])
+	if p.Measurement == "" {
+		return p, fmt.Errorf("%w: missing measurement", ErrSyntax)
+	}
+	for _, tag := range parts[1:] {
+		k, v, ok := cutUnescaped(tag, '=', false)
+		if !ok || k == "" || v == "" {
+			return p, fmt.Errorf("%w: invalid tag %q", ErrSyntax, tag)
+		}
+		if p.Tags == nil {
+			p.Tags = make(map[string]string, len(parts)-1)
+		}
+		p.Tags[unescape(k)] = unescape(v)
+	}
+
+	for _, field := range splitUnescaped(fields, ',', true) {
+		k, v, ok := cutUnescaped(field, '=', false)
+		if !ok || k == "" || v == "" {
+			return p, fmt.Errorf("%w: invalid field %q", ErrSyntax, field)
+		}
+		value, err := parseValue(v)
+		if err != nil {
+			return p, fmt.Errorf("%w: field %q: %w", ErrSyntax, unescape(k), err)
+		}
+		p.Fields = append(p.Fields, Field{Key: unescape(k), Value: value})
+	}
+	if len(p.Fields) == 0 {
+		return p, fmt.Errorf("%w: missing fields", ErrSyntax)
+	}
+
+	p.Timestamp = now
+	if ts = strings.TrimSpace(ts); ts != "" {
+		n, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("%w: invalid timestamp %q", ErrSyntax, ts)
+		}
+		p.Timestamp = time.Unix(0, n*int64(precision))
+	}
+	return p, nil
+}
+
+// parseValue converts a field value into its Go type.
+func parseValue(v string) (any, error) {
+	switch {
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return nil, errors.New("unterminated string")
+		}
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1]), nil
+	case strings.HasSuffix(v, "i"):
+		return strconv.ParseInt(v[:len(v)-1], 10, 64)
+	case strings.HasSuffix(v, "u"):
+		return strconv.ParseUint(v[:len(v)-1], 10, 64)
+	}
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// cutUnescaped splits s around the first sep that is not escaped with a backslash.
+// If quotes is true, separators inside double-quoted strings are ignored.
+func cutUnescaped(s string, sep byte, quotes bool) (string, string, bool) {
+	if i := indexUnescaped(s, sep, quotes); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return s, "", false
+}
+
+// splitUnescaped splits s around every sep that is not escaped with a backslash.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	for {
+		i := indexUnescaped(s, sep, quotes)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
+
+// indexUnescaped returns the index of the first unescaped sep in s, or -1.
+func indexUnescaped(s string, sep byte, quotes bool) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescaper removes backslashes before the characters escaped in names, tags and field keys.
+var unescaper = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\\`, `\`)
+
+// unescape removes escaping from a measurement, tag or field key.
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	return unescaper.Replace(s)
+}
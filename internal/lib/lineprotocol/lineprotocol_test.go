@@ -0,0 +1,81 @@
+package lineprotocol
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	now := time.Unix(100, 0)
+
+	tests := []struct {
+		name    string
+		line    string
+		want    Point
+		wantErr bool
+	}{
+		{
+			name: "tags, fields and timestamp",
+			line: `cpu,host=server01,region=us-west usage_idle=99.5,procs=12i,up=t 1465839830100400200`,
+			want: Point{
+				Measurement: "cpu",
+				Tags:        map[string]string{"host": "server01", "region": "us-west"},
+				Fields:      []Field{{Key: "usage_idle", Value: 99.5}, {Key: "procs", Value: int64(12)}, {Key: "up", Value: true}},
+				Timestamp:   time.Unix(0, 1465839830100400200),
+			},
+		},
+		{
+			name: "escaping and strings",
+			line: `disk\ io,path=C:\,\ data bytes=7u,msg="a \"quoted\" value, with space"`,
+			want: Point{
+				Measurement: "disk io",
+				Tags:        map[string]string{"path": "C:, data"},
+				Fields:      []Field{{Key: "bytes", Value: uint64(7)}, {Key: "msg", Value: `a "quoted" value, with space`}},
+				Timestamp:   now,
+			},
+		},
+		{name: "missing fields", line: "cpu,host=a", wantErr: true},
+		{name: "invalid tag", line: "cpu,host value=1", wantErr: true},
+		{name: "invalid field", line: "cpu value=abc", wantErr: true},
+		{name: "invalid integer", line: "cpu value=1.5i", wantErr: true},
+		{name: "invalid timestamp", line: "cpu value=1 soon", wantErr: true},
+		{name: "unterminated string", line: `cpu msg="abc`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line, time.Nanosecond, now)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrSyntax)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	body := "# comment\nmem used=1 1700000000\n\nbroken\nmem free=2i 1700000001\n"
+
+	points, errs, err := Parse(strings.NewReader(body), time.Second, time.Now())
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	require.Equal(t, 2, points[0].Line)
+	require.Equal(t, time.Unix(1700000000, 0), points[0].Timestamp)
+	require.Equal(t, 5, points[1].Line)
+	require.Len(t, errs, 1)
+	require.Equal(t, 4, errs[0].Line)
+}
+
+func TestPrecision(t *testing.T) {
+	d, err := Precision("ms")
+	require.NoError(t, err)
+	require.Equal(t, time.Millisecond, d)
+
+	_, err = Precision("h")
+	require.ErrorIs(t, err, ErrSyntax)
+}
@@ -0,0 +1,276 @@
+// Package prompb implements the subset of the Prometheus remote_write protocol
+// needed to ingest samples: the WriteRequest message and its snappy framing.
+// Messages are encoded and decoded directly on the protobuf wire format,
+// unknown fields such as exemplars and native histograms are skipped.
+package prompb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/klauspost/compress/s2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MetricType is the type of a metric family announced in the request metadata.
+type MetricType int32
+
+// Metric types of the remote_write protocol.
+const (
+	MetricTypeUnknown        MetricType = 0
+	MetricTypeCounter        MetricType = 1
+	MetricTypeGauge          MetricType = 2
+	MetricTypeHistogram      MetricType = 3
+	MetricTypeGaugeHistogram MetricType = 4
+	MetricTypeSummary        MetricType = 5
+	MetricTypeInfo           MetricType = 6
+	MetricTypeStateset       MetricType = 7
+)
+
+// NameLabel is the label holding the metric name.
+const NameLabel = "__name__"
+
+// ErrMalformed is returned when the payload is not a valid WriteRequest.
+var ErrMalformed = errors.New("malformed remote write request")
+
+// WriteRequest is the body of a remote_write request.
+type WriteRequest struct {
+	Timeseries []TimeSeries     // Timeseries are the series with their samples.
+	Metadata   []MetricMetadata // Metadata describes the metric families.
+}
+
+// TimeSeries is a set of labels with samples.
+type TimeSeries struct {
+	Labels  []Label  // Labels identify the series, including NameLabel.
+	Samples []Sample // Samples are ordered by timestamp.
+}
+
+// Label is a name and value pair.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value observed at a timestamp in milliseconds.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// MetricMetadata describes a metric family.
+type MetricMetadata struct {
+	Type             MetricType
+	MetricFamilyName string
+	Help             string
+	Unit             string
+}
+
+// Name returns the value of NameLabel.
+func (ts TimeSeries) Name() string {
+	for _, l := range ts.Labels {
+		if l.Name == NameLabel {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// DecodedLen returns the size of the snappy block once decompressed, as written in its
+// header, so that an oversized payload can be refused before it is decompressed.
+func DecodedLen(body []byte) (int, error) {
+	n, err := s2.DecodedLen(body)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	return n, nil
+}
+
+// Decode decompresses a snappy block and unmarshals the WriteRequest.
+//
+// Parameters:
+//   - body: the request body as sent by Prometheus.
+//
+// Returns:
+//   - *WriteRequest: the decoded request.
+//   - error: ErrMalformed if the payload cannot be decoded.
+func Decode(body []byte) (*WriteRequest, error) {
+	const op = "lib.prompb.Decode"
+
+	raw, err := s2.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrMalformed, err)
+	}
+	var req WriteRequest
+	if err = req.Unmarshal(raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &req, nil
+}
+
+// Encode marshals the WriteRequest and compresses it into a snappy block.
+func Encode(req *WriteRequest) []byte {
+	return s2.EncodeSnappy(nil, req.Marshal())
+}
+
+// Marshal encodes the request in the protobuf wire format.
+func (req *WriteRequest) Marshal() []byte {
+	var b []byte
+	for _, ts := range req.Timeseries {
+		var tb []byte
+		for _, l := range ts.Labels {
+			var lb []byte
+			lb = appendString(lb, 1, l.Name)
+			lb = appendString(lb, 2, l.Value)
+			tb = appendMessage(tb, 1, lb)
+		}
+		for _, s := range ts.Samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+			sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+			tb = appendMessage(tb, 2, sb)
+		}
+		b = appendMessage(b, 1, tb)
+	}
+	for _, m := range req.Metadata {
+		var mb []byte
+		mb = protowire.AppendTag(mb, 1, protowire.VarintType)
+		mb = protowire.AppendVarint(mb, uint64(m.Type))
+		mb = appendString(mb, 2, m.MetricFamilyName)
+		mb = appendString(mb, 4, m.Help)
+		mb = appendString(mb, 5, m.Unit)
+		b = appendMessage(b, 3, mb)
+	}
+	return b
+}
+
+// Unmarshal decodes the request from the protobuf wire format.
+func (req *WriteRequest) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var ts TimeSeries
+			if err := ts.unmarshal(v); err != nil {
+				return err
+			}
+			req.Timeseries = append(req.Timeseries, ts)
+		case num == 3 && typ == protowire.BytesType:
+			var m MetricMetadata
+			if err := m.unmarshal(v); err != nil {
+				return err
+			}
+			req.Metadata = append(req.Metadata, m)
+		}
+		return nil
+	})
+}
+
+// unmarshal decodes a TimeSeries message.
+func (ts *TimeSeries) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var l Label
+			err := walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case 1:
+					l.Name = string(v)
+				case 2:
+					l.Value = string(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		case num == 2 && typ == protowire.BytesType:
+			var s Sample
+			err := walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == 1 && typ == protowire.Fixed64Type:
+					bits, _ := protowire.ConsumeFixed64(v)
+					s.Value = math.Float64frombits(bits)
+				case num == 2 && typ == protowire.VarintType:
+					t, _ := protowire.ConsumeVarint(v)
+					s.Timestamp = int64(t)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+}
+
+// unmarshal decodes a MetricMetadata message.
+func (m *MetricMetadata) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			t, _ := protowire.ConsumeVarint(v)
+			m.Type = MetricType(t)
+		case num == 2 && typ == protowire.BytesType:
+			m.MetricFamilyName = string(v)
+		case num == 4 && typ == protowire.BytesType:
+			m.Help = string(v)
+		case num == 5 && typ == protowire.BytesType:
+			m.Unit = string(v)
+		}
+		return nil
+	})
+}
+
+// walk calls fn for every field of the message. For length-delimited fields v is the payload,
+// for other fields v holds the raw encoded value.
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrMalformed, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var v []byte
+		if typ == protowire.BytesType {
+			v, n = protowire.ConsumeBytes(b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrMalformed, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendString appends a string field, omitting it when empty as proto3 does.
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendMessage appends an embedded message field.
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,54 @@
+package prompb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	req := &WriteRequest{
+		Timeseries: []TimeSeries{
+			{
+				Labels:  []Label{{Name: NameLabel, Value: "http_requests_total"}, {Name: "code", Value: "200"}},
+				Samples: []Sample{{Value: 10, Timestamp: 1000}, {Value: 12.5, Timestamp: 2000}},
+			},
+			{
+				Labels:  []Label{{Name: NameLabel, Value: "temperature"}},
+				Samples: []Sample{{Value: -3.25, Timestamp: 1500}},
+			},
+		},
+		Metadata: []MetricMetadata{{Type: MetricTypeCounter, MetricFamilyName: "http_requests_total", Help: "Requests."}},
+	}
+
+	got, err := Decode(Encode(req))
+	require.NoError(t, err)
+	require.Equal(t, req, got)
+	require.Equal(t, "http_requests_total", got.Timeseries[0].Name())
+}
+
+func TestUnmarshal_SkipsUnknownFields(t *testing.T) {
+	var ts []byte
+	ts = protowire.AppendTag(ts, 3, protowire.BytesType) // exemplar
+	ts = protowire.AppendBytes(ts, []byte{0x08, 0x01})
+	ts = appendMessage(ts, 1, appendString(appendString(nil, 1, NameLabel), 2, "up"))
+
+	var b []byte
+	b = appendMessage(b, 1, ts)
+	b = protowire.AppendTag(b, 7, protowire.VarintType)
+	b = protowire.AppendVarint(b, 42)
+
+	var req WriteRequest
+	require.NoError(t, req.Unmarshal(b))
+	require.Len(t, req.Timeseries, 1)
+	require.Equal(t, "up", req.Timeseries[0].Name())
+}
+
+func TestDecode_Malformed(t *testing.T) {
+	_, err := Decode([]byte("not snappy"))
+	require.ErrorIs(t, err, ErrMalformed)
+
+	var req WriteRequest
+	require.ErrorIs(t, req.Unmarshal([]byte{0x0a, 0x05, 0x01}), ErrMalformed)
+}
@@ -0,0 +1,367 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/history"
+)
+
+var (
+	// ErrInvalid is returned for expressions that parse but cannot be evaluated whatever
+	// the data, such as calls of unknown functions.
+	ErrInvalid = errors.New("invalid query")
+
+	// ErrNoData is returned when the stored data is not enough to evaluate the expression,
+	// such as rate without history or the average of no metrics.
+	ErrNoData = errors.New("not enough data")
+)
+
+// Storage interface for reading the metrics
+type Storage interface {
+	GetAllMetrics(ctx context.Context) ([][]string, [][]string, error)
+}
+
+// History interface for the recent values of the metrics
+type History interface {
+	Range(typ, name string, window time.Duration) []history.Sample
+}
+
+// Value is the result of an expression: a Scalar or a Vector.
+type Value interface {
+	value()
+}
+
+// Scalar is a single number.
+type Scalar float64
+
+// Vector is a set of metrics with their values.
+type Vector []Sample
+
+// Sample is one metric of a Vector.
+type Sample struct {
+	Name  string
+	Type  string
+	Value float64
+}
+
+func (Scalar) value() {}
+func (Vector) value() {}
+
+// Evaluator evaluates expressions against a storage.
+type Evaluator struct {
+	storage Storage
+	history History
+}
+
+// NewEvaluator creates an Evaluator.
+//
+// Parameters:
+//   - storage: the storage of the metrics.
+//   - history: the recent values used by rate, may be nil to disable it.
+//
+// Returns:
+//   - *Evaluator: the evaluator.
+func NewEvaluator(storage Storage, history History) *Evaluator {
+	return &Evaluator{storage: storage, history: history}
+}
+
+// Eval evaluates the expression.
+//
+// Selectors return vectors, numbers and aggregations return scalars. An operation on a
+// scalar and a vector applies to every element of the vector. Two vectors are matched by
+// metric name, except that two single-metric vectors, as in TotalMemory - FreeMemory,
+// combine into a scalar.
+//
+// Parameters:
+//   - ctx: context for managing request-scoped values, cancelation, and deadlines.
+//   - n: the parsed expression.
+//
+// Returns:
+//   - Value: a Scalar or a Vector.
+//   - error: ErrInvalid, ErrNoData or a storage error.
+func (e *Evaluator) Eval(ctx context.Context, n Node) (Value, error) {
+	const op = "lib.query.Eval"
+
+	gauges, counters, err := e.storage.GetAllMetrics(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	metrics := make(Vector, 0, len(gauges)+len(counters))
+	for _, typed := range []struct {
+		typ  string
+		rows [][]string
+	}{{format.Gauge, gauges}, {format.Counter, counters}} {
+		for _, row := range typed.rows {
+			v, err := strconv.ParseFloat(row[1], 64)
+			if err != nil {
+				continue
+			}
+			metrics = append(metrics, Sample{Name: row[0], Type: typed.typ, Value: v})
+		}
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].Name != metrics[j].Name {
+			return metrics[i].Name < metrics[j].Name
+		}
+		return metrics[i].Type < metrics[j].Type
+	})
+
+	ev := &evaluation{metrics: metrics, history: e.history}
+	return ev.eval(n)
+}
+
+// evaluation holds the snapshot of the metrics one expression is evaluated against.
+type evaluation struct {
+	metrics Vector
+	history History
+}
+
+func (ev *evaluation) eval(n Node) (Value, error) {
+	switch n := n.(type) {
+	case *NumberLiteral:
+		return Scalar(n.Value), nil
+	case *Selector:
+		if n.Range != 0 {
+			return nil, fmt.Errorf("%w: range selector %s is only allowed in rate", ErrInvalid, n)
+		}
+		return ev.selectMetrics(n.Pattern), nil
+	case *UnaryExpr:
+		v, err := ev.eval(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return apply(Scalar(-1), v, '*')
+	case *BinaryExpr:
+		lhs, err := ev.eval(n.LHS)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := ev.eval(n.RHS)
+		if err != nil {
+			return nil, err
+		}
+		return apply(lhs, rhs, n.Op)
+	case *Call:
+		return ev.call(n)
+	}
+	return nil, fmt.Errorf("%w: unexpected node %T", ErrInvalid, n)
+}
+
+func (ev *evaluation) selectMetrics(pattern string) Vector {
+	var v Vector
+	for _, m := range ev.metrics {
+		if match(pattern, m.Name) {
+			v = append(v, m)
+		}
+	}
+	return v
+}
+
+func (ev *evaluation) call(n *Call) (Value, error) {
+	if len(n.Args) != 1 {
+		return nil, fmt.Errorf("%w: %s expects one argument", ErrInvalid, n.Func)
+	}
+
+	if n.Func == "rate" {
+		sel, ok := n.Args[0].(*Selector)
+		if !ok || sel.Range == 0 {
+			return nil, fmt.Errorf("%w: rate expects a range selector such as name[5m]", ErrInvalid)
+		}
+		return ev.rate(sel)
+	}
+
+	agg, ok := aggregations[n.Func]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown function %q", ErrInvalid, n.Func)
+	}
+	arg, err := ev.eval(n.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	var values []float64
+	switch arg := arg.(type) {
+	case Scalar:
+		values = []float64{float64(arg)}
+	case Vector:
+		for _, s := range arg {
+			values = append(values, s.Value)
+		}
+	}
+	if len(values) == 0 && n.Func != "sum" && n.Func != "count" {
+		return nil, fmt.Errorf("%w: %s of no metrics", ErrNoData, n)
+	}
+	return Scalar(agg(values)), nil
+}
+
+// rate returns the per-second rate of change of every selected metric over the window.
+// For counters a decrease is taken as a restart of the storage and the value after it
+// counts as the increase. Metrics with fewer than two samples in the window are skipped.
+func (ev *evaluation) rate(sel *Selector) (Value, error) {
+	if ev.history == nil {
+		return nil, fmt.Errorf("%w: history is not recorded", ErrNoData)
+	}
+
+	var v Vector
+	for _, m := range ev.selectMetrics(sel.Pattern) {
+		samples := ev.history.Range(m.Type, m.Name, sel.Range)
+		if len(samples) < 2 {
+			continue
+		}
+		elapsed := samples[len(samples)-1].Time.Sub(samples[0].Time).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		var increase float64
+		for i := 1; i < len(samples); i++ {
+			delta := samples[i].Value - samples[i-1].Value
+			if delta < 0 && m.Type == format.Counter {
+				delta = samples[i].Value
+			}
+			increase += delta
+		}
+		v = append(v, Sample{Name: m.Name, Type: m.Type, Value: increase / elapsed})
+	}
+	if len(v) == 0 {
+		return nil, fmt.Errorf("%w: no history for %s", ErrNoData, sel)
+	}
+	return v, nil
+}
+
+var aggregations = map[string]func([]float64) float64{
+	"sum": func(vs []float64) float64 {
+		var sum float64
+		for _, v := range vs {
+			sum += v
+		}
+		return sum
+	},
+	"avg": func(vs []float64) float64 {
+		var sum float64
+		for _, v := range vs {
+			sum += v
+		}
+		return sum / float64(len(vs))
+	},
+	"min": func(vs []float64) float64 {
+		m := math.Inf(1)
+		for _, v := range vs {
+			m = math.Min(m, v)
+		}
+		return m
+	},
+	"max": func(vs []float64) float64 {
+		m := math.Inf(-1)
+		for _, v := range vs {
+			m = math.Max(m, v)
+		}
+		return m
+	},
+	"count": func(vs []float64) float64 {
+		return float64(len(vs))
+	},
+}
+
+// apply performs an arithmetic operation on two values.
+func apply(lhs, rhs Value, op byte) (Value, error) {
+	ls, lScalar := lhs.(Scalar)
+	rs, rScalar := rhs.(Scalar)
+	lv, _ := lhs.(Vector)
+	rv, _ := rhs.(Vector)
+
+	switch {
+	case lScalar && rScalar:
+		r, err := arith(float64(ls), float64(rs), op)
+		return Scalar(r), err
+
+	case lScalar:
+		out := make(Vector, 0, len(rv))
+		for _, s := range rv {
+			r, err := arith(float64(ls), s.Value, op)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, Sample{Name: s.Name, Type: s.Type, Value: r})
+		}
+		return out, nil
+
+	case rScalar:
+		out := make(Vector, 0, len(lv))
+		for _, s := range lv {
+			r, err := arith(s.Value, float64(rs), op)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, Sample{Name: s.Name, Type: s.Type, Value: r})
+		}
+		return out, nil
+
+	case len(lv) == 1 && len(rv) == 1 && lv[0].Name != rv[0].Name:
+		r, err := arith(lv[0].Value, rv[0].Value, op)
+		return Scalar(r), err
+	}
+
+	out := make(Vector, 0, len(lv))
+	for _, l := range lv {
+		for _, r := range rv {
+			if l.Name != r.Name || l.Type != r.Type {
+				continue
+			}
+			v, err := arith(l.Value, r.Value, op)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, Sample{Name: l.Name, Type: l.Type, Value: v})
+		}
+	}
+	return out, nil
+}
+
+func arith(a, b float64, op byte) (float64, error) {
+	switch op {
+	case '+':
+		return a + b, nil
+	case '-':
+		return a - b, nil
+	case '*':
+		return a * b, nil
+	case '/':
+		if b == 0 {
+			return 0, fmt.Errorf("%w: division by zero", ErrNoData)
+		}
+		return a / b, nil
+	}
+	return 0, fmt.Errorf("%w: unknown operator %q", ErrInvalid, op)
+}
+
+// match reports whether name matches the glob pattern, where * matches any sequence
+// of characters and ? any single character.
+func match(pattern, name string) bool {
+	p, n := 0, 0
+	star, next := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
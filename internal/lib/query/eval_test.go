@@ -0,0 +1,152 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/history"
+)
+
+type fakeStorage struct {
+	gauges, counters [][]string
+	err              error
+}
+
+func (s fakeStorage) GetAllMetrics(_ context.Context) ([][]string, [][]string, error) {
+	return s.gauges, s.counters, s.err
+}
+
+type fakeHistory map[string][]history.Sample
+
+func (h fakeHistory) Range(typ, name string, _ time.Duration) []history.Sample {
+	return h[typ+"/"+name]
+}
+
+func eval(t *testing.T, e *Evaluator, expr string) (Value, error) {
+	t.Helper()
+	n, err := Parse(expr)
+	require.NoError(t, err)
+	return e.Eval(context.Background(), n)
+}
+
+func TestEvaluator_Eval(t *testing.T) {
+	st := fakeStorage{
+		gauges: [][]string{
+			{"TotalMemory", "1000"},
+			{"FreeMemory", "250"},
+			{"CPUutilization1", "10"},
+			{"CPUutilization2", "30"},
+		},
+		counters: [][]string{{"PollCount", "7"}},
+	}
+	e := NewEvaluator(st, nil)
+
+	cases := []struct {
+		name string
+		expr string
+		want Value
+	}{
+		{name: "constant", expr: "2 * (3 + 4)", want: Scalar(14)},
+		{name: "selector", expr: "PollCount", want: Vector{{Name: "PollCount", Type: format.Counter, Value: 7}}},
+		{name: "difference of metrics", expr: "TotalMemory - FreeMemory", want: Scalar(750)},
+		{name: "sum", expr: "sum(CPUutilization*)", want: Scalar(40)},
+		{name: "avg", expr: "avg(CPUutilization?)", want: Scalar(20)},
+		{name: "min", expr: "min(CPUutilization*)", want: Scalar(10)},
+		{name: "max", expr: "max(CPUutilization*)", want: Scalar(30)},
+		{name: "count", expr: "count(*)", want: Scalar(5)},
+		{name: "sum of nothing", expr: "sum(Missing*)", want: Scalar(0)},
+		{name: "vector and scalar", expr: "CPUutilization* / 10", want: Vector{
+			{Name: "CPUutilization1", Type: format.Gauge, Value: 1},
+			{Name: "CPUutilization2", Type: format.Gauge, Value: 3},
+		}},
+		{name: "vectors matched by name", expr: "CPUutilization* + CPUutilization*", want: Vector{
+			{Name: "CPUutilization1", Type: format.Gauge, Value: 20},
+			{Name: "CPUutilization2", Type: format.Gauge, Value: 60},
+		}},
+		{name: "negation", expr: "-FreeMemory", want: Vector{{Name: "FreeMemory", Type: format.Gauge, Value: -250}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := eval(t, e, tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestEvaluator_Rate(t *testing.T) {
+	st := fakeStorage{
+		gauges:   [][]string{{"Alloc", "40"}},
+		counters: [][]string{{"PollCount", "15"}, {"Fresh", "1"}},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := fakeHistory{
+		"counter/PollCount": {
+			{Time: start, Value: 10},
+			{Time: start.Add(10 * time.Second), Value: 20},
+			// The server restarted and the counter started over.
+			{Time: start.Add(20 * time.Second), Value: 5},
+			{Time: start.Add(40 * time.Second), Value: 15},
+		},
+		"gauge/Alloc": {
+			{Time: start, Value: 100},
+			{Time: start.Add(20 * time.Second), Value: 40},
+		},
+		"counter/Fresh": {{Time: start, Value: 1}},
+	}
+	e := NewEvaluator(st, h)
+
+	got, err := eval(t, e, "rate(PollCount[5m])")
+	require.NoError(t, err)
+	require.Equal(t, Vector{{Name: "PollCount", Type: format.Counter, Value: 25.0 / 40}}, got)
+
+	got, err = eval(t, e, "rate(Alloc[5m])")
+	require.NoError(t, err)
+	require.Equal(t, Vector{{Name: "Alloc", Type: format.Gauge, Value: -3}}, got)
+
+	_, err = eval(t, e, "rate(Fresh[5m])")
+	require.ErrorIs(t, err, ErrNoData)
+}
+
+func TestEvaluator_Errors(t *testing.T) {
+	st := fakeStorage{gauges: [][]string{{"Alloc", "1"}}}
+
+	cases := []struct {
+		name    string
+		history History
+		expr    string
+		want    error
+	}{
+		{name: "unknown function", expr: "median(Alloc)", want: ErrInvalid},
+		{name: "too many arguments", expr: "sum(Alloc, Alloc)", want: ErrInvalid},
+		{name: "range outside rate", expr: "Alloc[5m]", want: ErrInvalid},
+		{name: "rate of instant selector", history: fakeHistory{}, expr: "rate(Alloc)", want: ErrInvalid},
+		{name: "rate without history", expr: "rate(Alloc[5m])", want: ErrNoData},
+		{name: "avg of nothing", expr: "avg(Missing)", want: ErrNoData},
+		{name: "division by zero", expr: "Alloc / 0", want: ErrNoData},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := eval(t, NewEvaluator(st, tc.history), tc.expr)
+			require.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	storageErr := errors.New("connection refused")
+	_, err := eval(t, NewEvaluator(fakeStorage{err: storageErr}, nil), "1")
+	require.ErrorIs(t, err, storageErr)
+}
+
+func TestMatch(t *testing.T) {
+	require.True(t, match("CPU*", "CPUutilization1"))
+	require.True(t, match("*Memory", "TotalMemory"))
+	require.True(t, match("C?U*1", "CPUutilization1"))
+	require.True(t, match("*", ""))
+	require.False(t, match("CPU?", "CPU"))
+	require.False(t, match("*Memory", "MemoryTotal"))
+}
@@ -0,0 +1,408 @@
+// Package query implements a small expression language over stored metrics:
+//
+//	TotalMemory - FreeMemory
+//	sum(CPUutilization*)
+//	rate(PollCount[5m]) * 60
+//
+// An expression combines numbers, metric selectors and function calls with the
+// arithmetic operators + - * / and parentheses. A selector is a metric name that may
+// contain the glob wildcards * and ?; it selects every stored gauge and counter with a
+// matching name. A selector followed by a duration in brackets is a range selector,
+// accepted only by rate. The functions sum, avg, min, max and count aggregate the
+// selected metrics into a number. A * directly after a name character is part of the
+// name, so the multiplication operator is separated by spaces: Alloc * 2.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSyntax is returned for expressions that cannot be parsed.
+var ErrSyntax = errors.New("query syntax error")
+
+// Node is a node of the syntax tree.
+type Node interface {
+	// String returns the expression in its canonical form.
+	String() string
+}
+
+// NumberLiteral is a constant.
+type NumberLiteral struct {
+	Value float64
+}
+
+// Selector selects the metrics whose names match Pattern.
+type Selector struct {
+	Pattern string        // Pattern is the name, possibly with the wildcards * and ?.
+	Range   time.Duration // Range is the window of a range selector, zero for an instant selector.
+}
+
+// UnaryExpr is a negation.
+type UnaryExpr struct {
+	Expr Node
+}
+
+// BinaryExpr is an arithmetic operation.
+type BinaryExpr struct {
+	Op       byte // Op is one of + - * /.
+	LHS, RHS Node
+}
+
+// Call is a function call.
+type Call struct {
+	Func string
+	Args []Node
+}
+
+// String implements Node.
+func (n *NumberLiteral) String() string {
+	return strconv.FormatFloat(n.Value, 'g', -1, 64)
+}
+
+// String implements Node.
+func (n *Selector) String() string {
+	if n.Range == 0 {
+		return n.Pattern
+	}
+	return n.Pattern + "[" + n.Range.String() + "]"
+}
+
+// String implements Node.
+func (n *UnaryExpr) String() string {
+	return "-" + n.Expr.String()
+}
+
+// String implements Node.
+func (n *BinaryExpr) String() string {
+	return "(" + n.LHS.String() + " " + string(n.Op) + " " + n.RHS.String() + ")"
+}
+
+// String implements Node.
+func (n *Call) String() string {
+	args := make([]string, len(n.Args))
+	for i, a := range n.Args {
+		args[i] = a.String()
+	}
+	return n.Func + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Parse parses an expression.
+//
+// Parameters:
+//   - expr: the expression text.
+//
+// Returns:
+//   - Node: the root of the syntax tree.
+//   - error: ErrSyntax with the position of the problem.
+func Parse(expr string) (Node, error) {
+	p := &parser{src: expr}
+	p.next()
+	n, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return n, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokName
+	tokDuration
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// String describes the token in error messages.
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// parser is a recursive descent parser:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | "(" expr ")" | name "(" [ expr { "," expr } ] ")" | name [ "[" duration "]" ]
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: position %d: %s", ErrSyntax, p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseExpr() (Node, error) {
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.tok.kind == tokOp && p.tok.text == "-" {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Expr: n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	switch p.tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(p.tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", p.tok)
+		}
+		p.next()
+		return &NumberLiteral{Value: v}, nil
+
+	case tokLParen:
+		p.next()
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected \")\", got %s", p.tok)
+		}
+		p.next()
+		return n, nil
+
+	case tokName:
+		name := p.tok.text
+		p.next()
+		if p.tok.kind == tokLParen {
+			return p.parseCall(name)
+		}
+		sel := &Selector{Pattern: name}
+		if p.tok.kind == tokLBracket {
+			p.nextDuration()
+			if p.tok.kind != tokDuration {
+				return nil, p.errorf("expected duration, got %s", p.tok)
+			}
+			d, err := time.ParseDuration(p.tok.text)
+			if err != nil || d <= 0 {
+				return nil, p.errorf("invalid duration %s", p.tok)
+			}
+			sel.Range = d
+			p.next()
+			if p.tok.kind != tokRBracket {
+				return nil, p.errorf("expected \"]\", got %s", p.tok)
+			}
+			p.next()
+		}
+		return sel, nil
+	}
+	return nil, p.errorf("unexpected %s", p.tok)
+}
+
+func (p *parser) parseCall(name string) (Node, error) {
+	call := &Call{Func: name}
+	p.next() // "("
+	if p.tok.kind == tokRParen {
+		p.next()
+		return call, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		switch p.tok.kind {
+		case tokComma:
+			p.next()
+		case tokRParen:
+			p.next()
+			return call, nil
+		default:
+			return nil, p.errorf("expected \",\" or \")\", got %s", p.tok)
+		}
+	}
+}
+
+// next reads the next token.
+func (p *parser) next() {
+	p.skipSpace()
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '+' || c == '-' || c == '*' && !p.nameFollows() || c == '/':
+		p.pos++
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+	case c == '(':
+		p.pos++
+		p.tok = token{kind: tokLParen, text: "(", pos: start}
+	case c == ')':
+		p.pos++
+		p.tok = token{kind: tokRParen, text: ")", pos: start}
+	case c == '[':
+		p.pos++
+		p.tok = token{kind: tokLBracket, text: "[", pos: start}
+	case c == ']':
+		p.pos++
+		p.tok = token{kind: tokRBracket, text: "]", pos: start}
+	case c == ',':
+		p.pos++
+		p.tok = token{kind: tokComma, text: ",", pos: start}
+	case isDigit(c) || c == '.':
+		p.scanNumber()
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], pos: start}
+	case isNameStart(c):
+		if err := p.scanName(); err != nil {
+			p.tok = token{kind: tokInvalid, text: p.src[start:], pos: start}
+			return
+		}
+		p.tok = token{kind: tokName, text: p.src[start:p.pos], pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: tokInvalid, text: string(c), pos: start}
+	}
+}
+
+// nextDuration reads a duration token such as "5m" after "[".
+func (p *parser) nextDuration() {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || isLetter(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos == start {
+		p.next()
+		return
+	}
+	p.tok = token{kind: tokDuration, text: p.src[start:p.pos], pos: start}
+}
+
+// nameFollows reports whether the "*" at the current position starts a selector:
+// a wildcard is an operator only where an operator is expected.
+func (p *parser) nameFollows() bool {
+	switch p.tok.kind {
+	case tokNumber, tokName, tokRParen, tokRBracket:
+		return false
+	}
+	return true
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+func (p *parser) scanNumber() {
+	for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+	}
+}
+
+// scanName reads a metric name. Labels in braces, as produced by the series encoding,
+// are part of the name; quoted label values may contain any character.
+func (p *parser) scanName() error {
+	for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == len(p.src) || p.src[p.pos] != '{' {
+		return nil
+	}
+	quoted := false
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		switch c := p.src[p.pos]; {
+		case c == '\\' && quoted:
+			p.pos++
+		case c == '"':
+			quoted = !quoted
+		case c == '}' && !quoted:
+			p.pos++
+			return nil
+		}
+	}
+	return errors.New("unterminated labels")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameStart(c byte) bool {
+	return isLetter(c) || c == '_' || c == '*' || c == '?'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c) || c == '.' || c == ':'
+}
@@ -0,0 +1,69 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{name: "number", expr: "1.5", want: "1.5"},
+		{name: "selector", expr: "Alloc", want: "Alloc"},
+		{name: "difference", expr: "TotalMemory - FreeMemory", want: "(TotalMemory - FreeMemory)"},
+		{name: "precedence", expr: "1 + 2 * 3", want: "(1 + (2 * 3))"},
+		{name: "parentheses", expr: "(1 + 2) * 3", want: "((1 + 2) * 3)"},
+		{name: "left associative", expr: "8 / 4 / 2", want: "((8 / 4) / 2)"},
+		{name: "negation", expr: "-Alloc", want: "-Alloc"},
+		{name: "glob", expr: "sum(CPUutilization*)", want: "sum(CPUutilization*)"},
+		{name: "bare wildcard", expr: "count(*)", want: "count(*)"},
+		{name: "multiplication after call", expr: "rate(PollCount[5m]) * 60", want: "(rate(PollCount[5m0s]) * 60)"},
+		{name: "labels", expr: `http_requests{code="2*0"}`, want: `http_requests{code="2*0"}`},
+		{name: "exponent", expr: "1e3", want: "1000"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := Parse(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, n.String())
+		})
+	}
+}
+
+func TestParse_RangeSelector(t *testing.T) {
+	n, err := Parse("rate(PollCount[1h30m])")
+	require.NoError(t, err)
+
+	call, ok := n.(*Call)
+	require.True(t, ok)
+	require.Equal(t, "rate", call.Func)
+	require.Equal(t, &Selector{Pattern: "PollCount", Range: 90 * time.Minute}, call.Args[0])
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		expr string
+	}{
+		{name: "empty", expr: ""},
+		{name: "dangling operator", expr: "Alloc +"},
+		{name: "unbalanced", expr: "(1 + 2"},
+		{name: "missing duration", expr: "rate(PollCount[])"},
+		{name: "bad duration", expr: "rate(PollCount[5x])"},
+		{name: "unterminated range", expr: "rate(PollCount[5m)"},
+		{name: "unterminated labels", expr: `cpu{core="1"`},
+		{name: "trailing input", expr: "1 2"},
+		{name: "invalid character", expr: "Alloc % 2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.expr)
+			require.ErrorIs(t, err, ErrSyntax)
+		})
+	}
+}
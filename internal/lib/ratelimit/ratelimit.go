@@ -0,0 +1,121 @@
+// Package ratelimit limits the rate of requests of every client with a token bucket
+// and the number of requests the server handles at once.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// idleBuckets is the number of buckets above which the full ones are forgotten.
+const idleBuckets = 10000
+
+// bucket is the token bucket of one client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a set of token buckets, one per client. Every bucket holds up to burst
+// tokens and is refilled at rate tokens per second; a request takes one token.
+type Limiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// New creates a Limiter.
+//
+// Parameters:
+//   - rate: the sustained number of requests per second of a client.
+//   - burst: the number of requests a client may send at once, at least 1.
+//
+// Returns:
+//   - *Limiter: the limiter.
+func New(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of the client.
+//
+// Parameters:
+//   - key: the identity of the client.
+//
+// Returns:
+//   - bool: whether the request is allowed.
+//   - time.Duration: if it is not, how long until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= idleBuckets {
+			l.forgetFull(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// forgetFull removes the buckets that have refilled, a new bucket starts full anyway.
+func (l *Limiter) forgetFull(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Semaphore bounds the number of requests handled at once.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// NewSemaphore creates a Semaphore.
+//
+// Parameters:
+//   - limit: the number of requests handled at once.
+//
+// Returns:
+//   - *Semaphore: the semaphore.
+func NewSemaphore(limit int) *Semaphore {
+	return &Semaphore{slots: make(chan struct{}, limit)}
+}
+
+// TryAcquire takes a slot without waiting and reports whether there was a free one.
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees a slot taken by TryAcquire.
+func (s *Semaphore) Release() {
+	<-s.slots
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Now()
+	l := New(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		require.True(t, ok, "request %d is within the burst", i)
+	}
+	ok, wait := l.Allow("a")
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	ok, _ = l.Allow("b")
+	require.True(t, ok, "clients have their own buckets")
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	require.True(t, ok, "the bucket is refilled at the rate")
+	ok, _ = l.Allow("a")
+	require.False(t, ok)
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ = l.Allow("a")
+		require.True(t, ok)
+	}
+	ok, _ = l.Allow("a")
+	require.False(t, ok, "the bucket holds at most burst tokens")
+}
+
+func TestLimiter_ForgetsFullBuckets(t *testing.T) {
+	now := time.Now()
+	l := New(1, 1)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.forgetFull(now)
+	require.Len(t, l.buckets, 1, "an empty bucket is kept")
+
+	now = now.Add(time.Second)
+	l.forgetFull(now)
+	require.Empty(t, l.buckets)
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(2)
+
+	require.True(t, s.TryAcquire())
+	require.True(t, s.TryAcquire())
+	require.False(t, s.TryAcquire())
+
+	s.Release()
+	require.True(t, s.TryAcquire())
+}
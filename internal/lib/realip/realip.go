@@ -0,0 +1,62 @@
+// Package realip carries the address of the agent to the server. The agent reports the address
+// of the interface it reaches the server through, and the server admits writes only from
+// the trusted subnet.
+package realip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Header is the HTTP header with the address reported by the agent.
+const Header = "X-Real-IP"
+
+// MetadataKey is the gRPC metadata key with the address reported by the agent.
+// It is the counterpart of the X-Real-IP HTTP header.
+const MetadataKey = "x-real-ip"
+
+// Outbound returns the address of the local interface the host reaches addr through.
+// Connecting a UDP socket only selects the route, no packet is sent.
+//
+// Parameters:
+//   - addr: the address of the server, e.g. "localhost:8080".
+//
+// Returns:
+//   - net.IP: the local address.
+//   - error: if addr cannot be resolved or no route leads to it.
+func Outbound(addr string) (net.IP, error) {
+	const op = "realip.Outbound"
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.Close()
+
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected local address %s", op, conn.LocalAddr())
+	}
+	return local.IP, nil
+}
+
+// Resolve returns the address of the client: the address it reports if any, the address
+// of the peer otherwise.
+//
+// Parameters:
+//   - reported: the value of the X-Real-IP header or metadata, empty if missing.
+//   - peer: the remote address of the connection, with or without a port.
+//
+// Returns:
+//   - net.IP: the address of the client, nil if the used value is not an IP address.
+func Resolve(reported, peer string) net.IP {
+	if reported = strings.TrimSpace(reported); reported != "" {
+		return net.ParseIP(reported)
+	}
+	host, _, err := net.SplitHostPort(peer)
+	if err != nil {
+		host = peer
+	}
+	return net.ParseIP(host)
+}
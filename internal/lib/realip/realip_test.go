@@ -0,0 +1,39 @@
+package realip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		reported string
+		peer     string
+		want     net.IP
+	}{
+		{name: "reported", reported: "10.0.0.7", peer: "192.168.1.1:5000", want: net.ParseIP("10.0.0.7")},
+		{name: "reported with spaces", reported: " 10.0.0.7 ", peer: "192.168.1.1:5000", want: net.ParseIP("10.0.0.7")},
+		{name: "peer", peer: "192.168.1.1:5000", want: net.ParseIP("192.168.1.1")},
+		{name: "peer without port", peer: "192.168.1.1", want: net.ParseIP("192.168.1.1")},
+		{name: "ipv6 peer", peer: "[::1]:5000", want: net.ParseIP("::1")},
+		{name: "invalid reported", reported: "agent-1", peer: "192.168.1.1:5000"},
+		{name: "invalid peer", peer: "pipe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Resolve(tt.reported, tt.peer))
+		})
+	}
+}
+
+func TestOutbound(t *testing.T) {
+	ip, err := Outbound("127.0.0.1:8080")
+	require.NoError(t, err)
+	require.True(t, ip.IsLoopback())
+
+	_, err = Outbound("no-port")
+	require.Error(t, err)
+}
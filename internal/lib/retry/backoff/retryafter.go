@@ -0,0 +1,77 @@
+package backoff
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rican7/retry/backoff"
+	"github.com/Rican7/retry/strategy"
+)
+
+// MaxRetryAfter bounds the wait requested by a server, so that a misbehaving server cannot stall the client.
+const MaxRetryAfter = time.Minute
+
+// ParseRetryAfter parses the value of the Retry-After header, which is either a number
+// of seconds or an HTTP date.
+//
+// Parameters:
+// - value: the value of the header.
+// - now: the current time, for dates.
+//
+// Returns:
+// - time.Duration: the wait, at most MaxRetryAfter.
+// - bool: whether the value is valid.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+	} else {
+		date, err := http.ParseTime(value)
+		if err != nil {
+			return 0, false
+		}
+		wait = date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+	}
+	if wait > MaxRetryAfter {
+		wait = MaxRetryAfter
+	}
+	return wait, true
+}
+
+// RetryAfter returns a retry strategy that waits before each attempt after the first.
+// If the previous attempt stored a wait in hint, for example from the Retry-After header
+// of the response, the strategy waits for it and resets the hint; otherwise it waits
+// for the duration given by algorithm.
+//
+// Parameters:
+// - algorithm: the backoff used when the server gives no hint.
+// - hint: the wait requested by the server, set by the action; 0 means no hint.
+//
+// Returns:
+// - A strategy.Strategy for retry.Retry.
+func RetryAfter(algorithm backoff.Algorithm, hint *time.Duration) strategy.Strategy {
+	return func(attempt uint) bool {
+		if attempt == 0 {
+			return true
+		}
+		wait := algorithm(attempt)
+		if *hint > 0 {
+			wait = *hint
+			*hint = 0
+		}
+		time.Sleep(wait)
+		return true
+	}
+}
@@ -0,0 +1,54 @@
+package backoff
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "Seconds", value: "3", want: 3 * time.Second, wantOK: true},
+		{name: "Date", value: now.Add(10 * time.Second).Format(http.TimeFormat), want: 10 * time.Second, wantOK: true},
+		{name: "Past date", value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+		{name: "Too long", value: "86400", want: MaxRetryAfter, wantOK: true},
+		{name: "Empty", value: "", wantOK: false},
+		{name: "Negative", value: "-1", wantOK: false},
+		{name: "Garbage", value: "soon", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls []uint
+	algorithm := func(attempt uint) time.Duration {
+		calls = append(calls, attempt)
+		return time.Millisecond
+	}
+	hint := time.Millisecond
+	strategy := RetryAfter(algorithm, &hint)
+
+	require.True(t, strategy(0))
+	require.Empty(t, calls, "the first attempt is not delayed")
+
+	require.True(t, strategy(1))
+	require.Zero(t, hint, "the hint is used once")
+
+	require.True(t, strategy(2))
+	require.Equal(t, []uint{1, 2}, calls)
+}
@@ -0,0 +1,197 @@
+// Package s3 provides a minimal client for S3-compatible object storage.
+// It supports putting, getting, listing and deleting objects using path-style
+// addressing and AWS Signature Version 4, which is understood by AWS S3, MinIO and
+// most other S3-compatible services.
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the requested object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Client is a client of an S3-compatible object storage.
+type Client struct {
+	Endpoint  string       // Endpoint is the base URL of the service, e.g. https://s3.amazonaws.com.
+	Region    string       // Region is used in the request signature.
+	AccessKey string       // AccessKey is the access key ID.
+	SecretKey string       // SecretKey is the secret access key.
+	HTTP      *http.Client // HTTP is the client used to send requests.
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key          string    `xml:"Key"`          // Key is the object key.
+	Size         int64     `xml:"Size"`         // Size is the object size in bytes.
+	LastModified time.Time `xml:"LastModified"` // LastModified is the time the object was written.
+}
+
+// listBucketResult is the response body of ListObjectsV2.
+type listBucketResult struct {
+	Contents              []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// New creates a new Client.
+//
+// Parameters:
+//   - endpoint: the base URL of the service.
+//   - region: the region used for signing, "us-east-1" if empty.
+//   - accessKey: the access key ID.
+//   - secretKey: the secret access key.
+//
+// Returns:
+//   - *Client: the created client.
+//   - error: an error if the endpoint is not a valid URL.
+func New(endpoint, region, accessKey, secretKey string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("lib.s3.New: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("lib.s3.New: endpoint must be an absolute URL: %s", endpoint)
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &Client{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		HTTP:      http.DefaultClient,
+	}, nil
+}
+
+// PutObject uploads size bytes from body under the given key.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, body io.Reader, size int64) error {
+	const op = "lib.s3.PutObject"
+
+	req, err := c.newRequest(ctx, http.MethodPut, bucket, key, nil, body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.ContentLength = size
+	resp, err := c.do(req, unsignedPayload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject returns the content of the object with the given key.
+// The caller must close the returned reader.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	const op = "lib.s3.GetObject"
+
+	req, err := c.newRequest(ctx, http.MethodGet, bucket, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	resp, err := c.do(req, emptyPayload)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return resp.Body, nil
+}
+
+// DeleteObject removes the object with the given key.
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	const op = "lib.s3.DeleteObject"
+
+	req, err := c.newRequest(ctx, http.MethodDelete, bucket, key, nil, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp, err := c.do(req, emptyPayload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListObjects returns all objects whose keys start with prefix.
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	const op = "lib.s3.ListObjects"
+
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := c.newRequest(ctx, http.MethodGet, bucket, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		resp, err := c.do(req, emptyPayload)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// newRequest builds a path-style request to the given bucket and key.
+func (c *Client) newRequest(ctx context.Context, method, bucket, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	path := "/" + bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	u.RawPath = ""
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// do signs and sends the request and converts error responses into errors.
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	sign(req, c.AccessKey, c.SecretKey, c.Region, payloadHash, time.Now())
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,114 @@
+// Package s3test provides an in-process fake of an S3-compatible object storage for tests.
+// It implements path-style PutObject, GetObject, DeleteObject and ListObjectsV2 and keeps
+// objects in memory. Request signatures are not verified, only their presence is checked.
+package s3test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake S3 server backed by memory.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+// object is a stored object.
+type object struct {
+	data     []byte
+	modified time.Time
+}
+
+// listEntry is an element of the ListObjectsV2 response.
+type listEntry struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// listResult is the ListObjectsV2 response body.
+type listResult struct {
+	XMLName     xml.Name    `xml:"ListBucketResult"`
+	Contents    []listEntry `xml:"Contents"`
+	IsTruncated bool        `xml:"IsTruncated"`
+}
+
+// NewServer starts a new fake S3 server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{objects: make(map[string]object)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Keys returns the keys of all stored objects in the bucket, sorted.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, bucket+"/") {
+			keys = append(keys, strings.TrimPrefix(k, bucket+"/"))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handle serves a single S3 request.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && key == "" && r.URL.Query().Get("list-type") == "2":
+		prefix := r.URL.Query().Get("prefix")
+		result := listResult{}
+		for k, o := range s.objects {
+			name := strings.TrimPrefix(k, bucket+"/")
+			if name != k && strings.HasPrefix(name, prefix) {
+				result.Contents = append(result.Contents, listEntry{Key: name, Size: int64(len(o.data)), LastModified: o.modified})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut && key != "":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.objects[path] = object{data: data, modified: time.Now().UTC()}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && key != "":
+		o, ok := s.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(o.data)
+	case r.Method == http.MethodDelete && key != "":
+		delete(s.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,111 @@
+// Package series encodes labeled series into plain metric names.
+// The storage keys metrics by name only, so a series with labels is stored under
+// its canonical form `name{a="1",b="2"}` with labels sorted by name.
+// A name without labels is stored unchanged.
+package series
+
+import (
+	"sort"
+	"strings"
+)
+
+// Format returns the canonical name of the series.
+//
+// Parameters:
+//   - name: the metric name.
+//   - labels: the series labels, may be empty.
+//
+// Returns:
+//   - string: the name with labels appended in braces, sorted by label name.
+func Format(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	names := make([]string, 0, len(labels))
+	for l := range labels {
+		names = append(names, l)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, l := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l)
+		b.WriteString(`="`)
+		b.WriteString(escaper.Replace(labels[l]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Parse splits a name produced by Format into the metric name and its labels.
+// Names that are not in the canonical form are returned unchanged with no labels.
+//
+// Parameters:
+//   - s: the stored metric name.
+//
+// Returns:
+//   - string: the metric name.
+//   - map[string]string: the labels, nil if there are none.
+func Parse(s string) (string, map[string]string) {
+	open := strings.IndexByte(s, '{')
+	if open <= 0 || !strings.HasSuffix(s, "}") {
+		return s, nil
+	}
+	name, rest := s[:open], s[open+1:len(s)-1]
+
+	labels := make(map[string]string)
+	for rest != "" {
+		l, after, ok := strings.Cut(rest, `="`)
+		if !ok || l == "" {
+			return s, nil
+		}
+		value, n, ok := unquote(after)
+		if !ok {
+			return s, nil
+		}
+		labels[l] = value
+		rest = after[n:]
+		if rest != "" {
+			if rest[0] != ',' {
+				return s, nil
+			}
+			rest = rest[1:]
+		}
+	}
+	return name, labels
+}
+
+// escaper escapes backslashes, double quotes and line feeds in label values.
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// unquote reads an escaped label value up to the closing quote.
+// It returns the value and the number of bytes consumed including the quote.
+func unquote(s string) (string, int, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, true
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", 0, false
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,42 @@
+package series
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{name: "up", want: "up"},
+		{name: "http_requests_total", labels: map[string]string{"method": "GET", "code": "200"}, want: `http_requests_total{code="200",method="GET"}`},
+		{name: "escaped", labels: map[string]string{"v": "a\"b\\c\nd"}, want: `escaped{v="a\"b\\c\nd"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Format(tt.name, tt.labels)
+			require.Equal(t, tt.want, got)
+
+			name, labels := Parse(got)
+			require.Equal(t, tt.name, name)
+			if len(tt.labels) == 0 {
+				require.Nil(t, labels)
+			} else {
+				require.Equal(t, tt.labels, labels)
+			}
+		})
+	}
+}
+
+func TestParse_NotCanonical(t *testing.T) {
+	for _, s := range []string{"{a=\"b\"}", "x{a=b}", "x{a=\"b\"", "x{a=\"b\"c=\"d\"}"} {
+		name, labels := Parse(s)
+		require.Equal(t, s, name)
+		require.Nil(t, labels)
+	}
+}
@@ -5,10 +5,16 @@ package signature
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 )
 
+// MetadataKey is the gRPC metadata key that carries the hash of a message.
+// It is the counterpart of the HashSHA256 HTTP header.
+const MetadataKey = "hashsha256"
+
 // GetHash generates a SHA-256 hash from the concatenation of the provided key and body strings.
 // It logs the operation and the generated hash using the provided zap.Logger.
 //
@@ -28,3 +34,24 @@ func GetHash(key string, body string, log *zap.Logger) string {
 	log.Info("Hash is generated", zap.String("hash", hashStr))
 	return hashStr
 }
+
+// GetMessageHash generates the hash of a protobuf message the way GetHash does for an HTTP body.
+// The message is marshaled deterministically, so the client and the server compute the same hash.
+//
+// Parameters:
+//   - key: The key concatenated with the marshaled message.
+//   - msg: The message to sign.
+//   - log: A pointer to a zap.Logger used for logging.
+//
+// Returns:
+//   - string: the hexadecimal SHA-256 hash.
+//   - error: an error if the message cannot be marshaled.
+func GetMessageHash(key string, msg proto.Message, log *zap.Logger) (string, error) {
+	const op = "lib.signature.GetMessageHash"
+
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return GetHash(key, string(body), log), nil
+}
@@ -0,0 +1,250 @@
+// Package tlsconfig builds the TLS configurations of the server and the agent. The server
+// certificate and the CA bundle verifying client certificates are read again when their files
+// change, so that renewed certificates are served without a restart.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is how often the files of a Reloader are checked for changes.
+const DefaultInterval = 10 * time.Second
+
+// Reloader serves the certificate and client CA bundle found in its files and reloads them
+// when the files change.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	log      *zap.Logger
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes []time.Time
+}
+
+// NewReloader loads the server certificate and, if caFile is set, the CA bundle that client
+// certificates must be signed by.
+//
+// Parameters:
+//   - certFile: the PEM certificate chain of the server.
+//   - keyFile: the PEM private key of the certificate.
+//   - caFile: the PEM bundle of the CAs of client certificates, empty to accept clients without certificates.
+//   - log: A zap.Logger instance for logging reloads.
+//
+// Returns:
+//   - *Reloader: the reloader.
+//   - error: if a file cannot be read or parsed.
+func NewReloader(certFile, keyFile, caFile string, log *zap.Logger) (*Reloader, error) {
+	const op = "tlsconfig.NewReloader"
+
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		log:      log.With(zap.String("component", "lib/tlsconfig")),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return r, nil
+}
+
+// ServerConfig returns the TLS configuration of a server using the reloader. With a client
+// CA bundle every client must present a certificate signed by one of its CAs.
+func (r *Reloader) ServerConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+	}
+	if r.caFile != "" {
+		// The chain is verified against the current bundle rather than a fixed ClientCAs pool,
+		// so that a reloaded bundle applies to the next handshakes.
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyPeerCertificate = r.verifyClient
+	}
+	return cfg
+}
+
+// Reload reads the files again. On error the previous certificate and bundle are kept.
+//
+// Returns:
+//   - error: if a file cannot be read or parsed.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		if pool, err = LoadCertPool(r.caFile); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCA, r.modTimes = &cert, pool, modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch checks the files every interval and reloads them when they change, until ctx is done.
+//
+// Parameters:
+//   - ctx: the context that stops the watch.
+//   - interval: how often the files are checked, DefaultInterval if not positive.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				r.log.Error("Cannot reload TLS certificate, keeping the previous one", zap.Error(err))
+				continue
+			}
+			r.log.Info("TLS certificate reloaded", zap.String("cert", r.certFile))
+		}
+	}
+}
+
+// changed reports whether a file was modified since it was loaded.
+func (r *Reloader) changed() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		// A file being replaced may be missing for a moment, it is checked again later.
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, t := range modTimes {
+		if !t.Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// stat returns the modification times of the files.
+func (r *Reloader) stat() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, name := range []string{r.certFile, r.keyFile, r.caFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+// verifyClient verifies the chain presented by a client against the current CA bundle.
+func (r *Reloader) verifyClient(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("client certificate required")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+
+	r.mu.RLock()
+	roots := r.clientCA
+	r.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// ClientConfig returns the TLS configuration of the agent.
+//
+// Parameters:
+//   - caFile: the PEM bundle of the CAs of the server certificate, empty to use the system roots.
+//   - certFile: the PEM certificate of the agent for mutual TLS, empty to connect without one.
+//   - keyFile: the PEM private key of the agent certificate.
+//
+// Returns:
+//   - *tls.Config: the configuration.
+//   - error: if a file cannot be read or parsed.
+func ClientConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	const op = "tlsconfig.ClientConfig"
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// LoadCertPool reads a PEM bundle of certificates.
+//
+// Parameters:
+//   - file: the path of the bundle.
+//
+// Returns:
+//   - *x509.CertPool: the certificates.
+//   - error: if the file cannot be read or holds no certificate.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,204 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// authority is a CA issuing the certificates of a test.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate signed by the authority and its key to dir and returns their paths.
+func (a *authority) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func (a *authority) write(t *testing.T, dir, name string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(file, a.pem, 0o600))
+	return file
+}
+
+// serve starts an HTTPS server with the configuration and returns its address.
+func serve(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }),
+		TLSConfig: cfg,
+	}
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+// servedName returns the common name of the certificate served at addr.
+func servedName(t *testing.T, addr string, cfg *tls.Config) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, cfg)
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	other := newAuthority(t, "other")
+	caFile := ca.write(t, dir, "ca.pem")
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	agentCert, agentKey := ca.issue(t, dir, "agent", x509.ExtKeyUsageClientAuth)
+	strangerCert, strangerKey := other.issue(t, dir, "stranger", x509.ExtKeyUsageClientAuth)
+
+	r, err := NewReloader(certFile, keyFile, caFile, zap.NewNop())
+	require.NoError(t, err)
+	addr := serve(t, r.ServerConfig())
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{name: "Trusted client certificate", certFile: agentCert, keyFile: agentKey},
+		{name: "No client certificate", wantErr: true},
+		{name: "Client certificate of another CA", certFile: strangerCert, keyFile: strangerKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ClientConfig(caFile, tt.certFile, tt.keyFile)
+			require.NoError(t, err)
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+
+			resp, err := client.Get("https://" + addr + "/")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+
+	// The server certificate is not trusted without the CA bundle.
+	_, err = (&http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{}}}).Get("https://" + addr + "/")
+	require.Error(t, err)
+}
+
+func TestReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	caFile := ca.write(t, dir, "ca.pem")
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	r, err := NewReloader(certFile, keyFile, "", zap.NewNop())
+	require.NoError(t, err)
+	addr := serve(t, r.ServerConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	client, err := ClientConfig(caFile, "", "")
+	require.NoError(t, err)
+	require.Equal(t, "server", servedName(t, addr, client))
+
+	// A renewed certificate is served once its files change.
+	renewedCert, renewedKey := ca.issue(t, dir, "renewed", x509.ExtKeyUsageServerAuth)
+	later := time.Now().Add(time.Minute)
+	for _, f := range [][2]string{{renewedCert, certFile}, {renewedKey, keyFile}} {
+		require.NoError(t, os.Rename(f[0], f[1]))
+		require.NoError(t, os.Chtimes(f[1], later, later))
+	}
+	require.Eventually(t, func() bool {
+		return servedName(t, addr, client) == "renewed"
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// A broken file does not replace the served certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	require.Error(t, r.Reload())
+	require.Equal(t, "renewed", servedName(t, addr, client))
+}
+
+func TestNewReloader_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	_, err := NewReloader(filepath.Join(dir, "missing.crt"), keyFile, "", zap.NewNop())
+	require.Error(t, err)
+	_, err = NewReloader(certFile, certFile, "", zap.NewNop())
+	require.Error(t, err, "the key file must hold the key")
+	_, err = NewReloader(certFile, keyFile, empty, zap.NewNop())
+	require.Error(t, err, "the client CA bundle must hold a certificate")
+
+	_, err = ClientConfig(empty, "", "")
+	require.Error(t, err)
+	_, err = ClientConfig("", certFile, "")
+	require.Error(t, err)
+}
@@ -4,14 +4,18 @@ package backuper
 
 import (
 	"context"
-	"encoding/json"
-	"os"
+	"errors"
+	"fmt"
+	"io"
 	"strconv"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/server/backuper/codec"
+	"github.com/mbiwapa/metric/internal/server/backuper/target"
 )
 
 // AllMetricGeter is an interface for the Metric repository.
@@ -66,6 +70,24 @@ type Buckuper struct {
 
 	// metrics is a slice that holds the metrics to be saved.
 	metrics metrics
+
+	// compression is the algorithm used to compress the backup file.
+	compression string
+
+	// encryptionKey is the AES-256 key used to encrypt the backup file, nil disables encryption.
+	encryptionKey []byte
+
+	// format is the name of the serialization format used when writing the backup file.
+	format string
+
+	// codec is the serialization format used when writing the backup file.
+	codec codec.Codec
+
+	// target is the destination of the backups, the file at storagePath by default.
+	target target.Target
+
+	// mu guards metrics, which are written by handlers and the saver concurrently.
+	mu sync.Mutex
 }
 
 // New creates a new instance of Saver
@@ -74,19 +96,39 @@ type Buckuper struct {
 // - storeInterval: the interval in seconds at which metrics should be saved
 // - storagePath: the file path where metrics will be saved
 // - logger: a zap.Logger instance for logging
+// - opts: optional settings such as compression and encryption of the backup file
 // Returns:
 // - a pointer to a new Buckuper instance
 // - an error if any occurs during the creation of the Buckuper instance
-func New(storage AllMetricGeter, storeInterval int64, storagePath string, logger *zap.Logger) (*Buckuper, error) {
+func New(storage AllMetricGeter, storeInterval int64, storagePath string, logger *zap.Logger, opts ...Option) (*Buckuper, error) {
 	initialMetrics := metrics{}
 
-	return &Buckuper{
+	backup := &Buckuper{
 		logger:        logger,
 		storage:       storage,
 		storeInterval: storeInterval,
 		storagePath:   storagePath,
 		metrics:       initialMetrics,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(backup)
+	}
+
+	if !ValidCompression(backup.compression) {
+		return nil, fmt.Errorf("%s: %w", "server.saver.New", ErrUnknownCompression)
+	}
+	if backup.target == nil {
+		backup.target = target.NewFile(storagePath)
+	}
+	if backup.codec == nil {
+		var err error
+		backup.codec, err = codec.ByName(backup.format)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "server.saver.New", err)
+		}
+	}
+
+	return backup, nil
 }
 
 // Start initiates the process of periodically saving metrics to a file.
@@ -113,32 +155,45 @@ func (s *Buckuper) Start() {
 		sleepSecond := time.Duration(s.storeInterval) * time.Second
 		time.Sleep(sleepSecond)
 
-		gauge, counter, err := s.storage.GetAllMetrics(ctx)
+		err := s.Refresh(ctx)
 		if err != nil {
 			//TODO error chanel
-			s.logger.Error("Cant get all metrics", zap.Error(err))
+			s.logger.Error("Cant refresh metrics", zap.Error(err))
 		}
+		s.SaveToFile()
+	}
+}
 
-		for _, metric := range gauge {
-			if metric[0] != "" && metric[1] != "" {
-				err = s.SaveToStruct(format.Gauge, metric[0], metric[1])
-				if err != nil {
-					//TODO error chanel
-					s.logger.Error("Cant save metric to struct", zap.Error(err))
-				}
+// Refresh updates the metrics to be saved with the current content of the storage.
+// Parameters:
+// - ctx: a context.Context for the storage request
+// Returns:
+// - an error if the metrics cannot be retrieved or parsed
+func (s *Buckuper) Refresh(ctx context.Context) error {
+	const op = "server.saver.Refresh"
+
+	gauge, counter, err := s.storage.GetAllMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, metric := range gauge {
+		if metric[0] != "" && metric[1] != "" {
+			err = s.SaveToStruct(format.Gauge, metric[0], metric[1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
 			}
 		}
-		for _, metric := range counter {
-			if metric[0] != "" && metric[1] != "" {
-				s.SaveToStruct(format.Counter, metric[0], metric[1])
-				if err != nil {
-					//TODO error chanel
-					s.logger.Error("Cant save metric to struct", zap.Error(err))
-				}
+	}
+	for _, metric := range counter {
+		if metric[0] != "" && metric[1] != "" {
+			err = s.SaveToStruct(format.Counter, metric[0], metric[1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
 			}
 		}
-		s.SaveToFile()
 	}
+	return nil
 }
 
 // SaveToStruct saves a metric to the metrics slice
@@ -177,6 +232,9 @@ func (s *Buckuper) SaveToStruct(typ string, name string, value string) error {
 
 	changed := false
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for i := 0; i < len(s.metrics); i++ {
 		if s.metrics[i].ID == name {
 			s.metrics[i] = m
@@ -192,10 +250,11 @@ func (s *Buckuper) SaveToStruct(typ string, name string, value string) error {
 	return nil
 }
 
-// SaveToFile saves the current metrics to a file in JSON format.
-// It marshals the metrics slice into a JSON-formatted byte array and writes it to the specified storage path.
+// SaveToFile saves the current metrics as a new generation of the backup target.
+// The metrics are streamed through the codec, compression and encryption layers into the target,
+// which is the file at the specified storage path unless another target is configured.
 // The method logs the start and completion of the save process, as well as any errors encountered during
-// the encoding or writing of the JSON data.
+// the encoding or writing of the data.
 //
 // Parameters:
 // - None
@@ -208,23 +267,57 @@ func (s *Buckuper) SaveToFile() {
 
 	s.logger.Info("Start save!")
 
-	data, err := json.MarshalIndent(s.metrics, "", "   ")
+	gen, err := s.target.Save(context.Background(), s.Dump)
 	if err != nil {
 		s.logger.Error(
-			"Cant encoding metric to json", zap.Error(err))
+			"Cant write backup", zap.Error(err))
+		return
 	}
-	err = os.WriteFile(s.storagePath, data, 0666)
+
+	s.logger.Info("Complete save!", zap.String("generation", gen.Name))
+}
+
+// Dump streams the current metrics to w in the configured format,
+// compressed and encrypted as configured.
+//
+// Parameters:
+// - w: the destination of the backup
+//
+// Returns:
+// - an error if any occurs during encoding or writing
+func (s *Buckuper) Dump(w io.Writer) error {
+	const op = "server.saver.Dump"
+
+	sealed, err := s.seal(w)
 	if err != nil {
-		s.logger.Error(
-			"Cant write json to file", zap.Error(err))
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	enc, err := s.codec.NewEncoder(sealed)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.mu.Lock()
+	snapshot := append(metrics(nil), s.metrics...)
+	s.mu.Unlock()
 
-	s.logger.Info("Complete save!")
+	for _, metric := range snapshot {
+		if err = enc.Encode(metric); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err = enc.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = sealed.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
 }
 
-// Restore restores the metrics from the file and updates the storage with the restored metrics.
-// It reads the metrics from the specified storage file, unmarshals the JSON data into the metrics slice,
-// and updates the storage with the restored metrics.
+// Restore restores the metrics from the most recent backup generation and updates the storage with the restored metrics.
+// It streams the backup from the target, detects and reverses encryption, compression and the backup format,
+// and updates the storage with every restored metric.
+// Plain JSON files written without compression or encryption are still accepted.
 //
 // The method logs the start and completion of the restore process, as well as any errors encountered
 // during reading, decoding, or updating the metrics.
@@ -239,38 +332,154 @@ func (s *Buckuper) Restore() {
 	s.logger.With(zap.String("op", op))
 	s.logger.Info("Start Restore!")
 
+	err := s.RestoreGeneration(context.Background(), "")
+	if err != nil {
+		s.logger.Error(
+			"Cant restore backup", zap.Error(err))
+	}
+	s.logger.Info("Complete Restore!")
+}
+
+// RestoreGeneration restores the metrics from the named backup generation of the target,
+// or from the most recent one if name is empty.
+//
+// Parameters:
+// - ctx: a context.Context for cancelation of the download
+// - name: the name of the generation as returned by Generations
+//
+// Returns:
+// - an error if the generation cannot be read or decoded
+func (s *Buckuper) RestoreGeneration(ctx context.Context, name string) error {
+	const op = "server.saver.RestoreGeneration"
+
+	rc, err := s.target.Open(ctx, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rc.Close()
+
+	if err = s.Load(rc); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Generations returns the backup generations stored in the target, oldest first.
+//
+// Parameters:
+// - ctx: a context.Context for cancelation of the listing
+//
+// Returns:
+// - the stored generations
+// - an error if the target cannot be listed
+func (s *Buckuper) Generations(ctx context.Context) ([]target.Generation, error) {
+	return s.target.List(ctx)
+}
+
+// Load streams a backup from r and updates the storage with every metric it contains.
+// Encryption, compression and the backup format are detected automatically.
+// Metrics without a value for their type are skipped.
+//
+// Parameters:
+// - r: the source of the backup
+//
+// Returns:
+// - an error if any occurs during reading or decoding
+func (s *Buckuper) Load(r io.Reader) error {
+	const op = "server.saver.Load"
+
 	ctx := context.Background()
 
-	data, err := os.ReadFile(s.storagePath)
+	br, closeReader, err := s.open(r)
 	if err != nil {
-		s.logger.Error(
-			"Cant read json from file", zap.Error(err))
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer closeReader()
 
-	err = json.Unmarshal(data, &s.metrics)
+	dec, err := codec.Detect(br).NewDecoder(br)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
 	if err != nil {
-		s.logger.Error(
-			"Cant decode json", zap.Error(err))
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	for _, sourceMetric := range s.metrics {
+	restored := metrics{}
+	defer func() {
+		s.mu.Lock()
+		s.metrics = restored
+		s.mu.Unlock()
+	}()
+
+	for {
+		sourceMetric, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
 		databaseCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
-		defer cancel()
-		switch sourceMetric.MType {
-		case format.Gauge:
+		switch {
+		case sourceMetric.MType == format.Gauge && sourceMetric.Value != nil:
 			err = s.storage.UpdateGauge(databaseCtx, sourceMetric.ID, *sourceMetric.Value)
 			if err != nil {
 				s.logger.Error("Failed to update gauge value", zap.Error(err))
 			}
-		case format.Counter:
+		case sourceMetric.MType == format.Counter && sourceMetric.Delta != nil:
 			err = s.storage.UpdateCounter(databaseCtx, sourceMetric.ID, *sourceMetric.Delta)
 			if err != nil {
 				s.logger.Error("Failed to update counter value", zap.Error(err))
 			}
 		default:
+			cancel()
+			continue
 		}
+		cancel()
+		restored = append(restored, sourceMetric)
 	}
-	s.logger.Info("Complete Restore!")
+}
+
+// Snapshot streams the current content of the storage to w in the backup format.
+// Parameters:
+// - ctx: a context.Context for the storage request
+// - w: the destination of the snapshot
+// Returns:
+// - an error if the metrics cannot be retrieved or written
+func (s *Buckuper) Snapshot(ctx context.Context, w io.Writer) error {
+	const op = "server.saver.Snapshot"
+
+	if err := s.Refresh(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.Dump(w); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RestoreFrom restores the storage from a backup read from r with the same semantics as Restore:
+// gauges are overwritten and counters are incremented by the restored values.
+// Afterwards the saved metrics are refreshed from the storage and written out in sync mode.
+// Parameters:
+// - ctx: a context.Context for the storage requests
+// - r: the source of the backup
+// Returns:
+// - an error if the backup cannot be decoded or the storage cannot be read
+func (s *Buckuper) RestoreFrom(ctx context.Context, r io.Reader) error {
+	const op = "server.saver.RestoreFrom"
+
+	if err := s.Load(r); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.Refresh(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if s.IsSyncMode() {
+		s.SaveToFile()
+	}
+	return nil
 }
 
 // IsSyncMode returns true if sync mode is enabled
@@ -1,9 +1,11 @@
 package backuper
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -12,6 +14,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/s3"
+	"github.com/mbiwapa/metric/internal/lib/s3/s3test"
+	"github.com/mbiwapa/metric/internal/server/backuper/target"
 )
 
 // Mock implementation of the AllMetricGeter interface for testing purposes.
@@ -155,3 +160,55 @@ func TestStart(t *testing.T) {
 	// Clean up
 	os.Remove("test_metrics.json")
 }
+
+func TestSaveAndRestore_S3Target(t *testing.T) {
+	srv := s3test.NewServer()
+	defer srv.Close()
+
+	client, err := s3.New(srv.URL, "", "access", "secret")
+	require.NoError(t, err)
+	remote := target.NewS3(client, "backups", "metrics/", 0)
+
+	writer, err := New(new(MockAllMetricGeter), 10, "", zap.NewNop(), WithTarget(remote), WithFormat("jsonl"))
+	require.NoError(t, err)
+	require.NoError(t, writer.SaveToStruct(format.Gauge, "testGauge", "123.45"))
+	writer.SaveToFile()
+
+	gens, err := writer.Generations(context.Background())
+	require.NoError(t, err)
+	require.Len(t, gens, 1)
+
+	mockStorage := new(MockAllMetricGeter)
+	mockStorage.On("UpdateGauge", mock.Anything, "testGauge", 123.45).Return(nil)
+
+	reader, err := New(mockStorage, 10, "", zap.NewNop(), WithTarget(remote))
+	require.NoError(t, err)
+	require.NoError(t, reader.RestoreGeneration(context.Background(), gens[0].Name))
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestSnapshotAndRestoreFrom(t *testing.T) {
+	source := new(MockAllMetricGeter)
+	source.On("GetAllMetrics", mock.Anything).Return([][]string{{"testGauge", "123.45"}}, [][]string{{"testCounter", "678"}}, nil)
+
+	writer, err := New(source, 10, filepath.Join(t.TempDir(), "metrics.json"), zap.NewNop())
+	require.NoError(t, err)
+
+	var snapshot bytes.Buffer
+	require.NoError(t, writer.Snapshot(context.Background(), &snapshot))
+
+	destination := new(MockAllMetricGeter)
+	destination.On("UpdateGauge", mock.Anything, "testGauge", 123.45).Return(nil)
+	destination.On("UpdateCounter", mock.Anything, "testCounter", int64(678)).Return(nil)
+	destination.On("GetAllMetrics", mock.Anything).Return([][]string{{"testGauge", "123.45"}}, [][]string{{"testCounter", "678"}}, nil)
+
+	reader, err := New(destination, 0, filepath.Join(t.TempDir(), "metrics.json"), zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, reader.RestoreFrom(context.Background(), &snapshot))
+
+	destination.AssertExpectations(t)
+	gens, err := reader.Generations(context.Background())
+	require.NoError(t, err)
+	require.Len(t, gens, 1)
+}
@@ -0,0 +1,106 @@
+// Package codec provides pluggable serialization formats for metric backups.
+// Every codec writes and reads metrics one at a time, so backups are streamed
+// instead of being loaded into memory as a whole.
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+const (
+	// JSON is an indented JSON array of metrics.
+	JSON = "json"
+
+	// JSONLines is one JSON metric object per line.
+	JSONLines = "jsonl"
+
+	// Gob is a stream of gob-encoded metrics.
+	Gob = "gob"
+
+	// CSV is a comma-separated file with an "id,type,delta,value" header.
+	CSV = "csv"
+)
+
+// ErrUnknownFormat is returned when an unsupported backup format is requested.
+var ErrUnknownFormat = errors.New("unknown backup format")
+
+// Encoder writes metrics to the underlying stream.
+type Encoder interface {
+	// Encode writes a single metric.
+	Encode(m format.Metric) error
+
+	// Close writes any trailing data. It does not close the underlying writer.
+	Close() error
+}
+
+// Decoder reads metrics from the underlying stream.
+type Decoder interface {
+	// Decode returns the next metric or io.EOF when the stream is exhausted.
+	Decode() (format.Metric, error)
+}
+
+// Codec creates encoders and decoders of a single backup format.
+type Codec interface {
+	// Name returns the format name used in the configuration.
+	Name() string
+
+	// NewEncoder returns an Encoder writing to w.
+	NewEncoder(w io.Writer) (Encoder, error)
+
+	// NewDecoder returns a Decoder reading from r.
+	NewDecoder(r io.Reader) (Decoder, error)
+}
+
+// codecs holds all registered formats by name.
+var codecs = map[string]Codec{
+	JSON:      jsonCodec{},
+	JSONLines: jsonLinesCodec{},
+	Gob:       gobCodec{},
+	CSV:       csvCodec{},
+}
+
+// ByName returns the codec registered under the given name.
+// An empty name selects JSON.
+func ByName(name string) (Codec, error) {
+	if name == "" {
+		name = JSON
+	}
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+	}
+	return c, nil
+}
+
+// Detect sniffs the format of the buffered stream without consuming it.
+// CSV starts with its header, a JSON array with '[', JSON Lines with '{',
+// anything else is treated as gob. An empty stream is reported as JSON.
+func Detect(r *bufio.Reader) Codec {
+	head, _ := r.Peek(len(csvHeader))
+	if bytes.Equal(head, []byte(csvHeader)) {
+		return codecs[CSV]
+	}
+
+	for i := 1; ; i++ {
+		head, _ = r.Peek(i)
+		if len(head) < i {
+			return codecs[JSON]
+		}
+		switch head[i-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return codecs[JSON]
+		case '{':
+			return codecs[JSONLines]
+		default:
+			return codecs[Gob]
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+func testMetrics() []format.Metric {
+	value := 123.45
+	delta := int64(678)
+	return []format.Metric{
+		{ID: "testGauge", MType: format.Gauge, Value: &value},
+		{ID: "testCounter", MType: format.Counter, Delta: &delta},
+	}
+}
+
+func TestCodecs_RoundTripAndDetect(t *testing.T) {
+	for _, name := range []string{JSON, JSONLines, Gob, CSV} {
+		t.Run(name, func(t *testing.T) {
+			c, err := ByName(name)
+			require.NoError(t, err)
+			require.Equal(t, name, c.Name())
+
+			var buf bytes.Buffer
+			enc, err := c.NewEncoder(&buf)
+			require.NoError(t, err)
+			for _, m := range testMetrics() {
+				require.NoError(t, enc.Encode(m))
+			}
+			require.NoError(t, enc.Close())
+
+			br := bufio.NewReader(&buf)
+			detected := Detect(br)
+			require.Equal(t, name, detected.Name())
+
+			dec, err := detected.NewDecoder(br)
+			require.NoError(t, err)
+			var got []format.Metric
+			for {
+				m, err := dec.Decode()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				got = append(got, m)
+			}
+			require.Equal(t, testMetrics(), got)
+		})
+	}
+}
+
+func TestJSON_EmptyAndLegacy(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := jsonCodec{}.NewEncoder(&buf)
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+	require.Equal(t, "[]", buf.String())
+
+	legacy := "  [{\"id\":\"a\",\"type\":\"gauge\",\"value\":1}]"
+	br := bufio.NewReader(bytes.NewBufferString(legacy))
+	dec, err := Detect(br).NewDecoder(br)
+	require.NoError(t, err)
+	m, err := dec.Decode()
+	require.NoError(t, err)
+	require.Equal(t, "a", m.ID)
+	_, err = dec.Decode()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestByName_Unknown(t *testing.T) {
+	_, err := ByName("xml")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+// csvHeader is the first line of every CSV backup.
+const csvHeader = "id,type,delta,value"
+
+// csvCodec writes metrics as CSV rows.
+type csvCodec struct{}
+
+// Name returns the format name.
+func (csvCodec) Name() string { return CSV }
+
+// NewEncoder returns an encoder that writes the header followed by a row per metric.
+func (csvCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "type", "delta", "value"}); err != nil {
+		return nil, err
+	}
+	return &csvEncoder{w: cw}, nil
+}
+
+// NewDecoder returns a decoder that skips the header and reads a row per metric.
+func (csvCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 4
+	cr.ReuseRecord = true
+	if _, err := cr.Read(); err != nil {
+		return nil, err
+	}
+	return &csvDecoder{r: cr}, nil
+}
+
+// csvEncoder writes metrics as CSV rows.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+// Encode writes a single row. Only the field matching the metric type is filled.
+func (e *csvEncoder) Encode(m format.Metric) error {
+	var delta, value string
+	if m.Delta != nil {
+		delta = strconv.FormatInt(*m.Delta, 10)
+	}
+	if m.Value != nil {
+		value = strconv.FormatFloat(*m.Value, 'g', -1, 64)
+	}
+	return e.w.Write([]string{m.ID, m.MType, delta, value})
+}
+
+// Close flushes buffered rows.
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvDecoder reads metrics from CSV rows.
+type csvDecoder struct {
+	r *csv.Reader
+}
+
+// Decode returns the metric from the next row.
+func (d *csvDecoder) Decode() (format.Metric, error) {
+	var m format.Metric
+	rec, err := d.r.Read()
+	if err != nil {
+		return m, err
+	}
+	m.ID, m.MType = rec[0], rec[1]
+	if rec[2] != "" {
+		delta, err := strconv.ParseInt(rec[2], 10, 64)
+		if err != nil {
+			return m, fmt.Errorf("codec.csv: %w", err)
+		}
+		m.Delta = &delta
+	}
+	if rec[3] != "" {
+		value, err := strconv.ParseFloat(rec[3], 64)
+		if err != nil {
+			return m, fmt.Errorf("codec.csv: %w", err)
+		}
+		m.Value = &value
+	}
+	return m, nil
+}
@@ -0,0 +1,51 @@
+package codec
+
+import (
+	"encoding/gob"
+	"io"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+// gobCodec writes a stream of gob-encoded metrics.
+type gobCodec struct{}
+
+// Name returns the format name.
+func (gobCodec) Name() string { return Gob }
+
+// NewEncoder returns a gob encoder.
+func (gobCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	return &gobEncoder{enc: gob.NewEncoder(w)}, nil
+}
+
+// NewDecoder returns a gob decoder.
+func (gobCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	return &gobDecoder{dec: gob.NewDecoder(r)}, nil
+}
+
+// gobEncoder writes metrics with encoding/gob.
+type gobEncoder struct {
+	enc *gob.Encoder
+}
+
+// Encode writes a single metric.
+func (e *gobEncoder) Encode(m format.Metric) error {
+	return e.enc.Encode(m)
+}
+
+// Close does nothing, gob streams have no trailer.
+func (e *gobEncoder) Close() error {
+	return nil
+}
+
+// gobDecoder reads metrics with encoding/gob.
+type gobDecoder struct {
+	dec *gob.Decoder
+}
+
+// Decode returns the next metric.
+func (d *gobDecoder) Decode() (format.Metric, error) {
+	var m format.Metric
+	err := d.dec.Decode(&m)
+	return m, err
+}
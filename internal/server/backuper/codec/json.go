@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+// jsonCodec writes an indented JSON array, the format of the original backup file.
+type jsonCodec struct{}
+
+// Name returns the format name.
+func (jsonCodec) Name() string { return JSON }
+
+// NewEncoder returns an encoder that writes the array element by element.
+func (jsonCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	return &jsonEncoder{w: w}, nil
+}
+
+// NewDecoder returns a decoder that reads the array element by element.
+func (jsonCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	return &jsonDecoder{dec: json.NewDecoder(r)}, nil
+}
+
+// jsonEncoder streams metrics as elements of a JSON array.
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+// Encode writes a single array element.
+func (e *jsonEncoder) Encode(m format.Metric) error {
+	data, err := json.MarshalIndent(m, "   ", "   ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n   "
+	if e.count == 0 {
+		sep = "[\n   "
+	}
+	e.count++
+	if _, err = io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// Close terminates the array.
+func (e *jsonEncoder) Close() error {
+	end := "\n]"
+	if e.count == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// jsonDecoder reads elements of a JSON array one at a time.
+type jsonDecoder struct {
+	dec     *json.Decoder
+	started bool
+}
+
+// Decode returns the next array element.
+func (d *jsonDecoder) Decode() (format.Metric, error) {
+	var m format.Metric
+	if !d.started {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return m, err
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			return m, fmt.Errorf("codec.json: expected array, got %v", tok)
+		}
+		d.started = true
+	}
+	if !d.dec.More() {
+		return m, io.EOF
+	}
+	err := d.dec.Decode(&m)
+	return m, err
+}
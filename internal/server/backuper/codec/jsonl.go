@@ -0,0 +1,51 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+// jsonLinesCodec writes one JSON object per line, which suits large metric sets.
+type jsonLinesCodec struct{}
+
+// Name returns the format name.
+func (jsonLinesCodec) Name() string { return JSONLines }
+
+// NewEncoder returns an encoder that writes a JSON object per line.
+func (jsonLinesCodec) NewEncoder(w io.Writer) (Encoder, error) {
+	return &jsonLinesEncoder{enc: json.NewEncoder(w)}, nil
+}
+
+// NewDecoder returns a decoder that reads a JSON object per line.
+func (jsonLinesCodec) NewDecoder(r io.Reader) (Decoder, error) {
+	return &jsonLinesDecoder{dec: json.NewDecoder(r)}, nil
+}
+
+// jsonLinesEncoder writes metrics as newline-delimited JSON.
+type jsonLinesEncoder struct {
+	enc *json.Encoder
+}
+
+// Encode writes a single line.
+func (e *jsonLinesEncoder) Encode(m format.Metric) error {
+	return e.enc.Encode(m)
+}
+
+// Close does nothing, JSON Lines has no trailer.
+func (e *jsonLinesEncoder) Close() error {
+	return nil
+}
+
+// jsonLinesDecoder reads newline-delimited JSON.
+type jsonLinesDecoder struct {
+	dec *json.Decoder
+}
+
+// Decode returns the next metric.
+func (d *jsonLinesDecoder) Decode() (format.Metric, error) {
+	var m format.Metric
+	err := d.dec.Decode(&m)
+	return m, err
+}
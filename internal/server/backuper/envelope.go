@@ -0,0 +1,368 @@
+package backuper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/mbiwapa/metric/internal/server/backuper/target"
+)
+
+const (
+	// CompressionNone disables compression of the backup file.
+	CompressionNone = "none"
+
+	// CompressionGzip compresses the backup file with gzip.
+	CompressionGzip = "gzip"
+
+	// CompressionZstd compresses the backup file with zstd.
+	CompressionZstd = "zstd"
+)
+
+// chunkSize is the amount of plaintext sealed into a single encrypted frame.
+const chunkSize = 64 * 1024
+
+var (
+	// ErrUnknownCompression is returned when an unsupported compression algorithm is requested.
+	ErrUnknownCompression = errors.New("unknown backup compression")
+
+	// ErrEncryptedBackup is returned when an encrypted backup is restored without a key.
+	ErrEncryptedBackup = errors.New("backup is encrypted but no key is configured")
+
+	// ErrTruncatedBackup is returned when an encrypted backup ends before its final frame.
+	ErrTruncatedBackup = errors.New("encrypted backup is truncated")
+)
+
+// Magic prefixes used to detect the backup layout on restore.
+var (
+	gzipMagic      = []byte{0x1f, 0x8b}
+	zstdMagic      = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	encryptedMagic = []byte("MBAK\x01")
+)
+
+// Option configures optional behaviour of the Buckuper.
+type Option func(*Buckuper)
+
+// WithCompression sets the compression algorithm applied to the backup file.
+// Supported values are CompressionNone, CompressionGzip and CompressionZstd;
+// an empty string is treated as CompressionNone.
+func WithCompression(compression string) Option {
+	return func(s *Buckuper) {
+		s.compression = compression
+	}
+}
+
+// WithEncryptionKey enables AES-256-GCM encryption of the backup file.
+// The key material may be of any length, it is stretched with SHA-256.
+// An empty key leaves encryption disabled.
+func WithEncryptionKey(key []byte) Option {
+	return func(s *Buckuper) {
+		if len(key) == 0 {
+			return
+		}
+		sum := sha256.Sum256(key)
+		s.encryptionKey = sum[:]
+	}
+}
+
+// WithFormat sets the serialization format of the backup file by its codec name.
+// Restore detects the format of existing files regardless of this setting.
+func WithFormat(name string) Option {
+	return func(s *Buckuper) {
+		s.format = name
+	}
+}
+
+// WithTarget sets the destination of the backups instead of the local file at the storage path.
+func WithTarget(t target.Target) Option {
+	return func(s *Buckuper) {
+		s.target = t
+	}
+}
+
+// ValidCompression reports whether the given compression algorithm is supported.
+func ValidCompression(compression string) bool {
+	switch compression {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return true
+	default:
+		return false
+	}
+}
+
+// seal wraps w so that everything written to the returned writer is compressed and,
+// if a key is configured, encrypted. Closing the returned writer flushes all layers
+// but does not close w.
+func (s *Buckuper) seal(w io.Writer) (io.WriteCloser, error) {
+	const op = "server.backuper.seal"
+
+	var layers []io.Closer
+	if s.encryptionKey != nil {
+		ew, err := newEncryptWriter(w, s.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		layers = append(layers, ew)
+		w = ew
+	}
+
+	switch s.compression {
+	case "", CompressionNone:
+	case CompressionGzip:
+		zw := gzip.NewWriter(w)
+		layers = append(layers, zw)
+		w = zw
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		layers = append(layers, zw)
+		w = zw
+	default:
+		return nil, fmt.Errorf("%s: %w", op, ErrUnknownCompression)
+	}
+
+	return &layeredWriter{Writer: w, layers: layers}, nil
+}
+
+// open reverses seal. The layout is detected from the stream itself,
+// so plain backups written without compression or encryption are returned as is.
+// The returned reader is buffered and can be peeked to sniff the backup format.
+func (s *Buckuper) open(r io.Reader) (*bufio.Reader, func() error, error) {
+	const op = "server.backuper.open"
+
+	closers := make([]func() error, 0, 1)
+	closeAll := func() error {
+		var err error
+		for _, c := range closers {
+			err = errors.Join(err, c())
+		}
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	if hasPrefix(br, encryptedMagic) {
+		if s.encryptionKey == nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, ErrEncryptedBackup)
+		}
+		dr, err := newDecryptReader(br, s.encryptionKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		br = bufio.NewReader(dr)
+	}
+
+	switch {
+	case hasPrefix(br, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		closers = append(closers, zr.Close)
+		br = bufio.NewReader(zr)
+	case hasPrefix(br, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		closers = append(closers, func() error { zr.Close(); return nil })
+		br = bufio.NewReader(zr)
+	}
+
+	return br, closeAll, nil
+}
+
+// hasPrefix reports whether the buffered stream starts with prefix without consuming it.
+func hasPrefix(r *bufio.Reader, prefix []byte) bool {
+	head, _ := r.Peek(len(prefix))
+	return bytes.Equal(head, prefix)
+}
+
+// layeredWriter closes the stacked writers from the innermost to the outermost.
+type layeredWriter struct {
+	io.Writer
+	layers []io.Closer
+}
+
+// Close flushes and closes every layer in reverse order of creation.
+func (l *layeredWriter) Close() error {
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		if err := l.layers[i].Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptWriter splits the stream into frames sealed with AES-GCM.
+// Each frame is written as a flag byte, a big-endian uint32 length and the ciphertext.
+// The nonce is the random prefix from the header followed by the frame counter,
+// and the last frame is marked so that truncation is detected on restore.
+type encryptWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+// newEncryptWriter writes the backup header and returns a writer that encrypts frames into w.
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, gcm.NonceSize()-4)
+	if _, err = io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(append(append([]byte{}, encryptedMagic...), prefix...)); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, gcm: gcm, prefix: prefix, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// Write buffers p and seals every full chunk.
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		free := chunkSize - len(e.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		e.buf = append(e.buf, p[:free]...)
+		p = p[free:]
+		if len(e.buf) == chunkSize {
+			if err := e.flush(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close seals the remaining buffered data as the final frame.
+func (e *encryptWriter) Close() error {
+	return e.flush(true)
+}
+
+// flush seals the buffered data into a single frame.
+func (e *encryptWriter) flush(final bool) error {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	ct := e.gcm.Seal(nil, frameNonce(e.prefix, e.counter), e.buf, frameAD(flag))
+	e.counter++
+	e.buf = e.buf[:0]
+
+	header := make([]byte, 5)
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(ct)))
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	_, err := e.w.Write(ct)
+	return err
+}
+
+// decryptReader reads frames written by encryptWriter and returns the plaintext.
+type decryptReader struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	final   bool
+}
+
+// newDecryptReader consumes the backup header from r and returns a reader of the plaintext.
+func newDecryptReader(r io.Reader, key []byte) (*decryptReader, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptedMagic)+gcm.NonceSize()-4)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: r, gcm: gcm, prefix: header[len(encryptedMagic):]}, nil
+}
+
+// Read returns decrypted data, opening the next frame when the current one is exhausted.
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.final {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// next reads and opens a single frame.
+func (d *decryptReader) next() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncatedBackup
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > chunkSize+uint32(d.gcm.Overhead()) {
+		return ErrTruncatedBackup
+	}
+	ct := make([]byte, size)
+	if _, err := io.ReadFull(d.r, ct); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncatedBackup
+		}
+		return err
+	}
+	pt, err := d.gcm.Open(nil, frameNonce(d.prefix, d.counter), ct, frameAD(header[0]))
+	if err != nil {
+		return err
+	}
+	d.counter++
+	d.buf = pt
+	d.final = header[0] == 1
+	return nil
+}
+
+// frameNonce builds the nonce of the frame with the given sequence number.
+func frameNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, len(prefix)+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], counter)
+	return nonce
+}
+
+// frameAD binds the backup header and the final-frame flag to the ciphertext.
+func frameAD(flag byte) []byte {
+	return append(append([]byte{}, encryptedMagic...), flag)
+}
+
+// newGCM creates an AES-GCM cipher for the given 32-byte key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
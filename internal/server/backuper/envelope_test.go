@@ -0,0 +1,102 @@
+package backuper
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+)
+
+func TestSaveAndRestore_CompressionAndEncryption(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		key         []byte
+		wantPrefix  []byte
+	}{
+		{name: "gzip", compression: CompressionGzip, wantPrefix: gzipMagic},
+		{name: "zstd", compression: CompressionZstd, wantPrefix: zstdMagic},
+		{name: "encrypted", compression: CompressionNone, key: []byte("secret"), wantPrefix: encryptedMagic},
+		{name: "zstd and encrypted", compression: CompressionZstd, key: []byte("secret"), wantPrefix: encryptedMagic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metrics.bak")
+
+			writer, err := New(new(MockAllMetricGeter), 10, path, zap.NewNop(),
+				WithCompression(tt.compression), WithEncryptionKey(tt.key))
+			require.NoError(t, err)
+			require.NoError(t, writer.SaveToStruct(format.Gauge, "testGauge", "123.45"))
+			require.NoError(t, writer.SaveToStruct(format.Counter, "testCounter", "678"))
+			writer.SaveToFile()
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantPrefix, data[:len(tt.wantPrefix)])
+			require.NotContains(t, string(data), "testGauge")
+
+			mockStorage := new(MockAllMetricGeter)
+			mockStorage.On("UpdateGauge", mock.Anything, "testGauge", 123.45).Return(nil)
+			mockStorage.On("UpdateCounter", mock.Anything, "testCounter", int64(678)).Return(nil)
+
+			reader, err := New(mockStorage, 10, path, zap.NewNop(), WithEncryptionKey(tt.key))
+			require.NoError(t, err)
+			reader.Restore()
+
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	writer, err := New(new(MockAllMetricGeter), 10, "metrics.bak", zap.NewNop(), WithEncryptionKey([]byte("secret")))
+	require.NoError(t, err)
+	var sealed bytes.Buffer
+	require.NoError(t, writer.Dump(&sealed))
+
+	withoutKey, err := New(new(MockAllMetricGeter), 10, "metrics.bak", zap.NewNop())
+	require.NoError(t, err)
+	require.ErrorIs(t, withoutKey.Load(bytes.NewReader(sealed.Bytes())), ErrEncryptedBackup)
+
+	wrongKey, err := New(new(MockAllMetricGeter), 10, "metrics.bak", zap.NewNop(), WithEncryptionKey([]byte("other")))
+	require.NoError(t, err)
+	require.Error(t, wrongKey.Load(bytes.NewReader(sealed.Bytes())))
+
+	truncated := sealed.Bytes()[:sealed.Len()-1]
+	require.Error(t, writer.Load(bytes.NewReader(truncated)))
+}
+
+func TestSealAndOpen_LargeStream(t *testing.T) {
+	s, err := New(new(MockAllMetricGeter), 10, "metrics.bak", zap.NewNop(),
+		WithCompression(CompressionGzip), WithEncryptionKey([]byte("secret")))
+	require.NoError(t, err)
+
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 3*chunkSize/16+7)
+
+	var sealed bytes.Buffer
+	w, err := s.seal(&sealed)
+	require.NoError(t, err)
+	_, err = w.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, closeReader, err := s.open(&sealed)
+	require.NoError(t, err)
+	defer closeReader()
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, payload, got)
+}
+
+func TestNew_UnknownCompression(t *testing.T) {
+	_, err := New(new(MockAllMetricGeter), 10, "metrics.bak", zap.NewNop(), WithCompression("lz4"))
+	require.ErrorIs(t, err, ErrUnknownCompression)
+}
@@ -0,0 +1,89 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File is a target that keeps a single generation in a local file.
+// Every save atomically replaces the file.
+type File struct {
+	path string
+}
+
+// NewFile creates a target writing to the file at path.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Save streams the backup into a temporary file next to the target file and renames it over the target.
+func (f *File) Save(_ context.Context, write func(w io.Writer) error) (Generation, error) {
+	const op = "server.backuper.target.File.Save"
+
+	file, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(file.Name())
+
+	err = write(file)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = os.Rename(file.Name(), f.path); err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return f.stat()
+}
+
+// Open opens the backup file. The only generation is named after the file,
+// so any name other than that or an empty one is reported as missing.
+func (f *File) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	const op = "server.backuper.target.File.Open"
+
+	if name != "" && name != filepath.Base(f.path) {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoBackup)
+	}
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoBackup)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return file, nil
+}
+
+// List returns the backup file as the only generation, or nothing if it does not exist.
+func (f *File) List(_ context.Context) ([]Generation, error) {
+	gen, err := f.stat()
+	if errors.Is(err, ErrNoBackup) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []Generation{gen}, nil
+}
+
+// stat describes the backup file as a generation.
+func (f *File) stat() (Generation, error) {
+	const op = "server.backuper.target.File.stat"
+
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Generation{}, fmt.Errorf("%s: %w", op, ErrNoBackup)
+	}
+	if err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return Generation{Name: filepath.Base(f.path), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
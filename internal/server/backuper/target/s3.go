@@ -0,0 +1,149 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mbiwapa/metric/internal/lib/s3"
+)
+
+// ObjectStorage is the subset of an S3 client used by the S3 target.
+type ObjectStorage interface {
+	// PutObject uploads size bytes from body under the given key.
+	PutObject(ctx context.Context, bucket, key string, body io.Reader, size int64) error
+
+	// GetObject returns the content of the object with the given key.
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+
+	// DeleteObject removes the object with the given key.
+	DeleteObject(ctx context.Context, bucket, key string) error
+
+	// ListObjects returns all objects whose keys start with prefix.
+	ListObjects(ctx context.Context, bucket, prefix string) ([]s3.Object, error)
+}
+
+// S3 is a target that uploads every backup as a new object to an S3-compatible storage.
+// Objects are named after the time of the save, so they sort chronologically,
+// and only the newest keep generations are retained.
+type S3 struct {
+	client ObjectStorage
+	bucket string
+	prefix string
+	keep   int
+	now    func() time.Time
+}
+
+// NewS3 creates an S3 target.
+//
+// Parameters:
+//   - client: the object storage client.
+//   - bucket: the bucket to store backups in.
+//   - prefix: the key prefix of backup objects, e.g. "metrics/".
+//   - keep: the number of generations to retain, zero or less keeps all.
+func NewS3(client ObjectStorage, bucket, prefix string, keep int) *S3 {
+	return &S3{client: client, bucket: bucket, prefix: prefix, keep: keep, now: time.Now}
+}
+
+// Save spools the backup into a temporary file, uploads it as a new generation
+// and removes generations exceeding the retention limit.
+func (t *S3) Save(ctx context.Context, write func(w io.Writer) error) (Generation, error) {
+	const op = "server.backuper.target.S3.Save"
+
+	spool, err := os.CreateTemp("", "metrics-backup-*.tmp")
+	if err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if err = write(spool); err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = spool.Seek(0, io.SeekStart); err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := t.now().UTC()
+	name := "metrics-" + now.Format("20060102T150405.000000000Z") + ".bak"
+	if err = t.client.PutObject(ctx, t.bucket, t.prefix+name, spool, size); err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = t.prune(ctx); err != nil {
+		return Generation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return Generation{Name: name, Size: size, ModTime: now}, nil
+}
+
+// Open downloads the generation with the given name, or the newest one if name is empty.
+func (t *S3) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	const op = "server.backuper.target.S3.Open"
+
+	if name == "" {
+		gens, err := t.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(gens) == 0 {
+			return nil, fmt.Errorf("%s: %w", op, ErrNoBackup)
+		}
+		name = gens[len(gens)-1].Name
+	}
+
+	rc, err := t.client.GetObject(ctx, t.bucket, t.prefix+name)
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoBackup)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return rc, nil
+}
+
+// List returns the stored generations, oldest first.
+func (t *S3) List(ctx context.Context) ([]Generation, error) {
+	const op = "server.backuper.target.S3.List"
+
+	objects, err := t.client.ListObjects(ctx, t.bucket, t.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	gens := make([]Generation, 0, len(objects))
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, t.prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+		gens = append(gens, Generation{Name: name, Size: o.Size, ModTime: o.LastModified})
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i].Name < gens[j].Name })
+	return gens, nil
+}
+
+// prune deletes the oldest generations exceeding the retention limit.
+func (t *S3) prune(ctx context.Context) error {
+	if t.keep <= 0 {
+		return nil
+	}
+	gens, err := t.List(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(gens)-t.keep; i++ {
+		if err = t.client.DeleteObject(ctx, t.bucket, t.prefix+gens[i].Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package target provides destinations for metric backups.
+// A target stores generations of backups and allows listing and reading them back.
+package target
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNoBackup is returned when a target does not hold any backup generation.
+var ErrNoBackup = errors.New("no backup found")
+
+// Generation describes a single stored backup.
+type Generation struct {
+	Name    string    `json:"name"`     // Name identifies the generation within the target.
+	Size    int64     `json:"size"`     // Size is the size of the backup in bytes.
+	ModTime time.Time `json:"mod_time"` // ModTime is the time the backup was written.
+}
+
+// Target is a destination for backups.
+type Target interface {
+	// Save stores a new generation. The content is produced by write, which streams it into w.
+	Save(ctx context.Context, write func(w io.Writer) error) (Generation, error)
+
+	// Open returns the content of the generation with the given name,
+	// or of the most recent generation if name is empty.
+	// The caller must close the returned reader.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// List returns all stored generations, oldest first.
+	List(ctx context.Context) ([]Generation, error)
+}
@@ -0,0 +1,94 @@
+package target
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbiwapa/metric/internal/lib/s3"
+	"github.com/mbiwapa/metric/internal/lib/s3/s3test"
+)
+
+func writeString(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func readAll(t *testing.T, rc io.ReadCloser) string {
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	f := NewFile(filepath.Join(t.TempDir(), "metrics.json"))
+
+	gens, err := f.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, gens)
+	_, err = f.Open(ctx, "")
+	require.ErrorIs(t, err, ErrNoBackup)
+
+	_, err = f.Save(ctx, writeString("first"))
+	require.NoError(t, err)
+	gen, err := f.Save(ctx, writeString("second"))
+	require.NoError(t, err)
+	require.Equal(t, "metrics.json", gen.Name)
+	require.Equal(t, int64(6), gen.Size)
+
+	gens, err = f.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, gens, 1)
+
+	rc, err := f.Open(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "second", readAll(t, rc))
+}
+
+func TestS3(t *testing.T) {
+	srv := s3test.NewServer()
+	defer srv.Close()
+
+	client, err := s3.New(srv.URL, "", "access", "secret")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	target := NewS3(client, "bucket", "metrics/", 2)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	target.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	_, err = target.Open(ctx, "")
+	require.ErrorIs(t, err, ErrNoBackup)
+
+	for _, content := range []string{"one", "two", "three"} {
+		_, err = target.Save(ctx, writeString(content))
+		require.NoError(t, err)
+	}
+
+	gens, err := target.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, gens, 2)
+	require.Equal(t, []string{"metrics/" + gens[0].Name, "metrics/" + gens[1].Name}, srv.Keys("bucket"))
+
+	rc, err := target.Open(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "three", readAll(t, rc))
+
+	rc, err = target.Open(ctx, gens[0].Name)
+	require.NoError(t, err)
+	require.Equal(t, "two", readAll(t, rc))
+
+	_, err = target.Open(ctx, "missing.bak")
+	require.ErrorIs(t, err, ErrNoBackup)
+}
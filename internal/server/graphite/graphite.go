@@ -0,0 +1,383 @@
+// Package graphite provides a listener for the Graphite plaintext protocol.
+// Every line has the form "path value timestamp" and is stored as a gauge.
+// Graphite tags ("path;tag=value") are kept as labels of the series.
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/realip"
+	"github.com/mbiwapa/metric/internal/lib/series"
+)
+
+const (
+	// flushInterval is the longest time a received value waits before it is stored.
+	flushInterval = time.Second
+
+	// maxBatch is the number of values that triggers an immediate flush.
+	maxBatch = 1000
+
+	// idleTimeout closes TCP connections that send nothing for this long.
+	idleTimeout = 2 * time.Minute
+
+	// maxDatagram is the largest UDP datagram that is read.
+	maxDatagram = 64 * 1024
+)
+
+// ErrInvalidLine is returned for lines that do not follow the plaintext protocol.
+var ErrInvalidLine = errors.New("invalid graphite line")
+
+// Updater interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Updater
+type Updater interface {
+	// UpdateBatch updates a batch of gauge and counter metrics in the storage.
+	// It takes a context for cancellation, and slices of gauge and counter metrics.
+	UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error
+}
+
+// Backuper interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Backuper
+type Backuper interface {
+	// IsSyncMode checks if the backup is in synchronous mode.
+	IsSyncMode() bool
+
+	// Refresh copies the current storage state into the backup structure.
+	Refresh(ctx context.Context) error
+
+	// SaveToFile saves the backup structure to a file.
+	SaveToFile()
+}
+
+// Server accepts Graphite plaintext metrics over TCP and, optionally, UDP.
+// Values from all connections are collected and written to the storage in batches.
+type Server struct {
+	addr     string
+	udp      bool
+	maxConns int
+	trusted  *net.IPNet
+	storage  Updater
+	backup   Backuper
+	log      *zap.Logger
+
+	values chan []string
+	conns  map[net.Conn]struct{}
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+}
+
+// Option configures optional behaviour of the Server.
+type Option func(*Server)
+
+// WithUDP additionally listens for datagrams on the same address.
+func WithUDP(enabled bool) Option {
+	return func(s *Server) {
+		s.udp = enabled
+	}
+}
+
+// WithMaxConnections limits the number of simultaneous TCP connections.
+// Connections over the limit are closed right after they are accepted. Zero means no limit.
+func WithMaxConnections(n int) Option {
+	return func(s *Server) {
+		s.maxConns = n
+	}
+}
+
+// WithTrustedSubnet accepts connections and datagrams only from the given subnet.
+// The plaintext protocol cannot report the address of the agent, the peer address is checked.
+// A nil subnet accepts every peer.
+func WithTrustedSubnet(subnet *net.IPNet) Option {
+	return func(s *Server) {
+		s.trusted = subnet
+	}
+}
+
+// New creates a Graphite listener.
+//
+// Parameters:
+//   - addr: the listen address, e.g. ":2003".
+//   - storage: the storage that receives the gauges.
+//   - backup: An implementation of the Backuper interface, used in synchronous mode.
+//   - log: A zap.Logger instance for logging.
+//   - opts: optional settings.
+//
+// Returns:
+//   - *Server: the listener, started with ListenAndServe.
+func New(addr string, storage Updater, backup Backuper, log *zap.Logger, opts ...Option) *Server {
+	s := &Server{
+		addr:    addr,
+		storage: storage,
+		backup:  backup,
+		log:     log.With(zap.String("component", "server/graphite")),
+		values:  make(chan []string, maxBatch),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListenAndServe listens on the configured address and serves connections until ctx is done.
+// On shutdown the listeners and open connections are closed, the values received so far
+// are flushed to the storage and only then ListenAndServe returns.
+//
+// Parameters:
+//   - ctx: the context that stops the server.
+//
+// Returns:
+//   - error: an error if a listener cannot be opened, nil after a graceful shutdown.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	const op = "server.graphite.ListenAndServe"
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	var pc net.PacketConn
+	if s.udp {
+		pc, err = net.ListenPacket("udp", s.addr)
+		if err != nil {
+			ln.Close()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	s.log.Info("Starting graphite listener", zap.String("addr", s.addr), zap.Bool("udp", s.udp))
+
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		s.flushLoop()
+	}()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.acceptLoop(ln)
+	}()
+	if pc != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveUDP(pc)
+		}()
+	}
+
+	<-ctx.Done()
+	s.log.Info("Shutdown graphite listener")
+	ln.Close()
+	if pc != nil {
+		pc.Close()
+	}
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	close(s.values)
+	<-flushed
+	return nil
+}
+
+// acceptLoop accepts TCP connections until the listener is closed.
+func (s *Server) acceptLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.Error("Accept failed", zap.Error(err))
+			}
+			return
+		}
+
+		if !s.isTrusted(conn.RemoteAddr()) {
+			s.log.Warn("Graphite connection from an untrusted address", zap.String("remote", conn.RemoteAddr().String()))
+			conn.Close()
+			continue
+		}
+
+		s.mu.Lock()
+		if s.maxConns > 0 && len(s.conns) >= s.maxConns {
+			s.mu.Unlock()
+			s.log.Warn("Too many graphite connections", zap.String("remote", conn.RemoteAddr().String()))
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn reads lines from a TCP connection until it is closed or idle.
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	sc := bufio.NewScanner(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if !sc.Scan() {
+			break
+		}
+		s.handleLine(sc.Text())
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.log.Debug("Graphite connection closed", zap.Error(err))
+	}
+}
+
+// serveUDP reads datagrams until the connection is closed. A datagram may hold several lines.
+func (s *Server) serveUDP(pc net.PacketConn) {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.Error("UDP read failed", zap.Error(err))
+			}
+			return
+		}
+		if !s.isTrusted(addr) {
+			s.log.Debug("Graphite datagram from an untrusted address", zap.String("remote", addr.String()))
+			continue
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.handleLine(line)
+		}
+	}
+}
+
+// isTrusted reports whether the peer is in the trusted subnet.
+func (s *Server) isTrusted(addr net.Addr) bool {
+	if s.trusted == nil {
+		return true
+	}
+	ip := realip.Resolve("", addr.String())
+	return ip != nil && s.trusted.Contains(ip)
+}
+
+// handleLine parses a line and queues the value for the next flush.
+func (s *Server) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	name, value, err := ParseLine(line)
+	if err != nil {
+		s.log.Warn("Skipping graphite line", zap.String("line", line), zap.Error(err))
+		return
+	}
+	s.values <- []string{name, value}
+}
+
+// flushLoop writes queued values to the storage every flushInterval or when maxBatch is reached,
+// and saves the backup after every write in synchronous mode. It returns after the values channel is closed and the last batch is written.
+func (s *Server) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch [][]string
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := s.storage.UpdateBatch(ctx, batch, nil)
+		if err != nil {
+			s.log.Error("Failed to store graphite metrics", zap.Int("count", len(batch)), zap.Error(err))
+		} else if s.backup.IsSyncMode() {
+			if err = s.backup.Refresh(ctx); err != nil {
+				s.log.Error("Cannot backup metrics", zap.Error(err))
+			} else {
+				s.backup.SaveToFile()
+			}
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case v, ok := <-s.values:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, v)
+			if len(batch) >= maxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// ParseLine parses a plaintext protocol line "path value [timestamp]".
+// Tags appended to the path as ";tag=value" become labels of the series.
+//
+// Parameters:
+//   - line: the line without the trailing newline.
+//
+// Returns:
+//   - string: the storage name of the series.
+//   - string: the gauge value.
+//   - error: ErrInvalidLine if the line is malformed.
+func ParseLine(line string) (string, string, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return "", "", fmt.Errorf("%w: expected path, value and timestamp", ErrInvalidLine)
+	}
+
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: invalid value %q", ErrInvalidLine, fields[1])
+	}
+	if len(fields) == 3 {
+		if _, err = strconv.ParseFloat(fields[2], 64); err != nil {
+			return "", "", fmt.Errorf("%w: invalid timestamp %q", ErrInvalidLine, fields[2])
+		}
+	}
+
+	parts := strings.Split(fields[0], ";")
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("%w: empty path", ErrInvalidLine)
+	}
+	var tags map[string]string
+	for _, tag := range parts[1:] {
+		k, val, ok := strings.Cut(tag, "=")
+		if !ok || k == "" || val == "" {
+			return "", "", fmt.Errorf("%w: invalid tag %q", ErrInvalidLine, tag)
+		}
+		if tags == nil {
+			tags = make(map[string]string, len(parts)-1)
+		}
+		tags[k] = val
+	}
+
+	return series.Format(parts[0], tags), strconv.FormatFloat(v, 'f', -1, 64), nil
+}
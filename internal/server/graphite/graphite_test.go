@@ -0,0 +1,175 @@
+package graphite
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/server/graphite/mocks"
+)
+
+// recordingStorage returns an Updater mock that accepts every batch and a function
+// looking up the last stored value of a gauge.
+func recordingStorage(t *testing.T) (*mocks.Updater, func(name string) (string, bool)) {
+	var (
+		mu     sync.Mutex
+		gauges = make(map[string]string)
+	)
+	storage := mocks.NewUpdater(t)
+	storage.On("UpdateBatch", mock.Anything, mock.Anything, [][]string(nil)).
+		Run(func(args mock.Arguments) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, g := range args.Get(1).([][]string) {
+				gauges[g[0]] = g[1]
+			}
+		}).
+		Return(nil)
+
+	return storage, func(name string) (string, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		v, ok := gauges[name]
+		return v, ok
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	return addr
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{line: "servers.web01.cpu 12.5 1700000000", wantName: "servers.web01.cpu", wantValue: "12.5"},
+		{line: "disk.used;host=web01;mount=/ 42 1700000000", wantName: `disk.used{host="web01",mount="/"}`, wantValue: "42"},
+		{line: "load 1", wantName: "load", wantValue: "1"},
+		{line: "load", wantErr: true},
+		{line: "load abc 1700000000", wantErr: true},
+		{line: "load 1 yesterday", wantErr: true},
+		{line: "load;host 1 1700000000", wantErr: true},
+		{line: ";host=a 1 1700000000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			name, value, err := ParseLine(tt.line)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantName, name)
+			require.Equal(t, tt.wantValue, value)
+		})
+	}
+}
+
+func TestServer_TCPAndUDP(t *testing.T) {
+	addr := freeAddr(t)
+	storage, get := recordingStorage(t)
+	// Every stored batch is saved to the backup in synchronous mode.
+	backup := mocks.NewBackuper(t)
+	backup.On("IsSyncMode").Return(true)
+	backup.On("Refresh", mock.Anything).Return(nil)
+	backup.On("SaveToFile").Return()
+	srv := New(addr, storage, backup, zap.NewNop(), WithUDP(true), WithMaxConnections(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(ctx) }()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	_, err := conn.Write([]byte("tcp.metric 1.5 1700000000\nbroken line here too\n"))
+	require.NoError(t, err)
+
+	// The second connection is over the limit and is closed by the server.
+	extra, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	extra.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = extra.Read(make([]byte, 1))
+	require.Error(t, err)
+	extra.Close()
+
+	udp, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	_, err = udp.Write([]byte("udp.metric;dc=eu 7 1700000000\n"))
+	require.NoError(t, err)
+	udp.Close()
+
+	require.Eventually(t, func() bool {
+		_, ok := get(`udp.metric{dc="eu"}`)
+		return ok
+	}, 3*time.Second, 20*time.Millisecond)
+
+	// Values still waiting for the flush are written on shutdown.
+	_, err = conn.Write([]byte("tcp.late 3 1700000000\n"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	conn.Close()
+
+	v, ok := get("tcp.metric")
+	require.True(t, ok)
+	require.Equal(t, "1.5", v)
+	v, ok = get("tcp.late")
+	require.True(t, ok)
+	require.Equal(t, "3", v)
+}
+
+func TestServer_TrustedSubnet(t *testing.T) {
+	addr := freeAddr(t)
+	// Nothing may reach the storage, so the mocks expect no calls.
+	storage := mocks.NewUpdater(t)
+	backup := mocks.NewBackuper(t)
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	srv := New(addr, storage, backup, zap.NewNop(), WithUDP(true), WithTrustedSubnet(subnet))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- srv.ListenAndServe(ctx) }()
+
+	// Connections from the loopback address are outside the subnet and are closed at once.
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	_, _ = conn.Write([]byte("tcp.metric 1 1700000000\n"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	conn.Close()
+
+	udp, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	_, err = udp.Write([]byte("udp.metric 7 1700000000\n"))
+	require.NoError(t, err)
+	udp.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
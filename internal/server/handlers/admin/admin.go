@@ -0,0 +1,205 @@
+// Package admin provides HTTP handlers for administrative backup and restore of metrics.
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/server/backuper/target"
+)
+
+// Snapshotter interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Snapshotter
+type Snapshotter interface {
+	// Snapshot streams the current content of the storage to w in the backup format.
+	Snapshot(ctx context.Context, w io.Writer) error
+}
+
+// Restorer interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Restorer
+type Restorer interface {
+	// RestoreFrom restores the storage from a backup read from r.
+	RestoreFrom(ctx context.Context, r io.Reader) error
+
+	// RestoreGeneration restores the storage from the named backup generation of the backup target.
+	RestoreGeneration(ctx context.Context, name string) error
+}
+
+// GenerationLister interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=GenerationLister
+type GenerationLister interface {
+	// Generations returns the backup generations stored in the backup target, oldest first.
+	Generations(ctx context.Context) ([]target.Generation, error)
+}
+
+// NewBackup returns an HTTP handler function that streams a snapshot of all metrics in the backup format.
+// Because the body is streamed, the SHA256 hash of the body is sent in the HashSHA256 trailer
+// instead of a header when a key is provided.
+//
+// Parameters:
+// - log: A zap.Logger instance for logging.
+// - backup: A Snapshotter producing the snapshot.
+// - sha256key: A string key used for generating SHA256 hash.
+//
+// Returns:
+// - An http.HandlerFunc that processes the backup request.
+func NewBackup(log *zap.Logger, backup Snapshotter, sha256key string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.NewBackup"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="metrics.bak"`)
+
+		counter := &countingWriter{w: w}
+		var body io.Writer = counter
+		hash := sha256.New()
+		if sha256key != "" {
+			w.Header().Set("Trailer", "HashSHA256")
+			body = io.MultiWriter(counter, hash)
+		}
+
+		err := backup.Snapshot(ctx, body)
+		if err != nil {
+			log.Error("Failed to stream snapshot", zap.Error(err), zap.Int64("written", counter.n))
+			if counter.n == 0 {
+				w.Header().Del("Trailer")
+				w.Header().Del("Content-Disposition")
+				problem.WriteError(w, r, err)
+				return
+			}
+			// The status and part of the backup are already sent; a problem document appended to them
+			// would look like a valid but corrupt backup, so the connection is aborted instead.
+			panic(http.ErrAbortHandler)
+		}
+
+		if sha256key != "" {
+			hash.Write([]byte(sha256key))
+			w.Header().Set("HashSHA256", hex.EncodeToString(hash.Sum(nil)))
+		}
+	}
+}
+
+// NewRestore returns an HTTP handler function that restores metrics from a backup in the request body,
+// or from the stored generation named by the "generation" query parameter.
+// The format, compression and encryption of the backup are detected automatically.
+//
+// Parameters:
+// - log: A zap.Logger instance for logging.
+// - backup: A Restorer applying the backup.
+//
+// Returns:
+// - An http.HandlerFunc that processes the restore request.
+func NewRestore(log *zap.Logger, backup Restorer) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.NewRestore"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		if generation := r.URL.Query().Get("generation"); generation != "" {
+			err := backup.RestoreGeneration(ctx, generation)
+			if errors.Is(err, target.ErrNoBackup) {
+				log.Info("Backup generation not found", zap.String("generation", generation))
+				problem.Write(w, r, http.StatusNotFound, problem.CodeBackupNotFound, "backup generation "+generation+" not found")
+				return
+			}
+			if err != nil {
+				log.Error("Failed to restore backup generation", zap.String("generation", generation), zap.Error(err))
+				problem.WriteError(w, r, err)
+				return
+			}
+			log.Info("Backup generation restored", zap.String("generation", generation))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		err := backup.RestoreFrom(ctx, r.Body)
+		if err != nil {
+			log.Error("Failed to restore backup", zap.Error(err))
+			problem.WriteReadError(w, r, err, problem.CodeBadRequest, err.Error())
+			return
+		}
+
+		log.Info("Backup restored")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// NewGenerations returns an HTTP handler function that lists the backup generations stored in the
+// backup target as a JSON array, oldest first. A generation is restored by passing its name to the
+// restore endpoint in the "generation" query parameter.
+//
+// Parameters:
+// - log: A zap.Logger instance for logging.
+// - backup: A GenerationLister listing the generations.
+//
+// Returns:
+// - An http.HandlerFunc that processes the listing request.
+func NewGenerations(log *zap.Logger, backup GenerationLister) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.NewGenerations"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		generations, err := backup.Generations(ctx)
+		if err != nil {
+			log.Error("Failed to list backup generations", zap.Error(err))
+			problem.WriteError(w, r, err)
+			return
+		}
+		if generations == nil {
+			generations = []target.Generation{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(generations); err != nil {
+			log.Error("Failed to write backup generations", zap.Error(err))
+		}
+	}
+}
+
+// countingWriter counts the bytes written to the response, so that a failed snapshot can be
+// reported as a problem document only while nothing has been sent yet.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the underlying writer and adds the written length to the counter.
+// Empty writes are dropped, as writing to the response would send the status even for them.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
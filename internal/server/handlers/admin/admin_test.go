@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/signature"
+	"github.com/mbiwapa/metric/internal/server/backuper/target"
+	"github.com/mbiwapa/metric/internal/server/handlers/admin/mocks"
+)
+
+func TestNewBackup(t *testing.T) {
+	snapshot := `[{"id":"testGauge","type":"gauge","value":1}]`
+
+	SnapshotterMock := mocks.NewSnapshotter(t)
+	SnapshotterMock.On("Snapshot", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, _ = io.WriteString(args.Get(1).(io.Writer), snapshot)
+		}).
+		Return(nil).Once()
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Get("/admin/backup", NewBackup(zap.NewNop(), SnapshotterMock, "testkey"))
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/admin/backup")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, snapshot, string(body))
+	require.Equal(t, signature.GetHash("testkey", snapshot, zap.NewNop()), resp.Trailer.Get("HashSHA256"))
+}
+
+func TestNewRestore(t *testing.T) {
+	tests := []struct {
+		name       string
+		restoreErr error
+		wantStatus int
+	}{
+		{name: "Restored", restoreErr: nil, wantStatus: http.StatusOK},
+		{name: "Invalid backup", restoreErr: errors.New("bad backup"), wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RestorerMock := mocks.NewRestorer(t)
+			RestorerMock.On("RestoreFrom", mock.Anything, mock.Anything).Return(tt.restoreErr).Once()
+
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Post("/admin/restore", NewRestore(zap.NewNop(), RestorerMock))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/restore", bytes.NewBufferString("[]"))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+}
+
+func TestNewRestoreGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		generation string
+		restoreErr error
+		wantStatus int
+	}{
+		{name: "Restored", generation: "metrics-20240101T000000.json", restoreErr: nil, wantStatus: http.StatusOK},
+		{name: "Unknown generation", generation: "missing.json", restoreErr: target.ErrNoBackup, wantStatus: http.StatusNotFound},
+		{name: "Target failure", generation: "metrics-20240101T000000.json", restoreErr: errors.New("target is down"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RestorerMock := mocks.NewRestorer(t)
+			RestorerMock.On("RestoreGeneration", mock.Anything, tt.generation).Return(tt.restoreErr).Once()
+
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Post("/admin/restore", NewRestore(zap.NewNop(), RestorerMock))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/restore?generation="+tt.generation, nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+}
+
+func TestNewGenerations(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		generations []target.Generation
+		listErr     error
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "Listed",
+			generations: []target.Generation{{Name: "metrics-20240101T000000.json", Size: 42, ModTime: modTime}},
+			wantStatus:  http.StatusOK,
+			wantBody:    `[{"name":"metrics-20240101T000000.json","size":42,"mod_time":"2024-01-01T00:00:00Z"}]`,
+		},
+		{name: "Empty", generations: nil, wantStatus: http.StatusOK, wantBody: `[]`},
+		{name: "Target failure", listErr: errors.New("target is down"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ListerMock := mocks.NewGenerationLister(t)
+			ListerMock.On("Generations", mock.Anything).Return(tt.generations, tt.listErr).Once()
+
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Get("/admin/backups", NewGenerations(zap.NewNop(), ListerMock))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/backups", nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantBody != "" {
+				require.JSONEq(t, tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewBackupFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		written string
+		wantErr bool
+	}{
+		{name: "Nothing written", written: "", wantErr: false},
+		{name: "Partially written", written: `[{"id":"testGauge"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SnapshotterMock := mocks.NewSnapshotter(t)
+			SnapshotterMock.On("Snapshot", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					_, _ = io.WriteString(args.Get(1).(io.Writer), tt.written)
+				}).
+				Return(errors.New("storage is down")).Once()
+
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Get("/admin/backup", NewBackup(zap.NewNop(), SnapshotterMock, "testkey"))
+
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			resp, err := ts.Client().Get(ts.URL + "/admin/backup")
+			if err == nil {
+				defer resp.Body.Close()
+				_, err = io.ReadAll(resp.Body)
+			}
+			if tt.wantErr {
+				// The connection is aborted, so the client can't take the partial body for a full backup.
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+			require.Equal(t, problem.ContentType, resp.Header.Get("Content-Type"))
+		})
+	}
+}
@@ -2,16 +2,62 @@
 package home
 
 import (
+	"bytes"
 	"context"
+	_ "embed"
+	"html/template"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"go.uber.org/zap"
 
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 	"github.com/mbiwapa/metric/internal/lib/signature"
 )
 
+// refreshInterval is how often the page reloads the tables in the background.
+const refreshInterval = 10 * time.Second
+
+//go:embed home.html
+var pageTemplate string
+
+// page is the dashboard template. html/template escapes the metric names,
+// so any name is rendered as text.
+var page = template.Must(template.New("home").Parse(pageTemplate))
+
+// pageData is the data of the dashboard template.
+type pageData struct {
+	Refresh  int // Refresh is the reload interval in seconds.
+	Gauges   table
+	Counters table
+}
+
+// table is one table of the dashboard.
+type table struct {
+	Title string
+	Type  string
+	Rows  []row
+}
+
+// row is one metric of a table.
+type row struct {
+	Name  string
+	Value string
+}
+
+// newTable builds a table of the metrics sorted by name.
+func newTable(title, typ string, metrics [][]string) table {
+	rows := make([]row, 0, len(metrics))
+	for _, metric := range metrics {
+		rows = append(rows, row{Name: metric[0], Value: metric[1]})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return table{Title: title, Type: typ, Rows: rows}
+}
+
 // AllMetricGeter defines the methods required to retrieve all metrics from the storage.
 // It is used to abstract the data retrieval logic, allowing for different implementations.
 //
@@ -30,8 +76,10 @@ type AllMetricGeter interface {
 }
 
 // New returns an HTTP handler function that serves an HTML page with all available metrics.
-// It logs the request, retrieves metrics from the storage, and constructs an HTML response.
-// If a SHA256 key is provided, it also includes a hash of the response body in the headers.
+// It logs the request, retrieves metrics from the storage, and renders the dashboard:
+// separate gauge and counter tables sorted by name, with client-side search, sorting by
+// column and a background refresh of the tables.
+// If a SHA256 key is provided, it also includes a hash of the rendered body in the headers.
 //
 // Parameters:
 //   - log: A zap.Logger instance for logging.
@@ -57,32 +105,31 @@ func New(log *zap.Logger, storage AllMetricGeter, sha256key string) http.Handler
 		gauge, counter, err := storage.GetAllMetrics(databaseCtx)
 		if err != nil {
 			log.Error("Failed to get all metrics", zap.Error(err))
-			w.WriteHeader(http.StatusBadRequest)
+			problem.WriteError(w, r, err)
 			return
 		}
-		log.Info("Metrics received", zap.Any("gauge", gauge), zap.Any("counter", counter))
+		log.Info("Metrics received", zap.Int("gauge", len(gauge)), zap.Int("counter", len(counter)))
 
-		body := "<!DOCTYPE html><html><head><title>Метрики</title><body><h1>Метрики</h1><ul>"
-
-		if len(gauge) > 0 {
-			for _, metric := range gauge {
-				body += "<li>" + metric[0] + ": " + metric[1] + "</li>"
-			}
-		}
-		if len(counter) > 0 {
-			for _, metric := range counter {
-				body += "<li>" + metric[0] + ": " + metric[1] + "</li>"
-			}
+		var buf bytes.Buffer
+		err = page.Execute(&buf, pageData{
+			Refresh:  int(refreshInterval / time.Second),
+			Gauges:   newTable("Gauge", format.Gauge, gauge),
+			Counters: newTable("Counter", format.Counter, counter),
+		})
+		if err != nil {
+			log.Error("Failed to render page", zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "cannot render page")
+			return
 		}
+		body := buf.Bytes()
 
-		body += "</ul></body></html>"
-		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 		if sha256key != "" {
-			hashStr := signature.GetHash(sha256key, body, log)
+			hashStr := signature.GetHash(sha256key, string(body), log)
 			w.Header().Set("HashSHA256", hashStr)
 		}
 
-		w.Write([]byte(body))
+		w.Write(body)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"fmt"
@@ -14,8 +15,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	"github.com/mbiwapa/metric/internal/lib/signature"
 	"github.com/mbiwapa/metric/internal/logger"
 	"github.com/mbiwapa/metric/internal/server/handlers/home/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
 )
 
 func TestNew(t *testing.T) {
@@ -40,8 +43,8 @@ func TestNew(t *testing.T) {
 		},
 		{
 			name:        "Home Тест 2, хранилище не отвечает",
-			wantStatus:  http.StatusBadRequest,
-			mockError:   fmt.Errorf("Stor unavailable"),
+			wantStatus:  http.StatusServiceUnavailable,
+			mockError:   fmt.Errorf("Stor unavailable: %w", storageErrors.ErrStorageUnavailable),
 			httpMethod:  http.MethodGet,
 			wantMetrics: metrics,
 		},
@@ -110,12 +113,51 @@ func ExampleNew() {
 	handler.ServeHTTP(rr, req)
 	fmt.Println(rr.Code)
 	fmt.Println(rr.Header().Get("Content-Type"))
-	fmt.Println(rr.Header().Get("HashSHA256"))
-	fmt.Println(rr.Body.String())
+	fmt.Println(rr.Header().Get("HashSHA256") == signature.GetHash(sha256key, rr.Body.String(), logger))
+	fmt.Println(strings.Contains(rr.Body.String(), `<td data-sort="metric3">metric3</td>`))
 
 	// Output:
 	//200
-	//text/html
-	//bebb681d299074ccd44c0023aaa8d4614bbd7ed8a426092f8da6f92c58e52f6a
-	//<!DOCTYPE html><html><head><title>Метрики</title><body><h1>Метрики</h1><ul><li>metric1: 1.23</li><li>metric2: 4.56</li><li>metric3: 789</li></ul></body></html>
+	//text/html; charset=utf-8
+	//true
+	//true
+}
+
+func TestNew_Page(t *testing.T) {
+	gauges := [][]string{{"b", "2.5"}, {"<script>alert(1)</script>", "1"}, {"a", "3"}}
+	counters := [][]string{{"PollCount", "42"}}
+
+	storage := mocks.NewAllMetricGeter(t)
+	storage.On("GetAllMetrics", mock.Anything).Return(gauges, counters, nil).Once()
+
+	rr := httptest.NewRecorder()
+	New(zap.NewNop(), storage, "key").ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	require.Equal(t, signature.GetHash("key", body, zap.NewNop()), rr.Header().Get("HashSHA256"))
+
+	// Metric names are escaped.
+	require.NotContains(t, body, "<script>alert(1)</script>")
+	require.Contains(t, body, "&lt;script&gt;alert(1)&lt;/script&gt;")
+
+	// Gauges and counters are in separate tables, sorted by name.
+	gaugeTable := body[strings.Index(body, `id="gauge-metrics"`):strings.Index(body, `id="counter-metrics"`)]
+	require.Less(t, strings.Index(gaugeTable, "&lt;script"), strings.Index(gaugeTable, `data-name="a"`))
+	require.Less(t, strings.Index(gaugeTable, `data-name="a"`), strings.Index(gaugeTable, `data-name="b"`))
+	require.NotContains(t, gaugeTable, "PollCount")
+	require.Contains(t, body[strings.Index(body, `id="counter-metrics"`):], `data-name="PollCount"`)
+	require.Contains(t, body, `<span class="badge counter">counter</span>`)
+}
+
+func TestNew_Empty(t *testing.T) {
+	storage := mocks.NewAllMetricGeter(t)
+	storage.On("GetAllMetrics", mock.Anything).Return([][]string{}, [][]string{}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	New(zap.NewNop(), storage, "").ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("HashSHA256"))
+	require.Equal(t, 2, strings.Count(rr.Body.String(), "Нет метрик"))
 }
@@ -0,0 +1,204 @@
+// Package influx provides the HTTP handler that ingests metrics written in the InfluxDB line protocol.
+package influx
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/lineprotocol"
+	"github.com/mbiwapa/metric/internal/lib/series"
+)
+
+// Updater interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Updater
+type Updater interface {
+	// UpdateBatch updates a batch of gauge and counter metrics in the storage.
+	// It takes a context for cancellation, and slices of gauge and counter metrics.
+	UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error
+}
+
+// Backuper interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Backuper
+type Backuper interface {
+	// IsSyncMode checks if the backup is in synchronous mode.
+	IsSyncMode() bool
+
+	// Refresh copies the current storage state into the backup structure.
+	Refresh(ctx context.Context) error
+
+	// SaveToFile saves the backup structure to a file.
+	SaveToFile()
+}
+
+// New returns an HTTP handler function for the InfluxDB line protocol write endpoint.
+//
+// Every field becomes a metric named "<measurement>_<field>" with the tags of the line as labels.
+// Float fields are stored as gauges, integer fields ("i" and "u" suffixes) as counters
+// and booleans as gauges with values 1 and 0. String fields are not supported.
+// The "precision" query parameter sets the unit of the timestamps; when a gauge is
+// written several times in one request, the value with the latest timestamp wins.
+//
+// Valid lines are stored even if other lines are rejected. If every line is accepted the
+// handler responds with 204 No Content, otherwise with 400 Bad Request and a problem
+// document listing the rejected lines in invalid_params.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - storage: An implementation of the Updater interface to store metrics.
+//   - backup: An implementation of the Backuper interface, used in synchronous mode.
+//
+// Returns:
+//   - An http.HandlerFunc that handles line protocol writes.
+func New(log *zap.Logger, storage Updater, backup Backuper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.influx.New"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		precision, err := lineprotocol.Precision(r.URL.Query().Get("precision"))
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, err.Error())
+			return
+		}
+
+		points, lineErrs, err := lineprotocol.Parse(r.Body, precision, time.Now())
+		if err != nil {
+			log.Error("Cannot read request body", zap.Error(err))
+			problem.WriteReadError(w, r, err, problem.CodeBadRequest, "cannot read request body")
+			return
+		}
+
+		var invalid []problem.InvalidParam
+		for _, e := range lineErrs {
+			invalid = append(invalid, problem.InvalidParam{Name: "line " + strconv.Itoa(e.Line), Reason: e.Err.Error()})
+		}
+
+		gauges, counters, rejected := convert(points)
+		invalid = append(invalid, rejected...)
+
+		if len(gauges) > 0 || len(counters) > 0 {
+			databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+
+			err = storage.UpdateBatch(databaseCtx, gauges, counters)
+			if err != nil {
+				log.Error("Failed to batch update", zap.Error(err))
+				problem.WriteError(w, r, err)
+				return
+			}
+
+			if backup.IsSyncMode() {
+				if err = backup.Refresh(databaseCtx); err != nil {
+					log.Error("Cannot backup metrics", zap.Error(err))
+				} else {
+					backup.SaveToFile()
+				}
+			}
+		}
+		log.Info("Line protocol applied",
+			zap.Int("gauges", len(gauges)),
+			zap.Int("counters", len(counters)),
+			zap.Int("rejected", len(invalid)))
+
+		if len(invalid) > 0 {
+			code := problem.CodeBadRequest
+			if len(gauges) > 0 || len(counters) > 0 {
+				code = problem.CodePartialWrite
+			}
+			p := problem.New(r, http.StatusBadRequest, code,
+				fmt.Sprintf("%d of the lines or fields were rejected", len(invalid)))
+			p.InvalidParams = invalid
+			problem.Send(w, p)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// gaugeValue is the latest value of a gauge within a request.
+type gaugeValue struct {
+	value string
+	ts    time.Time
+}
+
+// convert turns the fields of the points into gauges and counters.
+// Fields with unsupported values are returned as rejected.
+func convert(points []lineprotocol.Point) ([][]string, [][]string, []problem.InvalidParam) {
+	var (
+		order    []string
+		latest   = make(map[string]gaugeValue)
+		counters [][]string
+		rejected []problem.InvalidParam
+	)
+	setGauge := func(name, value string, ts time.Time) {
+		prev, ok := latest[name]
+		if !ok {
+			order = append(order, name)
+		} else if ts.Before(prev.ts) {
+			return
+		}
+		latest[name] = gaugeValue{value: value, ts: ts}
+	}
+
+	for _, p := range points {
+		for _, f := range p.Fields {
+			name := series.Format(p.Measurement+"_"+f.Key, p.Tags)
+			switch v := f.Value.(type) {
+			case float64:
+				// NaN and infinities can't be exposed or encoded as JSON once stored.
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					rejected = append(rejected, problem.InvalidParam{
+						Name:   "line " + strconv.Itoa(p.Line),
+						Reason: fmt.Sprintf("field %q: value %v is not a finite number", f.Key, v),
+					})
+					continue
+				}
+				setGauge(name, strconv.FormatFloat(v, 'f', -1, 64), p.Timestamp)
+			case bool:
+				value := "0"
+				if v {
+					value = "1"
+				}
+				setGauge(name, value, p.Timestamp)
+			case int64:
+				counters = append(counters, []string{name, strconv.FormatInt(v, 10)})
+			case uint64:
+				// Counters are stored as int64, so a larger value would fail the whole batch.
+				if v > math.MaxInt64 {
+					rejected = append(rejected, problem.InvalidParam{
+						Name:   "line " + strconv.Itoa(p.Line),
+						Reason: fmt.Sprintf("field %q: unsigned value %d exceeds the counter range", f.Key, v),
+					})
+					continue
+				}
+				counters = append(counters, []string{name, strconv.FormatUint(v, 10)})
+			default:
+				rejected = append(rejected, problem.InvalidParam{
+					Name:   "line " + strconv.Itoa(p.Line),
+					Reason: fmt.Sprintf("field %q: string values are not supported", f.Key),
+				})
+			}
+		}
+	}
+
+	gauges := make([][]string, 0, len(order))
+	for _, name := range order {
+		gauges = append(gauges, []string{name, latest[name].value})
+	}
+	return gauges, counters, rejected
+}
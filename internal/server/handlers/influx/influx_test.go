@@ -0,0 +1,132 @@
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/server/handlers/influx/mocks"
+	"github.com/mbiwapa/metric/internal/server/middleware/decompressor"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		query        string
+		mockError    error
+		wantStatus   int
+		wantGauges   [][]string
+		wantCounters [][]string
+		wantInvalid  []string
+	}{
+		{
+			name: "success",
+			body: "cpu,host=a usage=10.5 1000\n" +
+				"cpu,host=a usage=12 2000\n" +
+				"net,host=a bytes=512i,up=true\n",
+			wantStatus:   http.StatusNoContent,
+			wantGauges:   [][]string{{`cpu_usage{host="a"}`, "12"}, {`net_up{host="a"}`, "1"}},
+			wantCounters: [][]string{{`net_bytes{host="a"}`, "512"}},
+		},
+		{
+			name:         "partial write",
+			body:         "mem used=1\nmem used\nlog msg=\"hello\",lines=3i\n",
+			wantStatus:   http.StatusBadRequest,
+			wantGauges:   [][]string{{"mem_used", "1"}},
+			wantCounters: [][]string{{"log_lines", "3"}},
+			wantInvalid:  []string{"line 2", "line 3"},
+		},
+		{
+			name:        "non-finite float",
+			body:        "temp value=NaN\ntemp max=+Inf\ntemp min=-Inf\ntemp avg=21.5\n",
+			wantStatus:  http.StatusBadRequest,
+			wantGauges:  [][]string{{"temp_avg", "21.5"}},
+			wantInvalid: []string{"line 1", "line 2", "line 3"},
+		},
+		{
+			name:         "unsigned counter overflow",
+			body:         "net rx=9223372036854775808u\nnet tx=42u\n",
+			wantStatus:   http.StatusBadRequest,
+			wantGauges:   [][]string{},
+			wantCounters: [][]string{{"net_tx", "42"}},
+			wantInvalid:  []string{"line 1"},
+		},
+		{
+			name:        "nothing valid",
+			body:        "garbage\n",
+			wantStatus:  http.StatusBadRequest,
+			wantInvalid: []string{"line 1"},
+		},
+		{
+			name:       "unknown precision",
+			body:       "mem used=1\n",
+			query:      "?precision=h",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "storage unavailable",
+			body:       "mem used=1\n",
+			mockError:  fmt.Errorf("update: %w", storageErrors.ErrStorageUnavailable),
+			wantStatus: http.StatusServiceUnavailable,
+			wantGauges: [][]string{{"mem_used", "1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewUpdater(t)
+			backup := new(mocks.Backuper)
+			if tt.wantGauges != nil || tt.wantCounters != nil {
+				storage.On("UpdateBatch", mock.Anything, tt.wantGauges, tt.wantCounters).Return(tt.mockError).Once()
+			}
+			backup.On("IsSyncMode").Return(false)
+
+			r := httptest.NewRequest(http.MethodPost, "/write"+tt.query, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			New(zap.NewNop(), storage, backup)(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantInvalid != nil {
+				var p problem.Problem
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+				var names []string
+				for _, param := range p.InvalidParams {
+					names = append(names, param.Name)
+				}
+				require.Equal(t, tt.wantInvalid, names)
+			}
+		})
+	}
+}
+
+func TestNew_Gzip(t *testing.T) {
+	storage := mocks.NewUpdater(t)
+	backup := new(mocks.Backuper)
+	storage.On("UpdateBatch", mock.Anything, [][]string{{"mem_used", "1.5"}}, [][]string(nil)).Return(nil).Once()
+	backup.On("IsSyncMode").Return(false)
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	_, err := zw.Write([]byte("mem used=1.5\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/write", &body)
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	decompressor.New(zap.NewNop())(New(zap.NewNop(), storage, backup)).ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -0,0 +1,91 @@
+// Package metrics provides the HTTP handler exposing all stored metrics to Prometheus.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
+	"github.com/mbiwapa/metric/internal/lib/exposition"
+	"github.com/mbiwapa/metric/internal/lib/signature"
+)
+
+// AllMetricGeter defines the methods required to retrieve all metrics from the storage.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AllMetricGeter
+type AllMetricGeter interface {
+	// GetAllMetrics retrieves all gauge and counter metrics from the storage.
+	//
+	// Parameters:
+	//   - ctx: A context.Context instance for managing request-scoped values, cancellation, and deadlines.
+	//
+	// Returns:
+	//   - [][]string: A slice of gauge metrics, where each inner slice holds the name and the value.
+	//   - [][]string: A slice of counter metrics, where each inner slice holds the name and the value.
+	//   - error: An error object if there is an issue retrieving the metrics, otherwise nil.
+	GetAllMetrics(ctx context.Context) ([][]string, [][]string, error)
+}
+
+// New returns an HTTP handler function that renders all gauges and counters from the storage
+// in the Prometheus text exposition format. If the Accept header asks for
+// "application/openmetrics-text" or another format of the serializer registry,
+// that format is used instead.
+// If a SHA256 key is provided, it also includes a hash of the response body in the headers.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - storage: An implementation of the AllMetricGeter interface to retrieve metrics.
+//   - serializers: The formats the response can be rendered in.
+//   - sha256key: A string key used to generate a SHA256 hash of the response body.
+//
+// Returns:
+//   - An http.HandlerFunc that serves the metrics for scraping.
+func New(log *zap.Logger, storage AllMetricGeter, serializers *serializer.Registry, sha256key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.metrics.New"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		ser, err := serializers.Negotiate(r.Header.Get("Accept"), exposition.ContentTypeText)
+		if err != nil {
+			log.Info("No acceptable format", zap.String("accept", r.Header.Get("Accept")))
+			problem.Write(w, r, http.StatusNotAcceptable, problem.CodeNotAcceptable, err.Error())
+			return
+		}
+
+		databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		gauges, counters, err := storage.GetAllMetrics(databaseCtx)
+		if err != nil {
+			log.Error("Failed to get all metrics", zap.Error(err))
+			problem.WriteError(w, r, err)
+			return
+		}
+
+		var body bytes.Buffer
+		if err = ser.WriteMetrics(&body, format.NewMetrics(gauges, counters)); err != nil {
+			log.Error("Failed to render metrics", zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "cannot render metrics")
+			return
+		}
+
+		w.Header().Set("Content-Type", ser.ContentType())
+		w.Header().Add("Vary", "Accept")
+		if sha256key != "" {
+			w.Header().Set("HashSHA256", signature.GetHash(sha256key, body.String(), log))
+		}
+		w.Write(body.Bytes())
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
+	"github.com/mbiwapa/metric/internal/lib/exposition"
+	"github.com/mbiwapa/metric/internal/server/handlers/metrics/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+func TestNew(t *testing.T) {
+	gauges := [][]string{{"Alloc", "1.5"}, {"cpu.usage-1", "0.25"}}
+	counters := [][]string{{"PollCount", "42"}}
+
+	tests := []struct {
+		name            string
+		accept          string
+		mockError       error
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "Prometheus text",
+			wantStatus:      http.StatusOK,
+			wantContentType: exposition.ContentTypeText,
+			wantBody: "# TYPE Alloc gauge\nAlloc 1.5\n" +
+				"# TYPE PollCount counter\nPollCount 42\n" +
+				"# TYPE cpu_usage_1 gauge\ncpu_usage_1 0.25\n",
+		},
+		{
+			name:            "OpenMetrics",
+			accept:          "application/openmetrics-text;version=1.0.0,text/plain;q=0.5",
+			wantStatus:      http.StatusOK,
+			wantContentType: exposition.ContentTypeOpenMetrics,
+			wantBody: "# TYPE Alloc gauge\nAlloc 1.5\n" +
+				"# TYPE PollCount counter\nPollCount_total 42\n" +
+				"# TYPE cpu_usage_1 gauge\ncpu_usage_1 0.25\n# EOF\n",
+		},
+		{
+			name:            "JSON",
+			accept:          "application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: serializer.ContentTypeJSON,
+			wantBody: `[{"id":"Alloc","type":"gauge","value":1.5},{"id":"cpu.usage-1","type":"gauge","value":0.25},` +
+				`{"id":"PollCount","type":"counter","delta":42}]`,
+		},
+		{
+			name:       "not acceptable",
+			accept:     "image/png",
+			wantStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:       "storage unavailable",
+			mockError:  fmt.Errorf("get: %w", storageErrors.ErrStorageUnavailable),
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewAllMetricGeter(t)
+			if tt.wantStatus != http.StatusNotAcceptable {
+				storage.On("GetAllMetrics", mock.Anything).Return(gauges, counters, tt.mockError).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			New(zap.NewNop(), storage, serializer.NewDefault(), "")(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+			require.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.wantContentType, res.Header.Get("Content-Type"))
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
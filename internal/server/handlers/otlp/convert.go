@@ -0,0 +1,164 @@
+package otlp
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"github.com/mbiwapa/metric/internal/lib/series"
+)
+
+// serviceNameAttribute is the resource attribute exposed as the service_name label.
+const serviceNameAttribute = "service.name"
+
+// batch is the result of converting an export request.
+type batch struct {
+	gauges   [][]string
+	counters [][]string
+	rejected int64
+	reasons  map[string]struct{}
+}
+
+// reject counts unsupported data points and remembers the reason.
+func (b *batch) reject(n int, reason string) {
+	if n == 0 {
+		return
+	}
+	b.rejected += int64(n)
+	b.reasons[reason] = struct{}{}
+}
+
+// errorMessage joins the distinct rejection reasons.
+func (b *batch) errorMessage() string {
+	reasons := make([]string, 0, len(b.reasons))
+	for r := range b.reasons {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, "; ")
+}
+
+// convert maps the data points of the request into gauges and counters:
+//   - Gauge points are stored as gauges;
+//   - monotonic delta Sum points are stored as counters as is;
+//   - monotonic cumulative Sum points are converted into increases with delta;
+//   - non-monotonic cumulative Sum points hold the current value and are stored as gauges.
+//
+// Non-monotonic delta sums, histograms, summaries and points without a value are rejected.
+// Every series is named after the metric, with the point attributes and the service name as labels.
+func convert(req *colmetricspb.ExportMetricsServiceRequest, delta func(key string, current int64) int64) *batch {
+	b := &batch{reasons: make(map[string]struct{})}
+
+	for _, rm := range req.GetResourceMetrics() {
+		service := ""
+		for _, kv := range rm.GetResource().GetAttributes() {
+			if kv.GetKey() == serviceNameAttribute {
+				service = kv.GetValue().GetStringValue()
+			}
+		}
+
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				switch data := m.GetData().(type) {
+				case *metricspb.Metric_Gauge:
+					for _, dp := range data.Gauge.GetDataPoints() {
+						if v, ok := pointValue(dp); ok {
+							b.gauges = append(b.gauges, []string{seriesName(m.GetName(), service, dp), strconv.FormatFloat(v, 'f', -1, 64)})
+						} else {
+							b.reject(1, "data points without a value are not supported")
+						}
+					}
+				case *metricspb.Metric_Sum:
+					b.addSum(m.GetName(), service, data.Sum, delta)
+				case *metricspb.Metric_Histogram:
+					b.reject(len(data.Histogram.GetDataPoints()), "histograms are not supported")
+				case *metricspb.Metric_ExponentialHistogram:
+					b.reject(len(data.ExponentialHistogram.GetDataPoints()), "exponential histograms are not supported")
+				case *metricspb.Metric_Summary:
+					b.reject(len(data.Summary.GetDataPoints()), "summaries are not supported")
+				}
+			}
+		}
+	}
+	return b
+}
+
+// addSum converts the data points of a Sum metric.
+func (b *batch) addSum(name, service string, sum *metricspb.Sum, delta func(key string, current int64) int64) {
+	temporality := sum.GetAggregationTemporality()
+	for _, dp := range sum.GetDataPoints() {
+		v, ok := pointValue(dp)
+		if !ok {
+			b.reject(1, "data points without a value are not supported")
+			continue
+		}
+		key := seriesName(name, service, dp)
+
+		switch {
+		case sum.GetIsMonotonic() && temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+			b.counters = append(b.counters, []string{key, strconv.FormatInt(int64(math.Round(v)), 10)})
+		case sum.GetIsMonotonic() && temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+			increase := delta(key, int64(math.Round(v)))
+			b.counters = append(b.counters, []string{key, strconv.FormatInt(increase, 10)})
+		case !sum.GetIsMonotonic() && temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+			b.gauges = append(b.gauges, []string{key, strconv.FormatFloat(v, 'f', -1, 64)})
+		case !sum.GetIsMonotonic() && temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+			b.reject(1, "non-monotonic delta sums are not supported")
+		default:
+			b.reject(1, "sums without aggregation temporality are not supported")
+		}
+	}
+}
+
+// pointValue returns the value of the data point as a float.
+func pointValue(dp *metricspb.NumberDataPoint) (float64, bool) {
+	if dp.GetFlags()&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+		return 0, false
+	}
+	switch v := dp.GetValue().(type) {
+	case *metricspb.NumberDataPoint_AsDouble:
+		if math.IsNaN(v.AsDouble) || math.IsInf(v.AsDouble, 0) {
+			return 0, false
+		}
+		return v.AsDouble, true
+	case *metricspb.NumberDataPoint_AsInt:
+		return float64(v.AsInt), true
+	default:
+		return 0, false
+	}
+}
+
+// seriesName builds the storage name of the data point.
+func seriesName(name, service string, dp *metricspb.NumberDataPoint) string {
+	labels := make(map[string]string, len(dp.GetAttributes())+1)
+	for _, kv := range dp.GetAttributes() {
+		if v, ok := attributeValue(kv.GetValue()); ok {
+			labels[kv.GetKey()] = v
+		}
+	}
+	if service != "" {
+		labels["service_name"] = service
+	}
+	return series.Format(name, labels)
+}
+
+// attributeValue renders scalar attribute values; arrays, maps and bytes are skipped.
+func attributeValue(v *commonpb.AnyValue) (string, bool) {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue, true
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue), true
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10), true
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,155 @@
+// Package otlp provides the HTTP handler receiving metrics exported with the
+// OpenTelemetry protocol (OTLP/HTTP) in the protobuf and JSON encodings.
+package otlp
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/cumulative"
+)
+
+const (
+	// ContentTypeProtobuf is the media type of the binary protobuf encoding.
+	ContentTypeProtobuf = "application/x-protobuf"
+
+	// ContentTypeJSON is the media type of the JSON protobuf encoding.
+	ContentTypeJSON = "application/json"
+)
+
+// Updater interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Updater
+type Updater interface {
+	// UpdateBatch updates a batch of gauge and counter metrics in the storage.
+	// It takes a context for cancellation, and slices of gauge and counter metrics.
+	UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error
+}
+
+// Backuper interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Backuper
+type Backuper interface {
+	// IsSyncMode checks if the backup is in synchronous mode.
+	IsSyncMode() bool
+
+	// Refresh copies the current storage state into the backup structure.
+	Refresh(ctx context.Context) error
+
+	// SaveToFile saves the backup structure to a file.
+	SaveToFile()
+}
+
+// New returns an HTTP handler function for the OTLP/HTTP metrics endpoint.
+//
+// The request is an ExportMetricsServiceRequest encoded as protobuf or JSON according to
+// its Content-Type; the response uses the same encoding. Gauges are stored as gauges, monotonic
+// sums as counters, with cumulative sums converted into increases by the tracker.
+// Data points that cannot be stored are counted in the partial_success of the response.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - storage: An implementation of the Updater interface to store metrics.
+//   - backup: An implementation of the Backuper interface, used in synchronous mode.
+//   - counters: The tracker of cumulative sums.
+//
+// Returns:
+//   - An http.HandlerFunc that handles OTLP metrics export requests.
+func New(log *zap.Logger, storage Updater, backup Backuper, counters *cumulative.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.otlp.New"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != ContentTypeProtobuf && contentType != ContentTypeJSON {
+			problem.Write(w, r, http.StatusUnsupportedMediaType, problem.CodeBadRequest,
+				"content type must be "+ContentTypeProtobuf+" or "+ContentTypeJSON)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error("Cannot read request body", zap.Error(err))
+			problem.WriteReadError(w, r, err, problem.CodeBadRequest, "cannot read request body")
+			return
+		}
+
+		req := &colmetricspb.ExportMetricsServiceRequest{}
+		if contentType == ContentTypeJSON {
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, req)
+		} else {
+			err = proto.Unmarshal(body, req)
+		}
+		if err != nil {
+			log.Error("Cannot decode export request", zap.Error(err))
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, err.Error())
+			return
+		}
+
+		databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		var result *batch
+		err = counters.Apply(func(delta func(key string, current int64) int64) error {
+			result = convert(req, delta)
+			if len(result.gauges) == 0 && len(result.counters) == 0 {
+				return nil
+			}
+			return storage.UpdateBatch(databaseCtx, result.gauges, result.counters)
+		})
+		if err != nil {
+			log.Error("Failed to batch update", zap.Error(err))
+			problem.WriteError(w, r, err)
+			return
+		}
+		log.Info("OTLP metrics applied",
+			zap.Int("gauges", len(result.gauges)),
+			zap.Int("counters", len(result.counters)),
+			zap.Int64("rejected", result.rejected))
+
+		if backup.IsSyncMode() && (len(result.gauges) > 0 || len(result.counters) > 0) {
+			if err = backup.Refresh(databaseCtx); err != nil {
+				log.Error("Cannot backup metrics", zap.Error(err))
+			} else {
+				backup.SaveToFile()
+			}
+		}
+
+		resp := &colmetricspb.ExportMetricsServiceResponse{}
+		if result.rejected > 0 {
+			resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+				RejectedDataPoints: result.rejected,
+				ErrorMessage:       result.errorMessage(),
+			}
+		}
+
+		var out []byte
+		if contentType == ContentTypeJSON {
+			out, err = protojson.Marshal(resp)
+		} else {
+			out, err = proto.Marshal(resp)
+		}
+		if err != nil {
+			log.Error("Cannot encode export response", zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "cannot encode response")
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(out)
+	}
+}
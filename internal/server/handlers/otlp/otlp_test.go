@@ -0,0 +1,181 @@
+package otlp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mbiwapa/metric/internal/lib/cumulative"
+	"github.com/mbiwapa/metric/internal/server/handlers/otlp/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+// exportJSON is an export request in the OTLP JSON encoding as sent by the SDKs.
+const exportJSON = `{
+  "resourceMetrics": [{
+    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+    "scopeMetrics": [{
+      "metrics": [
+        {"name": "queue.size", "gauge": {"dataPoints": [{"asInt": "4", "timeUnixNano": "1000"}]}},
+        {"name": "requests", "sum": {"aggregationTemporality": 2, "isMonotonic": true,
+          "dataPoints": [{"asInt": "10", "attributes": [{"key": "code", "value": {"intValue": "200"}}]}]}},
+        {"name": "latency", "histogram": {"aggregationTemporality": 2, "dataPoints": [{"count": "3"}]}}
+      ]
+    }]
+  }]
+}`
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         []byte
+		mockError    error
+		wantStatus   int
+		wantGauges   [][]string
+		wantCounters [][]string
+		wantRejected int64
+	}{
+		{
+			name:         "json",
+			contentType:  ContentTypeJSON,
+			body:         []byte(exportJSON),
+			wantStatus:   http.StatusOK,
+			wantGauges:   [][]string{{`queue.size{service_name="checkout"}`, "4"}},
+			wantCounters: [][]string{{`requests{code="200",service_name="checkout"}`, "0"}},
+			wantRejected: 1,
+		},
+		{
+			name:         "protobuf",
+			contentType:  ContentTypeProtobuf,
+			body:         mustMarshal(t, protobufRequest()),
+			wantStatus:   http.StatusOK,
+			wantGauges:   [][]string{{"temperature", "21.5"}, {"connections", "-2"}},
+			wantCounters: [][]string{{"jobs", "3"}},
+			wantRejected: 1,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        []byte("x"),
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "malformed",
+			contentType: ContentTypeJSON,
+			body:        []byte("{"),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:         "storage unavailable",
+			contentType:  ContentTypeJSON,
+			body:         []byte(exportJSON),
+			mockError:    fmt.Errorf("update: %w", storageErrors.ErrStorageUnavailable),
+			wantStatus:   http.StatusServiceUnavailable,
+			wantGauges:   [][]string{{`queue.size{service_name="checkout"}`, "4"}},
+			wantCounters: [][]string{{`requests{code="200",service_name="checkout"}`, "0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewUpdater(t)
+			backup := new(mocks.Backuper)
+			if tt.wantGauges != nil {
+				storage.On("UpdateBatch", mock.Anything, tt.wantGauges, tt.wantCounters).Return(tt.mockError).Once()
+			}
+			backup.On("IsSyncMode").Return(false)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			New(zap.NewNop(), storage, backup, cumulative.New())(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+
+			resp := &colmetricspb.ExportMetricsServiceResponse{}
+			if tt.contentType == ContentTypeJSON {
+				require.NoError(t, protojson.Unmarshal(w.Body.Bytes(), resp))
+			} else {
+				require.NoError(t, proto.Unmarshal(w.Body.Bytes(), resp))
+			}
+			require.Equal(t, tt.wantRejected, resp.GetPartialSuccess().GetRejectedDataPoints())
+			if tt.wantRejected > 0 {
+				require.NotEmpty(t, resp.GetPartialSuccess().GetErrorMessage())
+			}
+		})
+	}
+}
+
+func TestNew_CumulativeSums(t *testing.T) {
+	storage := mocks.NewUpdater(t)
+	backup := new(mocks.Backuper)
+	backup.On("IsSyncMode").Return(false)
+	handler := New(zap.NewNop(), storage, backup, cumulative.New())
+
+	for _, tc := range []struct {
+		value int64
+		want  string
+	}{{10, "0"}, {25, "15"}, {4, "4"}} {
+		req := &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{{
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{
+				sum("bytes", true, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, tc.value),
+			}}},
+		}}}
+		storage.On("UpdateBatch", mock.Anything, [][]string(nil), [][]string{{"bytes", tc.want}}).Return(nil).Once()
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(mustMarshal(t, req)))
+		r.Header.Set("Content-Type", ContentTypeProtobuf)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		require.Equal(t, http.StatusOK, w.Code, strings.TrimSpace(w.Body.String()))
+	}
+}
+
+func protobufRequest() *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			{Key: "host.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "a"}}},
+		}},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{
+			{Name: "temperature", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+				{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 21.5}},
+			}}}},
+			sum("jobs", true, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, 3),
+			sum("connections", false, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, -2),
+			sum("balance", false, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, 7),
+		}}},
+	}}}
+}
+
+func sum(name string, monotonic bool, temporality metricspb.AggregationTemporality, value int64) *metricspb.Metric {
+	return &metricspb.Metric{Name: name, Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+		IsMonotonic:            monotonic,
+		AggregationTemporality: temporality,
+		DataPoints:             []*metricspb.NumberDataPoint{{Value: &metricspb.NumberDataPoint_AsInt{AsInt: value}}},
+	}}}
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	require.NoError(t, err)
+	return b
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/go-chi/chi/middleware"
 	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 )
 
 // Pinger is an interface that defines a method for checking the availability of a database or service.
@@ -25,7 +27,7 @@ type Pinger interface {
 // It takes a zap.Logger and a Pinger as parameters. The zap.Logger is used for logging, and the Pinger is the interface that defines the method for checking the availability of the database or service.
 // The returned http.HandlerFunc checks the availability of the database or service by calling the Ping method of the Pinger interface.
 // It takes a context.Context as a parameter to allow for timeout and cancellation control.
-// If the database or service is unavailable or if there is an issue performing the check, it logs an error and returns an HTTP status code of 500 (Internal Server Error).
+// If the database or service is unavailable or if there is an issue performing the check, it logs an error and returns an HTTP status code of 503 (Service Unavailable) with a problem document.
 // If the database or service is available, it logs an info message and returns an HTTP status code of 200 (OK).
 // The context.Context is used to set a timeout of 10 seconds for the database check.
 // The context.Context is also used to cancel the database check if the context is canceled.
@@ -53,7 +55,7 @@ func New(log *zap.Logger, storage Pinger) http.HandlerFunc {
 		err := storage.Ping(databaseCtx)
 		if err != nil {
 			log.Error("Database is unvailable", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeStorageUnavailable, err.Error())
 			return
 		}
 
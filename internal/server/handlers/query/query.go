@@ -0,0 +1,136 @@
+// Package query provides the HTTP handler evaluating query expressions over the stored metrics.
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/query"
+)
+
+// Result types of the response.
+const (
+	ResultScalar = "scalar"
+	ResultVector = "vector"
+)
+
+// AllMetricGeter defines the methods required to retrieve all metrics from the storage.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AllMetricGeter
+type AllMetricGeter interface {
+	// GetAllMetrics retrieves all gauge and counter metrics from the storage.
+	//
+	// Parameters:
+	//   - ctx: A context.Context instance for managing request-scoped values, cancellation, and deadlines.
+	//
+	// Returns:
+	//   - [][]string: A slice of gauge metrics, where each inner slice holds the name and the value.
+	//   - [][]string: A slice of counter metrics, where each inner slice holds the name and the value.
+	//   - error: An error object if there is an issue retrieving the metrics, otherwise nil.
+	GetAllMetrics(ctx context.Context) ([][]string, [][]string, error)
+}
+
+// Response is the body of a successful query.
+type Response struct {
+	Expr       string `json:"expr"`       // Expr is the expression as parsed, with explicit parentheses.
+	ResultType string `json:"resultType"` // ResultType is "scalar" or "vector".
+	Result     any    `json:"result"`     // Result is a number or a list of Sample.
+}
+
+// Sample is one metric of a vector result.
+type Sample struct {
+	ID    string  `json:"id"`
+	MType string  `json:"type"`
+	Value float64 `json:"value"`
+}
+
+// New returns an HTTP handler function for GET /query.
+//
+// The "expr" query parameter holds the expression, for example
+// "sum(CPUutilization*)" or "rate(PollCount[5m])"; the language is described in the
+// lib/query package. An expression that cannot be parsed is answered with 400
+// and one that needs data the server does not have, such as rate without history,
+// with 422.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - storage: An implementation of the AllMetricGeter interface to retrieve metrics.
+//   - history: the recent values of the metrics used by rate, may be nil.
+//
+// Returns:
+//   - An http.HandlerFunc that handles query requests.
+func New(log *zap.Logger, storage AllMetricGeter, history query.History) http.HandlerFunc {
+	evaluator := query.NewEvaluator(storage, history)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.query.New"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		expr := r.URL.Query().Get("expr")
+		if expr == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "expr parameter is required")
+			return
+		}
+		node, err := query.Parse(expr)
+		if err != nil {
+			log.Info("Invalid query", zap.String("expr", expr), zap.Error(err))
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error())
+			return
+		}
+
+		databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		value, err := evaluator.Eval(databaseCtx, node)
+		switch {
+		case errors.Is(err, query.ErrInvalid):
+			log.Info("Invalid query", zap.String("expr", expr), zap.Error(err))
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error())
+			return
+		case errors.Is(err, query.ErrNoData):
+			log.Info("Not enough data for query", zap.String("expr", expr), zap.Error(err))
+			problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeNoData, err.Error())
+			return
+		case err != nil:
+			log.Error("Failed to evaluate query", zap.String("expr", expr), zap.Error(err))
+			problem.WriteError(w, r, err)
+			return
+		}
+
+		resp := Response{Expr: node.String()}
+		switch v := value.(type) {
+		case query.Scalar:
+			resp.ResultType = ResultScalar
+			resp.Result = float64(v)
+		case query.Vector:
+			samples := make([]Sample, 0, len(v))
+			for _, s := range v {
+				samples = append(samples, Sample{ID: s.Name, MType: s.Type, Value: s.Value})
+			}
+			resp.ResultType = ResultVector
+			resp.Result = samples
+		}
+
+		body, err := json.Marshal(resp)
+		if err != nil {
+			log.Error("Cannot encode result", zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "cannot encode result")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}
+}
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/history"
+	"github.com/mbiwapa/metric/internal/server/handlers/query/mocks"
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+// stubHistory returns the samples of the metric whatever the window.
+type stubHistory map[string][]history.Sample
+
+func (h stubHistory) Range(_, name string, _ time.Duration) []history.Sample {
+	return h[name]
+}
+
+func TestNew(t *testing.T) {
+	gauges := [][]string{{"TotalMemory", "1000"}, {"FreeMemory", "250"}}
+	counters := [][]string{{"PollCount", "5"}}
+
+	tests := []struct {
+		name       string
+		expr       string
+		mockError  error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "scalar",
+			expr:       "TotalMemory - FreeMemory",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"expr":"(TotalMemory - FreeMemory)","resultType":"scalar","result":750}`,
+		},
+		{
+			name:       "vector",
+			expr:       "*Memory / 10",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"expr":"(*Memory / 10)","resultType":"vector","result":[{"id":"FreeMemory","type":"gauge","value":25},{"id":"TotalMemory","type":"gauge","value":100}]}`,
+		},
+		{
+			name:       "rate",
+			expr:       "rate(PollCount[5m])",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"expr":"rate(PollCount[5m0s])","resultType":"vector","result":[{"id":"PollCount","type":"counter","value":0.4}]}`,
+		},
+		{
+			name:       "missing expression",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_query"`,
+		},
+		{
+			name:       "syntax error",
+			expr:       "sum(",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_query"`,
+		},
+		{
+			name:       "unknown function",
+			expr:       "median(FreeMemory)",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_query"`,
+		},
+		{
+			name:       "no history",
+			expr:       "rate(FreeMemory[5m])",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `"code":"no_data"`,
+		},
+		{
+			name:       "storage unavailable",
+			expr:       "FreeMemory",
+			mockError:  storage.ErrStorageUnavailable,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"code":"storage_unavailable"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := mocks.NewAllMetricGeter(t)
+			if tt.expr != "" && tt.expr != "sum(" {
+				st.On("GetAllMetrics", mock.Anything).Return(gauges, counters, tt.mockError).Once()
+			}
+			start := time.Now().Add(-time.Minute)
+			h := stubHistory{"PollCount": {
+				{Time: start, Value: 1},
+				{Time: start.Add(10 * time.Second), Value: 5},
+			}}
+
+			req := httptest.NewRequest(http.MethodGet, "/query?expr="+url.QueryEscape(tt.expr), nil)
+			rr := httptest.NewRecorder()
+			New(zap.NewNop(), st, h).ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+				require.JSONEq(t, tt.wantBody, rr.Body.String())
+				return
+			}
+			require.Contains(t, rr.Body.String(), tt.wantBody)
+		})
+	}
+}
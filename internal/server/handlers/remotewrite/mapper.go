@@ -0,0 +1,181 @@
+package remotewrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/cumulative"
+	"github.com/mbiwapa/metric/internal/lib/prompb"
+	"github.com/mbiwapa/metric/internal/lib/series"
+)
+
+// ErrInvalidRule is returned when a type mapping rule cannot be parsed.
+var ErrInvalidRule = errors.New("invalid remote write rule")
+
+// counterSuffixes mark cumulative series when neither a rule nor the metadata defines the type.
+var counterSuffixes = []string{"_total", "_count", "_sum", "_bucket"}
+
+// Rule maps metric names matching the pattern to a metric type.
+type Rule struct {
+	Pattern *regexp.Regexp // Pattern is matched against the metric name.
+	Type    string         // Type is format.Gauge or format.Counter.
+}
+
+// ParseRules parses type mapping rules written as "regexp=type" and separated by ";".
+// The type is either gauge or counter. An empty string yields no rules.
+//
+// Parameters:
+//   - s: the rules, e.g. "^node_cpu_.*=counter;_bytes$=gauge".
+//
+// Returns:
+//   - []Rule: the parsed rules in the order of definition.
+//   - error: ErrInvalidRule if a rule is malformed.
+func ParseRules(s string) ([]Rule, error) {
+	const op = "handlers.remotewrite.ParseRules"
+
+	var rules []Rule
+	for _, def := range strings.Split(s, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		i := strings.LastIndexByte(def, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("%s: %w: %q", op, ErrInvalidRule, def)
+		}
+		typ := strings.TrimSpace(def[i+1:])
+		if typ != format.Gauge && typ != format.Counter {
+			return nil, fmt.Errorf("%s: %w: unknown type %q", op, ErrInvalidRule, typ)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(def[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidRule, err)
+		}
+		rules = append(rules, Rule{Pattern: re, Type: typ})
+	}
+	return rules, nil
+}
+
+// Mapper converts remote_write samples into gauges and counters.
+//
+// The type of a series is taken from the first matching rule, then from the metadata
+// sent with the request, and finally from the name: "_total", "_count", "_sum" and
+// "_bucket" series are counters, everything else is a gauge.
+//
+// Prometheus counters are cumulative while the storage adds deltas to counters,
+// so the Mapper remembers the last value of every counter series and stores the increase.
+// The first value of a series is only its baseline and a value lower than the previous one
+// is treated as a counter reset.
+type Mapper struct {
+	rules    []Rule
+	counters *cumulative.Tracker
+}
+
+// NewMapper creates a Mapper with the given type mapping rules.
+func NewMapper(rules []Rule) *Mapper {
+	return &Mapper{
+		rules:    rules,
+		counters: cumulative.New(),
+	}
+}
+
+// Apply maps the request and passes the result to update. The counter state
+// is advanced only if update succeeds, so a retried request is not counted twice.
+// Requests are applied one at a time.
+//
+// Parameters:
+//   - ctx: the context passed to update.
+//   - req: the decoded remote_write request.
+//   - update: stores the gauges and counters, usually the storage UpdateBatch.
+//
+// Returns:
+//   - error: the error returned by update.
+func (m *Mapper) Apply(ctx context.Context, req *prompb.WriteRequest, update func(ctx context.Context, gauges [][]string, counters [][]string) error) error {
+	types := make(map[string]prompb.MetricType, len(req.Metadata))
+	for _, md := range req.Metadata {
+		types[md.MetricFamilyName] = md.Type
+	}
+
+	return m.counters.Apply(func(delta func(key string, current int64) int64) error {
+		var gauges, counters [][]string
+		for _, ts := range req.Timeseries {
+			name := ts.Name()
+			sample, ok := latest(ts.Samples)
+			if name == "" || !ok {
+				continue
+			}
+			key := seriesName(name, ts.Labels)
+
+			if m.typeOf(name, types) == format.Gauge {
+				gauges = append(gauges, []string{key, strconv.FormatFloat(sample.Value, 'f', -1, 64)})
+				continue
+			}
+			increase := delta(key, int64(math.Round(sample.Value)))
+			counters = append(counters, []string{key, strconv.FormatInt(increase, 10)})
+		}
+
+		if len(gauges) == 0 && len(counters) == 0 {
+			return nil
+		}
+		return update(ctx, gauges, counters)
+	})
+}
+
+// typeOf resolves the storage type of a metric name.
+func (m *Mapper) typeOf(name string, types map[string]prompb.MetricType) string {
+	for _, r := range m.rules {
+		if r.Pattern.MatchString(name) {
+			return r.Type
+		}
+	}
+
+	switch types[name] {
+	case prompb.MetricTypeCounter:
+		return format.Counter
+	case prompb.MetricTypeGauge, prompb.MetricTypeSummary:
+		// Summary quantiles are exposed under the family name and behave like gauges.
+		return format.Gauge
+	}
+
+	for _, suffix := range counterSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return format.Counter
+		}
+	}
+	return format.Gauge
+}
+
+// latest returns the sample with the greatest timestamp, skipping NaN values
+// such as staleness markers.
+func latest(samples []prompb.Sample) (prompb.Sample, bool) {
+	var (
+		best  prompb.Sample
+		found bool
+	)
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+		if !found || s.Timestamp >= best.Timestamp {
+			best, found = s, true
+		}
+	}
+	return best, found
+}
+
+// seriesName builds the storage name of the series from its name and the remaining labels.
+func seriesName(name string, labels []prompb.Label) string {
+	rest := make(map[string]string, len(labels))
+	for _, l := range labels {
+		if l.Name != prompb.NameLabel && l.Value != "" {
+			rest[l.Name] = l.Value
+		}
+	}
+	return series.Format(name, rest)
+}
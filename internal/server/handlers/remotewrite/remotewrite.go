@@ -0,0 +1,137 @@
+// Package remotewrite provides the HTTP handler that ingests samples sent with
+// the Prometheus remote_write protocol.
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/prompb"
+)
+
+// Updater interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Updater
+type Updater interface {
+	// UpdateBatch updates a batch of gauge and counter metrics in the storage.
+	// It takes a context for cancellation, and slices of gauge and counter metrics.
+	UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error
+}
+
+// Backuper interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Backuper
+type Backuper interface {
+	// IsSyncMode checks if the backup is in synchronous mode.
+	IsSyncMode() bool
+
+	// Refresh copies the current storage state into the backup structure.
+	Refresh(ctx context.Context) error
+
+	// SaveToFile saves the backup structure to a file.
+	SaveToFile()
+}
+
+// Option configures optional behaviour of the handler.
+type Option func(*options)
+
+type options struct {
+	maxSize int64
+}
+
+// WithMaxSize limits the size of the write request once decompressed. The size is read
+// from the snappy header, so an oversized request is refused with 413 before it is decompressed.
+func WithMaxSize(maxSize int64) Option {
+	return func(o *options) {
+		o.maxSize = maxSize
+	}
+}
+
+// New returns an HTTP handler function for the Prometheus remote_write protocol.
+// The body is a snappy-compressed protobuf WriteRequest; its samples are mapped into
+// gauges and counters by the mapper and stored with a single UpdateBatch call.
+// On success it responds with 204 No Content, a malformed payload is rejected with
+// 400 Bad Request so that Prometheus does not retry it.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - storage: An implementation of the Updater interface to store metrics.
+//   - backup: An implementation of the Backuper interface, used in synchronous mode.
+//   - mapper: The Mapper that resolves metric types and counter increases.
+//   - opts: optional settings.
+//
+// Returns:
+//   - An http.HandlerFunc that handles remote_write requests.
+func New(log *zap.Logger, storage Updater, backup Backuper, mapper *Mapper, opts ...Option) http.HandlerFunc {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.remotewrite.New"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error("Cannot read request body", zap.Error(err))
+			problem.WriteReadError(w, r, err, problem.CodeBadRequest, "cannot read request body")
+			return
+		}
+
+		if o.maxSize > 0 {
+			size, err := prompb.DecodedLen(body)
+			if err != nil {
+				log.Error("Cannot decode write request", zap.Error(err))
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, err.Error())
+				return
+			}
+			if int64(size) > o.maxSize {
+				log.Info("Write request is too large", zap.Int("size", size))
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeTooLarge,
+					"decompressed write request exceeds "+strconv.FormatInt(o.maxSize, 10)+" bytes")
+				return
+			}
+		}
+
+		req, err := prompb.Decode(body)
+		if err != nil {
+			log.Error("Cannot decode write request", zap.Error(err))
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, err.Error())
+			return
+		}
+
+		databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		err = mapper.Apply(databaseCtx, req, storage.UpdateBatch)
+		if err != nil {
+			log.Error("Failed to batch update", zap.Error(err))
+			problem.WriteError(w, r, err)
+			return
+		}
+		log.Info("Remote write applied", zap.Int("series", len(req.Timeseries)))
+
+		if backup.IsSyncMode() {
+			if err = backup.Refresh(databaseCtx); err != nil {
+				log.Error("Cannot backup metrics", zap.Error(err))
+			} else {
+				backup.SaveToFile()
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,171 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/prompb"
+	"github.com/mbiwapa/metric/internal/server/handlers/remotewrite/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+func newSeries(name string, value float64, labels ...string) prompb.TimeSeries {
+	ts := prompb.TimeSeries{
+		Labels:  []prompb.Label{{Name: prompb.NameLabel, Value: name}},
+		Samples: []prompb.Sample{{Value: value, Timestamp: 1000}},
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: labels[i], Value: labels[i+1]})
+	}
+	return ts
+}
+
+func TestNew(t *testing.T) {
+	req := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			newSeries("go_goroutines", 12),
+			newSeries("http_requests_total", 7, "code", "200"),
+			newSeries("process_cpu_seconds", 3.5),
+		},
+	}
+
+	tests := []struct {
+		name         string
+		body         []byte
+		opts         []Option
+		mockError    error
+		wantStatus   int
+		wantGauges   [][]string
+		wantCounters [][]string
+	}{
+		{
+			name:         "success",
+			body:         prompb.Encode(req),
+			wantStatus:   http.StatusNoContent,
+			wantGauges:   [][]string{{"go_goroutines", "12"}, {"process_cpu_seconds", "3.5"}},
+			wantCounters: [][]string{{`http_requests_total{code="200"}`, "0"}},
+		},
+		{
+			name:       "malformed payload",
+			body:       []byte("garbage"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "decompressed size limit",
+			body: prompb.Encode(&prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{newSeries("big", 1, "padding", strings.Repeat("x", 64*1024))},
+			}),
+			opts:       []Option{WithMaxSize(1024)},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "storage unavailable",
+			body:         prompb.Encode(req),
+			mockError:    fmt.Errorf("update: %w", storageErrors.ErrStorageUnavailable),
+			wantStatus:   http.StatusServiceUnavailable,
+			wantGauges:   [][]string{{"go_goroutines", "12"}, {"process_cpu_seconds", "3.5"}},
+			wantCounters: [][]string{{`http_requests_total{code="200"}`, "0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewUpdater(t)
+			backup := new(mocks.Backuper)
+			if tt.wantGauges != nil {
+				storage.On("UpdateBatch", mock.Anything, tt.wantGauges, tt.wantCounters).Return(tt.mockError).Once()
+			}
+			backup.On("IsSyncMode").Return(false)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Encoding", "snappy")
+			r.Header.Set("Content-Type", "application/x-protobuf")
+			w := httptest.NewRecorder()
+			New(zap.NewNop(), storage, backup, NewMapper(nil), tt.opts...)(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestMapper_CounterDeltas(t *testing.T) {
+	m := NewMapper(nil)
+	var got [][]string
+	update := func(_ context.Context, _ [][]string, counters [][]string) error {
+		got = counters
+		return nil
+	}
+	failing := func(context.Context, [][]string, [][]string) error {
+		return storageErrors.ErrStorageUnavailable
+	}
+	write := func(v float64) *prompb.WriteRequest {
+		return &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{newSeries("jobs_total", v)}}
+	}
+
+	// The first value is a baseline: the total may already be stored from before a restart.
+	require.NoError(t, m.Apply(context.Background(), write(10), update))
+	require.Equal(t, [][]string{{"jobs_total", "0"}}, got)
+
+	require.Error(t, m.Apply(context.Background(), write(15), failing))
+
+	require.NoError(t, m.Apply(context.Background(), write(15), update))
+	require.Equal(t, [][]string{{"jobs_total", "5"}}, got)
+
+	// A lower value means the counter was reset.
+	require.NoError(t, m.Apply(context.Background(), write(3), update))
+	require.Equal(t, [][]string{{"jobs_total", "3"}}, got)
+
+	// After a restart the exporter still sends its lifetime total, which is already stored.
+	restarted := NewMapper(nil)
+	require.NoError(t, restarted.Apply(context.Background(), write(8), update))
+	require.Equal(t, [][]string{{"jobs_total", "0"}}, got)
+	require.NoError(t, restarted.Apply(context.Background(), write(12), update))
+	require.Equal(t, [][]string{{"jobs_total", "4"}}, got)
+}
+
+func TestMapper_Types(t *testing.T) {
+	rules, err := ParseRules("^node_cpu_seconds$=counter; _bytes_total$=gauge")
+	require.NoError(t, err)
+	m := NewMapper(rules)
+
+	types := map[string]prompb.MetricType{
+		"queue_length":     prompb.MetricTypeCounter,
+		"rpc_duration":     prompb.MetricTypeSummary,
+		"heap_used_bytes":  prompb.MetricTypeGauge,
+		"errors_total":     prompb.MetricTypeGauge,
+		"latency_seconds":  prompb.MetricTypeHistogram,
+		"unknown_metadata": prompb.MetricTypeUnknown,
+	}
+	tests := map[string]string{
+		"node_cpu_seconds":        "counter",
+		"net_sent_bytes_total":    "gauge",
+		"queue_length":            "counter",
+		"rpc_duration":            "gauge",
+		"rpc_duration_count":      "counter",
+		"heap_used_bytes":         "gauge",
+		"errors_total":            "gauge",
+		"latency_seconds_bucket":  "counter",
+		"unknown_metadata":        "gauge",
+		"requests_total":          "counter",
+		"temperature_celsius_sum": "counter",
+	}
+	for name, want := range tests {
+		require.Equal(t, want, m.typeOf(name, types), name)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	for _, s := range []string{"counter", "=counter", "x=histogram", "(=gauge"} {
+		_, err := ParseRules(s)
+		require.ErrorIs(t, err, ErrInvalidRule, s)
+	}
+}
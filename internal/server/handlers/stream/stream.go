@@ -0,0 +1,307 @@
+// Package stream provides the HTTP handler that ingests a long-lived stream of
+// newline-delimited JSON metrics.
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+)
+
+// ContentType is the media type of the request and of the acknowledgements.
+const ContentType = "application/x-ndjson"
+
+const (
+	// defaultBatchSize is the number of metrics that triggers an immediate write.
+	defaultBatchSize = 500
+
+	// defaultFlushInterval is the longest time a received metric waits before it is written.
+	defaultFlushInterval = time.Second
+
+	// maxLine is the longest accepted line.
+	maxLine = 64 * 1024
+)
+
+// Updater interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Updater
+type Updater interface {
+	// UpdateBatch updates a batch of gauge and counter metrics in the storage.
+	// It takes a context for cancellation, and slices of gauge and counter metrics.
+	UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error
+}
+
+// Backuper interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Backuper
+type Backuper interface {
+	// IsSyncMode checks if the backup is in synchronous mode.
+	IsSyncMode() bool
+
+	// Refresh copies the current storage state into the backup structure.
+	Refresh(ctx context.Context) error
+
+	// SaveToFile saves the backup structure to a file.
+	SaveToFile()
+}
+
+// Ack is written after every micro-batch as one line of the response.
+type Ack struct {
+	Accepted int                    `json:"accepted"`           // Accepted is the number of metrics stored by this batch.
+	Total    int64                  `json:"total"`              // Total is the number of metrics stored since the stream started.
+	Line     int64                  `json:"line"`               // Line is the last line covered by this acknowledgement.
+	Rejected []problem.InvalidParam `json:"rejected,omitempty"` // Rejected lists the lines that were skipped.
+	Error    string                 `json:"error,omitempty"`    // Error is the status title, e.g. "Service Unavailable", when the batch could not be stored; the stream ends.
+	Done     bool                   `json:"done,omitempty"`     // Done marks the last acknowledgement of the stream.
+}
+
+// Option configures optional behaviour of the handler.
+type Option func(*settings)
+
+type settings struct {
+	batchSize     int
+	flushInterval time.Duration
+}
+
+// WithBatchSize sets the number of metrics written to the storage at once.
+func WithBatchSize(n int) Option {
+	return func(s *settings) {
+		if n > 0 {
+			s.batchSize = n
+		}
+	}
+}
+
+// WithFlushInterval sets how long received metrics may wait before they are written.
+func WithFlushInterval(d time.Duration) Option {
+	return func(s *settings) {
+		if d > 0 {
+			s.flushInterval = d
+		}
+	}
+}
+
+// line is a decoded line of the request.
+type line struct {
+	n      int64
+	metric format.Metric
+	err    error
+}
+
+// New returns an HTTP handler function for streaming ingestion.
+//
+// The request body is a sequence of format.Metric objects, one per line, sent over a single
+// long-lived request. Metrics are written to the storage in micro-batches when the batch is
+// full or the flush interval elapses, and every batch is acknowledged with an Ack line in the
+// response body, so the client sees progress while it is still sending. Malformed lines are
+// reported in the acknowledgement and skipped. If the storage fails, the last acknowledgement
+// carries the error and the stream is closed; metrics after Ack.Line were not stored.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - storage: An implementation of the Updater interface to store metrics.
+//   - backup: An implementation of the Backuper interface, used in synchronous mode.
+//   - opts: optional settings.
+//
+// Returns:
+//   - An http.HandlerFunc that handles metric streams.
+func New(log *zap.Logger, storage Updater, backup Backuper, opts ...Option) http.HandlerFunc {
+	cfg := settings{batchSize: defaultBatchSize, flushInterval: defaultFlushInterval}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.stream.New"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != ContentType {
+			problem.Write(w, r, http.StatusUnsupportedMediaType, problem.CodeBadRequest, "content type must be "+ContentType)
+			return
+		}
+
+		// HTTP/1.x discards the unread body once the response starts, unless full duplex is enabled.
+		rc := http.NewResponseController(w)
+		if err := rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Warn("Cannot enable full duplex", zap.Error(err))
+		}
+		w.Header().Set("Content-Type", ContentType)
+		w.WriteHeader(http.StatusOK)
+		_ = rc.Flush()
+
+		done := make(chan struct{})
+		defer close(done)
+		lines := make(chan line)
+		go read(r, lines, done)
+
+		enc := json.NewEncoder(w)
+		ticker := time.NewTicker(cfg.flushInterval)
+		defer ticker.Stop()
+
+		var (
+			gauges, counters [][]string
+			pending          Ack
+			last, first      int64
+		)
+		flush := func(final bool) bool {
+			if !final && len(gauges) == 0 && len(counters) == 0 && len(pending.Rejected) == 0 {
+				return true
+			}
+			pending.Line, pending.Done = last, final
+
+			if len(gauges) > 0 || len(counters) > 0 {
+				err := write(ctx, log, storage, backup, gauges, counters)
+				if err != nil {
+					log.Error("Failed to batch update", zap.Error(err))
+					// Only the status title is sent: storage errors carry queries and internal details.
+					status, _ := problem.FromError(err)
+					pending.Error, pending.Done = http.StatusText(status), true
+					pending.Line = first - 1
+				} else {
+					pending.Accepted = len(gauges) + len(counters)
+					pending.Total += int64(pending.Accepted)
+				}
+			}
+			if err := enc.Encode(pending); err != nil {
+				log.Error("Cannot write acknowledgement", zap.Error(err))
+				return false
+			}
+			_ = rc.Flush()
+
+			failed := pending.Error != ""
+			gauges, counters = nil, nil
+			pending = Ack{Total: pending.Total}
+			return !failed && !final
+		}
+
+		for {
+			select {
+			case l, ok := <-lines:
+				if !ok {
+					flush(true)
+					log.Info("Stream completed", zap.Int64("total", pending.Total), zap.Int64("lines", last))
+					return
+				}
+				last = l.n
+				if l.err != nil {
+					pending.Rejected = append(pending.Rejected, problem.InvalidParam{
+						Name:   "line " + strconv.FormatInt(l.n, 10),
+						Reason: l.err.Error(),
+					})
+					continue
+				}
+				if len(gauges) == 0 && len(counters) == 0 {
+					first = l.n
+				}
+				if l.metric.MType == format.Gauge {
+					gauges = append(gauges, []string{l.metric.ID, strconv.FormatFloat(*l.metric.Value, 'f', -1, 64)})
+				} else {
+					counters = append(counters, []string{l.metric.ID, strconv.FormatInt(*l.metric.Delta, 10)})
+				}
+				if len(gauges)+len(counters) >= cfg.batchSize && !flush(false) {
+					return
+				}
+			case <-ticker.C:
+				if !flush(false) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// read decodes the request body line by line until it ends or done is closed.
+// Lines are numbered from 1; empty lines are skipped but counted.
+func read(r *http.Request, lines chan<- line, done <-chan struct{}) {
+	defer close(lines)
+
+	send := func(l line) bool {
+		select {
+		case lines <- l:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(make([]byte, 0, 4096), maxLine)
+	n := int64(1)
+	for ; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		l := line{n: n}
+		if err := json.Unmarshal(sc.Bytes(), &l.metric); err != nil {
+			l.err = err
+		} else {
+			l.err = validate(l.metric)
+		}
+		if !send(l) {
+			return
+		}
+	}
+	// A read error or an overlong line ends the stream; it is reported like a malformed line.
+	if err := sc.Err(); err != nil {
+		send(line{n: n, err: err})
+	}
+}
+
+// validate checks that the metric has a known type and the matching value.
+func validate(m format.Metric) error {
+	if m.ID == "" {
+		return errors.New("metric id is required")
+	}
+	switch m.MType {
+	case format.Gauge:
+		if m.Value == nil {
+			return fmt.Errorf("gauge %s requires a value", m.ID)
+		}
+	case format.Counter:
+		if m.Delta == nil {
+			return fmt.Errorf("counter %s requires a delta", m.ID)
+		}
+	default:
+		return errors.New("metric type must be gauge or counter")
+	}
+	return nil
+}
+
+// write stores a batch and refreshes the backup in synchronous mode.
+// A failed backup is logged only, the batch is already stored and must not be resent.
+func write(ctx context.Context, log *zap.Logger, storage Updater, backup Backuper, gauges, counters [][]string) error {
+	databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := storage.UpdateBatch(databaseCtx, gauges, counters); err != nil {
+		return err
+	}
+	if backup.IsSyncMode() {
+		if err := backup.Refresh(databaseCtx); err != nil {
+			log.Error("Cannot backup metrics", zap.Error(err))
+		} else {
+			backup.SaveToFile()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/server/handlers/stream/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+// openStream starts a streaming request and returns the writer of the request body
+// and a reader of the acknowledgements.
+func openStream(t *testing.T, url string) (*io.PipeWriter, *bufio.Scanner) {
+	t.Helper()
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, url, pr)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", ContentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, ContentType, resp.Header.Get("Content-Type"))
+	return pw, bufio.NewScanner(resp.Body)
+}
+
+func nextAck(t *testing.T, sc *bufio.Scanner) Ack {
+	t.Helper()
+	require.True(t, sc.Scan(), "acknowledgement expected")
+	var ack Ack
+	require.NoError(t, json.Unmarshal(sc.Bytes(), &ack))
+	return ack
+}
+
+func TestNew_Stream(t *testing.T) {
+	storage := mocks.NewUpdater(t)
+	backup := mocks.NewBackuper(t)
+	storage.On("UpdateBatch", mock.Anything,
+		[][]string{{"Alloc", "1.5"}}, [][]string{{"PollCount", "2"}}).Return(nil).Once()
+	storage.On("UpdateBatch", mock.Anything,
+		[][]string{{"Alloc", "3"}}, [][]string(nil)).Return(nil).Once()
+	backup.On("IsSyncMode").Return(false)
+
+	srv := httptest.NewServer(New(zap.NewNop(), storage, backup, WithBatchSize(2), WithFlushInterval(time.Hour)))
+	defer srv.Close()
+
+	pw, acks := openStream(t, srv.URL)
+
+	// The first batch is acknowledged while the request is still open.
+	_, err := io.WriteString(pw, `{"id":"Alloc","type":"gauge","value":1.5}`+"\n"+`{"id":"PollCount","type":"counter","delta":2}`+"\n")
+	require.NoError(t, err)
+	ack := nextAck(t, acks)
+	require.Equal(t, Ack{Accepted: 2, Total: 2, Line: 2}, ack)
+
+	_, err = io.WriteString(pw, `{"id":"Bad","type":"gauge"}`+"\n\n"+`{"id":"Alloc","type":"gauge","value":3}`+"\n")
+	require.NoError(t, err)
+	require.NoError(t, pw.Close())
+
+	ack = nextAck(t, acks)
+	require.Equal(t, 1, ack.Accepted)
+	require.Equal(t, int64(3), ack.Total)
+	require.Equal(t, int64(5), ack.Line)
+	require.True(t, ack.Done)
+	require.Len(t, ack.Rejected, 1)
+	require.Equal(t, "line 3", ack.Rejected[0].Name)
+	require.False(t, acks.Scan())
+}
+
+func TestNew_StorageError(t *testing.T) {
+	storage := mocks.NewUpdater(t)
+	storage.On("UpdateBatch", mock.Anything, mock.Anything, mock.Anything).
+		Return(fmt.Errorf("storage.postgre.UpdateBatch: %w: dial tcp 10.0.0.5:5432: connection refused", storageErrors.ErrStorageUnavailable)).Once()
+
+	srv := httptest.NewServer(New(zap.NewNop(), storage, mocks.NewBackuper(t), WithFlushInterval(10*time.Millisecond)))
+	defer srv.Close()
+
+	pw, acks := openStream(t, srv.URL)
+	defer pw.Close()
+	_, err := io.WriteString(pw, `{"id":"PollCount","type":"counter","delta":1}`+"\n")
+	require.NoError(t, err)
+
+	ack := nextAck(t, acks)
+	require.True(t, ack.Done)
+	require.Equal(t, "Service Unavailable", ack.Error)
+	require.Equal(t, int64(0), ack.Line)
+	require.Equal(t, int64(0), ack.Total)
+}
+
+func TestNew_ContentType(t *testing.T) {
+	h := New(zap.NewNop(), mocks.NewUpdater(t), mocks.NewBackuper(t))
+	req := httptest.NewRequest(http.MethodPost, "/updates/stream", strings.NewReader(`[]`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h(rec, req)
+
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
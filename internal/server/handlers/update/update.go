@@ -13,6 +13,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 )
 
 // Updater interface for storage
@@ -82,7 +83,7 @@ func New(log *zap.Logger, storage Updater, backup Backuper) http.HandlerFunc {
 				zap.String("name", name),
 				zap.String("value", value))
 
-			w.WriteHeader(http.StatusNotFound)
+			problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "metric name or value is empty")
 			return
 		}
 
@@ -94,31 +95,31 @@ func New(log *zap.Logger, storage Updater, backup Backuper) http.HandlerFunc {
 			val, err := strconv.ParseFloat(value, 64)
 			if err != nil {
 				log.Error("Failed to parse gauge value", zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidValue, "gauge value must be a float")
 				return
 			}
 			err = storage.UpdateGauge(databaseCtx, name, val)
 			if err != nil {
 				log.Error("Failed to update gauge value", zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
+				problem.WriteError(w, r, err)
 				return
 			}
 		case format.Counter:
 			val, err := strconv.ParseInt(value, 0, 64)
 			if err != nil {
 				log.Error("Failed to parse counter value", zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidValue, "counter value must be an integer")
 				return
 			}
 			err = storage.UpdateCounter(databaseCtx, name, val)
 			if err != nil {
 				log.Error("Failed to update counter value", zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
+				problem.WriteError(w, r, err)
 				return
 			}
 		default:
 			log.Error("Undefined metric type", zap.String("type", typ))
-			w.WriteHeader(http.StatusBadRequest)
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeUnknownType, "metric type must be gauge or counter")
 			return
 
 		}
@@ -11,6 +11,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 	"github.com/mbiwapa/metric/internal/lib/signature"
 )
 
@@ -42,14 +43,14 @@ func NewJSON(log *zap.Logger, storage Updater, backup Backuper, sha256key string
 		dec := json.NewDecoder(r.Body)
 		if err := dec.Decode(&metricRequest); err != nil {
 			log.Error("Cannot decode request JSON body", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.WriteReadError(w, r, err, problem.CodeInvalidJSON, err.Error())
 			return
 		}
 
 		// Check if the metric ID is empty
 		if metricRequest.ID == "" {
 			log.Error("Name is empty!", zap.String("name", metricRequest.ID))
-			w.WriteHeader(http.StatusNotFound)
+			problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "metric name is empty")
 			return
 		}
 
@@ -57,39 +58,50 @@ func NewJSON(log *zap.Logger, storage Updater, backup Backuper, sha256key string
 		databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 
-		var updateErr error
-
 		// Update the metric based on its type
 		switch metricRequest.MType {
 		case format.Gauge:
-			updateErr = storage.UpdateGauge(databaseCtx, metricRequest.ID, *metricRequest.Value)
+			if metricRequest.Value == nil {
+				log.Error("Gauge value is missing", zap.String("name", metricRequest.ID))
+				problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeValidation, "gauge requires a value")
+				return
+			}
+			err := storage.UpdateGauge(databaseCtx, metricRequest.ID, *metricRequest.Value)
+			if err != nil {
+				log.Error("Failed to update value", zap.Error(err))
+				problem.WriteError(w, r, err)
+				return
+			}
 		case format.Counter:
-			updateErr = storage.UpdateCounter(databaseCtx, metricRequest.ID, *metricRequest.Delta)
+			if metricRequest.Delta == nil {
+				log.Error("Counter delta is missing", zap.String("name", metricRequest.ID))
+				problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeValidation, "counter requires a delta")
+				return
+			}
+			err := storage.UpdateCounter(databaseCtx, metricRequest.ID, *metricRequest.Delta)
+			if err != nil {
+				log.Error("Failed to update value", zap.Error(err))
+				problem.WriteError(w, r, err)
+				return
+			}
 			databaseGetCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 			defer cancel()
 			stringVal, err := storage.GetMetric(databaseGetCtx, metricRequest.MType, metricRequest.ID)
 			if err != nil {
 				log.Error("Failed to get metric", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				problem.WriteError(w, r, err)
 				return
 			}
 			newVal, err := strconv.ParseInt(stringVal, 0, 64)
 			if err != nil {
 				log.Error("Failed to parse int", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "stored counter is not an integer")
 				return
 			}
 			metricRequest.Delta = &newVal
 		default:
 			log.Error("Undefined metric type", zap.String("type", metricRequest.MType))
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		// Handle update errors
-		if updateErr != nil {
-			log.Error("Failed to update value", zap.Error(updateErr))
-			w.WriteHeader(http.StatusBadRequest)
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeUnknownType, "metric type must be gauge or counter")
 			return
 		}
 
@@ -14,6 +14,7 @@ import (
 
 	"github.com/mbiwapa/metric/internal/logger"
 	"github.com/mbiwapa/metric/internal/server/handlers/update/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
 )
 
 func TestNew(t *testing.T) {
@@ -43,8 +44,8 @@ func TestNew(t *testing.T) {
 		},
 		{
 			name:       "Gauge Тест 3, не работает хранилище",
-			wantStatus: http.StatusBadRequest,
-			mockError:  fmt.Errorf("Stor unavailable"),
+			wantStatus: http.StatusServiceUnavailable,
+			mockError:  fmt.Errorf("Stor unavailable: %w", storageErrors.ErrStorageUnavailable),
 			url:        "/update/gauge/test1/0.5653",
 			httpMethod: http.MethodPost,
 			typ:        "gauge",
@@ -83,8 +84,8 @@ func TestNew(t *testing.T) {
 		},
 		{
 			name:       "Counter Тест 3, не работает хранилище",
-			wantStatus: http.StatusBadRequest,
-			mockError:  fmt.Errorf("Stor unavailable"),
+			wantStatus: http.StatusServiceUnavailable,
+			mockError:  fmt.Errorf("Stor unavailable: %w", storageErrors.ErrStorageUnavailable),
 			url:        "/update/counter/test1/1",
 			httpMethod: http.MethodPost,
 			typ:        "counter",
@@ -4,7 +4,9 @@ package updates
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,15 +15,73 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 	"github.com/mbiwapa/metric/internal/lib/signature"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
 )
 
+// Mode is the semantics of a batch containing invalid metrics.
+type Mode string
+
+const (
+	// ModeAtomic rejects the whole batch if any metric is invalid.
+	ModeAtomic Mode = "atomic"
+
+	// ModeBestEffort writes the valid metrics and rejects the invalid ones.
+	ModeBestEffort Mode = "best-effort"
+)
+
+// ParseMode converts the name of a mode into a Mode.
+//
+// Parameters:
+//   - name: "atomic" or "best-effort"; empty means atomic.
+//
+// Returns:
+//   - Mode: the mode.
+//   - error: if the name is unknown.
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case "", ModeAtomic:
+		return ModeAtomic, nil
+	case ModeBestEffort:
+		return ModeBestEffort, nil
+	}
+	return ModeAtomic, fmt.Errorf("unknown batch mode %q, must be %s or %s", name, ModeAtomic, ModeBestEffort)
+}
+
+// Option configures optional behaviour of the handler.
+type Option func(*options)
+
+type options struct {
+	mode     Mode
+	maxItems int
+}
+
+// WithMode sets the semantics of batches containing invalid metrics, ModeAtomic by default.
+func WithMode(mode Mode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithMaxItems limits the number of metrics in a batch. The batch is decoded item by item
+// and rejected with 413 as soon as the limit is exceeded, 0 means no limit.
+func WithMaxItems(n int) Option {
+	return func(o *options) {
+		o.maxItems = n
+	}
+}
+
+// errTooManyItems is returned by decodeItems when the batch exceeds the limit.
+var errTooManyItems = errors.New("too many metrics in the batch")
+
 // Updater interface for storage
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Updater
 type Updater interface {
 	// UpdateBatch updates a batch of gauge and counter metrics in the storage.
 	// It takes a context for cancellation, and slices of gauge and counter metrics.
+	// Either the whole batch is written or, on error, none of it.
 	UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error
 }
 
@@ -40,95 +100,258 @@ type Backuper interface {
 	IsSyncMode() bool
 }
 
+// item is a metric of the batch with its position in the request.
+type item struct {
+	index  int
+	metric format.Metric
+}
+
 // NewJSON returns an HTTP handler function for batch updating metrics.
 // It takes a logger, storage updater, backup handler, and an optional SHA256 key for response hashing.
-func NewJSON(log *zap.Logger, storage Updater, backup Backuper, sha256key string) http.HandlerFunc {
+//
+// Every item of the batch is validated on its own. If all of them are valid and written, the
+// response echoes the batch. Otherwise it is a problem document listing the rejected items
+// with reasons in invalid_params and the written ones in accepted, both by their "[index]".
+// In ModeAtomic nothing is written if any item is invalid (422 validation_failed); in
+// ModeBestEffort the valid items are written (400 partial_write) and an item the storage
+// refuses as an invalid value is rejected alone. If the storage fails after some items
+// were written one by one, the response is a partial_write problem with the status of the
+// storage error, listing the written items in accepted. The backup is updated only with the
+// written items, after the storage.
+func NewJSON(log *zap.Logger, storage Updater, backup Backuper, sha256key string, opts ...Option) http.HandlerFunc {
+	o := options{mode: ModeAtomic}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.updates.NewJSON"
 
 		ctx := r.Context()
-		log.With(
+		log := log.With(
 			zap.String("op", op),
 			zap.String("request_id", middleware.GetReqID(ctx)),
 		)
 
-		var metricsRequest []format.Metric
-
-		dec := json.NewDecoder(r.Body)
-		if err := dec.Decode(&metricsRequest); err != nil {
+		raw, err := decodeItems(r.Body, o.maxItems)
+		if errors.Is(err, errTooManyItems) {
+			log.Info("Batch is too large", zap.Int("max_items", o.maxItems))
+			problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeTooLarge,
+				fmt.Sprintf("batch has more than %d metrics", o.maxItems))
+			return
+		}
+		if err != nil {
 			log.Error("Cannot decode request JSON body", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.WriteReadError(w, r, err, problem.CodeInvalidJSON, err.Error())
 			return
 		}
 
-		var gauges [][]string
-		var counters [][]string
-		for _, metric := range metricsRequest {
-			switch metric.MType {
-			case format.Gauge:
-				gauges = append(gauges, []string{metric.ID, strconv.FormatFloat(*metric.Value, 'f', -1, 64)})
-			case format.Counter:
-				counters = append(counters, []string{metric.ID, strconv.FormatInt(*metric.Delta, 10)})
-			default:
-				log.Error("Unknown metric type", zap.String("type", metric.MType))
-				w.WriteHeader(http.StatusBadRequest)
-				return
+		var valid []item
+		var invalid []problem.InvalidParam
+		for i, data := range raw {
+			var metric format.Metric
+			reason := ""
+			if err := json.Unmarshal(data, &metric); err != nil {
+				reason = "invalid metric: " + err.Error()
+			} else {
+				reason = validate(metric)
 			}
-
-			err := backupHandler(log, backup, metric)
-			if err != nil {
-				log.Error("Cannot backup metric", zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
-				return
+			if reason != "" {
+				invalid = append(invalid, problem.InvalidParam{Name: itemName(i), Reason: reason})
+				continue
 			}
+			valid = append(valid, item{index: i, metric: metric})
+		}
+
+		if len(invalid) > 0 && (o.mode == ModeAtomic || len(valid) == 0) {
+			log.Info("Batch rejected", zap.Int("invalid", len(invalid)), zap.Int("total", len(raw)))
+			p := problem.New(r, http.StatusUnprocessableEntity, problem.CodeValidation,
+				fmt.Sprintf("%d of %d metrics are invalid, nothing was written", len(invalid), len(raw)))
+			p.InvalidParams = invalid
+			problem.Send(w, p)
+			return
 		}
 
 		databaseCtx, cancel := context.WithTimeout(ctx, 11*time.Second)
 		defer cancel()
-		err := storage.UpdateBatch(databaseCtx, gauges, counters)
 
-		if err != nil {
+		written, refused, err := write(databaseCtx, storage, valid, o.mode)
+		if err != nil && len(written) == 0 {
 			log.Error("Failed to batch update", zap.Error(err))
-			w.WriteHeader(http.StatusBadRequest)
+			problem.WriteError(w, r, err)
 			return
 		}
+		invalid = append(invalid, refused...)
 
-		w.Header().Set("Content-Type", "application/json")
-		body, err := json.Marshal(metricsRequest)
+		backupHandler(log, backup, written)
+
+		if err != nil {
+			// Some items are already committed, so the client must resend only the rest.
+			log.Error("Batch update failed after a partial write", zap.Int("written", len(written)), zap.Error(err))
+			status, _ := problem.FromError(err)
+			p := problem.New(r, status, problem.CodePartialWrite,
+				fmt.Sprintf("%d of %d metrics were written before the storage failed", len(written), len(raw)))
+			p.InvalidParams = invalid
+			for _, it := range written {
+				p.Accepted = append(p.Accepted, itemName(it.index))
+			}
+			problem.Send(w, p)
+			return
+		}
+
+		if len(invalid) > 0 {
+			log.Info("Batch partially written", zap.Int("written", len(written)), zap.Int("rejected", len(invalid)))
+			status, code := http.StatusBadRequest, problem.CodePartialWrite
+			if len(written) == 0 {
+				status, code = http.StatusUnprocessableEntity, problem.CodeValidation
+			}
+			p := problem.New(r, status, code, fmt.Sprintf("%d of %d metrics were rejected", len(invalid), len(raw)))
+			p.InvalidParams = invalid
+			for _, it := range written {
+				p.Accepted = append(p.Accepted, itemName(it.index))
+			}
+			problem.Send(w, p)
+			return
+		}
+
+		metrics := make([]format.Metric, 0, len(written))
+		for _, it := range written {
+			metrics = append(metrics, it.metric)
+		}
+		body, err := json.Marshal(metrics)
 		if err != nil {
 			log.Error("Error encoding response", zap.Error(err))
 			return
 		}
 
+		w.Header().Set("Content-Type", "application/json")
 		if sha256key != "" {
 			hashStr := signature.GetHash(sha256key, string(body), log)
 			w.Header().Set("HashSHA256", hashStr)
 		}
 
-		w.Write(body)
 		w.WriteHeader(http.StatusOK)
+		w.Write(body)
 	}
 }
 
-// backupHandler handles the backup of a single metric.
-// It takes a logger, backup handler, and the metric to be backed up.
-// Returns an error if the metric type is undefined or if the backup fails.
-func backupHandler(log *zap.Logger, backup Backuper, metric format.Metric) error {
-	const op = "handlers.updates.backup"
-	if backup.IsSyncMode() {
-		var backupVal string
-		switch metric.MType {
+// decodeItems reads the JSON array of the batch one item at a time, so that a batch
+// exceeding maxItems is refused before the rest of it is read.
+func decodeItems(body io.Reader, maxItems int) ([]json.RawMessage, error) {
+	dec := json.NewDecoder(body)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("request body must be a JSON array of metrics")
+	}
+
+	var items []json.RawMessage
+	for dec.More() {
+		if maxItems > 0 && len(items) == maxItems {
+			return nil, errTooManyItems
+		}
+		var item json.RawMessage
+		if err = dec.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if _, err = dec.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// validate returns the reason the metric cannot be written or an empty string.
+func validate(metric format.Metric) string {
+	if metric.ID == "" {
+		return "metric name is empty"
+	}
+	switch metric.MType {
+	case format.Gauge:
+		if metric.Value == nil {
+			return "gauge requires a value"
+		}
+	case format.Counter:
+		if metric.Delta == nil {
+			return "counter requires a delta"
+		}
+	default:
+		return "metric type must be gauge or counter"
+	}
+	return ""
+}
+
+// itemName identifies an item of the batch in the response.
+func itemName(index int) string {
+	return "[" + strconv.Itoa(index) + "]"
+}
+
+// write saves the items in one batch. In ModeBestEffort a batch the storage refuses
+// because of an invalid value is retried item by item, so that only the offending
+// items are rejected.
+//
+// Returns the written items, the rejected ones and an error if the storage failed.
+// Items written one by one stay committed when the storage fails on a later item: they
+// are returned with the error, and the items not written are added to the rejected ones.
+func write(ctx context.Context, storage Updater, items []item, mode Mode) ([]item, []problem.InvalidParam, error) {
+	gauges, counters := rows(items)
+	err := storage.UpdateBatch(ctx, gauges, counters)
+	if err == nil {
+		return items, nil, nil
+	}
+	if mode != ModeBestEffort || !errors.Is(err, storageErrors.ErrInvalidValue) {
+		return nil, nil, err
+	}
+
+	var written []item
+	var refused []problem.InvalidParam
+	for i, it := range items {
+		gauges, counters = rows([]item{it})
+		err = storage.UpdateBatch(ctx, gauges, counters)
+		switch {
+		case err == nil:
+			written = append(written, it)
+		case errors.Is(err, storageErrors.ErrInvalidValue):
+			refused = append(refused, problem.InvalidParam{Name: itemName(it.index), Reason: err.Error()})
+		default:
+			for _, rest := range items[i:] {
+				refused = append(refused, problem.InvalidParam{Name: itemName(rest.index), Reason: "not written: the storage failed"})
+			}
+			return written, refused, err
+		}
+	}
+	return written, refused, nil
+}
+
+// rows converts the items into the gauges and counters of the storage.
+func rows(items []item) ([][]string, [][]string) {
+	var gauges, counters [][]string
+	for _, it := range items {
+		switch it.metric.MType {
 		case format.Gauge:
-			backupVal = strconv.FormatFloat(*metric.Value, 'f', -1, 64)
+			gauges = append(gauges, []string{it.metric.ID, strconv.FormatFloat(*it.metric.Value, 'f', -1, 64)})
 		case format.Counter:
-			backupVal = strconv.FormatInt(*metric.Delta, 10)
-		default:
-			log.Error("Undefined metric type", zap.String("type", metric.MType))
-			return fmt.Errorf("%s: %s", op, "Undefined metric type")
+			counters = append(counters, []string{it.metric.ID, strconv.FormatInt(*it.metric.Delta, 10)})
+		}
+	}
+	return gauges, counters
+}
+
+// backupHandler saves the written metrics to the backup when it is in synchronous mode.
+func backupHandler(log *zap.Logger, backup Backuper, items []item) {
+	if !backup.IsSyncMode() || len(items) == 0 {
+		return
+	}
+	for _, it := range items {
+		if err := backup.SaveToStruct(it.metric.MType, it.metric.ID, it.metric.StoredValue()); err != nil {
+			log.Error("Cannot backup metric", zap.String("name", it.metric.ID), zap.Error(err))
 		}
-		backup.SaveToStruct(metric.MType, metric.ID, backupVal)
-		backup.SaveToFile()
 	}
-	return nil
+	backup.SaveToFile()
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi"
@@ -17,7 +18,9 @@ import (
 	"github.com/mbiwapa/metric/internal/server/handlers/updates/mocks"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 	"github.com/mbiwapa/metric/internal/lib/signature"
+	"github.com/mbiwapa/metric/internal/storage"
 )
 
 func TestNewJSON_SuccessfulEncodingAndSigning(t *testing.T) {
@@ -91,6 +94,204 @@ func TestNewJSON_SuccessfulEncodingAndSigning(t *testing.T) {
 	}
 }
 
+func TestNewJSON_InvalidItems(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         Mode
+		body         string
+		wantStatus   int
+		wantCode     string
+		wantGauges   [][]string
+		wantCounters [][]string
+		wantInvalid  []string
+		wantAccepted []string
+	}{
+		{
+			name:        "Atomic mode rejects a gauge without a value",
+			mode:        ModeAtomic,
+			body:        `[{"id":"g","type":"gauge"},{"id":"c","type":"counter","delta":1}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    problem.CodeValidation,
+			wantInvalid: []string{"[0]"},
+		},
+		{
+			name:        "Atomic mode rejects an unknown type",
+			mode:        ModeAtomic,
+			body:        `[{"id":"c","type":"counter","delta":1},{"id":"h","type":"histogram","value":1}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    problem.CodeValidation,
+			wantInvalid: []string{"[1]"},
+		},
+		{
+			name:        "Atomic mode rejects a malformed item",
+			mode:        ModeAtomic,
+			body:        `[{"id":"g","type":"gauge","value":"1"}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    problem.CodeValidation,
+			wantInvalid: []string{"[0]"},
+		},
+		{
+			name:         "Best-effort mode writes the valid items",
+			mode:         ModeBestEffort,
+			body:         `[{"id":"g","type":"gauge","value":1.5},{"id":"c","type":"counter"},{"type":"gauge","value":1}]`,
+			wantStatus:   http.StatusBadRequest,
+			wantCode:     problem.CodePartialWrite,
+			wantGauges:   [][]string{{"g", "1.5"}},
+			wantInvalid:  []string{"[1]", "[2]"},
+			wantAccepted: []string{"[0]"},
+		},
+		{
+			name:        "Best-effort mode without valid items",
+			mode:        ModeBestEffort,
+			body:        `[{"id":"c","type":"counter"}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    problem.CodeValidation,
+			wantInvalid: []string{"[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UpdaterMock := mocks.NewUpdater(t)
+			BackuperMock := mocks.NewBackuper(t)
+			if tt.wantAccepted != nil {
+				UpdaterMock.On("UpdateBatch", mock.Anything, tt.wantGauges, tt.wantCounters).Return(nil).Once()
+				BackuperMock.On("IsSyncMode").Return(false)
+			}
+
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Post("/updates", NewJSON(zap.NewNop(), UpdaterMock, BackuperMock, "", WithMode(tt.mode)))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			require.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+
+			var p problem.Problem
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+			require.Equal(t, tt.wantCode, p.Code)
+			require.Equal(t, tt.wantAccepted, p.Accepted)
+			var invalid []string
+			for _, param := range p.InvalidParams {
+				require.NotEmpty(t, param.Reason)
+				invalid = append(invalid, param.Name)
+			}
+			require.Equal(t, tt.wantInvalid, invalid)
+		})
+	}
+}
+
+func TestNewJSON_BestEffortStorageRefusal(t *testing.T) {
+	UpdaterMock := mocks.NewUpdater(t)
+	BackuperMock := mocks.NewBackuper(t)
+
+	UpdaterMock.On("UpdateBatch", mock.Anything, [][]string{{"g", "1"}}, [][]string{{"c", "2"}}).Return(storage.ErrInvalidValue).Once()
+	UpdaterMock.On("UpdateBatch", mock.Anything, [][]string{{"g", "1"}}, [][]string(nil)).Return(storage.ErrInvalidValue).Once()
+	UpdaterMock.On("UpdateBatch", mock.Anything, [][]string(nil), [][]string{{"c", "2"}}).Return(nil).Once()
+	BackuperMock.On("IsSyncMode").Return(true)
+	BackuperMock.On("SaveToStruct", format.Counter, "c", "2").Return(nil).Once()
+	BackuperMock.On("SaveToFile").Return().Once()
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Post("/updates", NewJSON(zap.NewNop(), UpdaterMock, BackuperMock, "", WithMode(ModeBestEffort)))
+
+	body := `[{"id":"g","type":"gauge","value":1},{"id":"c","type":"counter","delta":2}]`
+	req := httptest.NewRequest(http.MethodPost, "/updates", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var p problem.Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	require.Equal(t, problem.CodePartialWrite, p.Code)
+	require.Equal(t, []string{"[1]"}, p.Accepted)
+	require.Len(t, p.InvalidParams, 1)
+	require.Equal(t, "[0]", p.InvalidParams[0].Name)
+}
+
+func TestNewJSON_BestEffortStorageFailureAfterPartialWrite(t *testing.T) {
+	UpdaterMock := mocks.NewUpdater(t)
+	BackuperMock := mocks.NewBackuper(t)
+
+	UpdaterMock.On("UpdateBatch", mock.Anything, [][]string{{"g", "1"}}, [][]string{{"c", "2"}, {"d", "3"}}).Return(storage.ErrInvalidValue).Once()
+	UpdaterMock.On("UpdateBatch", mock.Anything, [][]string{{"g", "1"}}, [][]string(nil)).Return(storage.ErrInvalidValue).Once()
+	UpdaterMock.On("UpdateBatch", mock.Anything, [][]string(nil), [][]string{{"c", "2"}}).Return(nil).Once()
+	UpdaterMock.On("UpdateBatch", mock.Anything, [][]string(nil), [][]string{{"d", "3"}}).Return(storage.ErrStorageUnavailable).Once()
+	BackuperMock.On("IsSyncMode").Return(true)
+	BackuperMock.On("SaveToStruct", format.Counter, "c", "2").Return(nil).Once()
+	BackuperMock.On("SaveToFile").Return().Once()
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Post("/updates", NewJSON(zap.NewNop(), UpdaterMock, BackuperMock, "", WithMode(ModeBestEffort)))
+
+	body := `[{"id":"g","type":"gauge","value":1},{"id":"c","type":"counter","delta":2},{"id":"d","type":"counter","delta":3}]`
+	req := httptest.NewRequest(http.MethodPost, "/updates", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	// The committed counter is reported, so that a retry does not add its delta again.
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var p problem.Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	require.Equal(t, problem.CodePartialWrite, p.Code)
+	require.Equal(t, []string{"[1]"}, p.Accepted)
+	var invalid []string
+	for _, param := range p.InvalidParams {
+		invalid = append(invalid, param.Name)
+	}
+	require.Equal(t, []string{"[0]", "[2]"}, invalid)
+}
+
+func TestNewJSON_StorageErrorSkipsBackup(t *testing.T) {
+	UpdaterMock := mocks.NewUpdater(t)
+	BackuperMock := mocks.NewBackuper(t)
+
+	UpdaterMock.On("UpdateBatch", mock.Anything, mock.Anything, mock.Anything).Return(storage.ErrStorageUnavailable).Once()
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Post("/updates", NewJSON(zap.NewNop(), UpdaterMock, BackuperMock, ""))
+
+	body := `[{"id":"g","type":"gauge","value":1}]`
+	req := httptest.NewRequest(http.MethodPost, "/updates", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.NotEqual(t, http.StatusOK, rr.Code)
+	BackuperMock.AssertNotCalled(t, "SaveToStruct", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Mode
+		wantErr bool
+	}{
+		{name: "", want: ModeAtomic},
+		{name: "atomic", want: ModeAtomic},
+		{name: "best-effort", want: ModeBestEffort},
+		{name: "partial", want: ModeAtomic, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMode(tt.name)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func float64Ptr(f float64) *float64 {
 	return &f
 }
@@ -145,3 +346,42 @@ func ExampleNewJSON() {
 	//1bd2e889d00afc2fcf7b3dba6f9426ae97e29db3fff69a78011b5baf5325d125
 	//[{"id":"testGauge","type":"gauge","value":0.5653},{"id":"testCounter","type":"counter","delta":10}]
 }
+
+func TestNewJSON_TooLarge(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		maxBody int64
+	}{
+		{
+			name: "Too many metrics",
+			body: `[{"id":"a","type":"counter","delta":1},{"id":"b","type":"counter","delta":1},{"id":"c","type":"counter","delta":1}]`,
+		},
+		{
+			name:    "Body cut off at the size limit",
+			body:    `[{"id":"a","type":"counter","delta":1},{"id":"` + strings.Repeat("b", 1024) + `","type":"counter","delta":1}]`,
+			maxBody: 256,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UpdaterMock := mocks.NewUpdater(t)
+			BackuperMock := mocks.NewBackuper(t)
+
+			handler := NewJSON(zap.NewNop(), UpdaterMock, BackuperMock, "", WithMaxItems(2))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			if tt.maxBody > 0 {
+				req.Body = http.MaxBytesReader(rr, req.Body, tt.maxBody)
+			}
+			handler(rr, req)
+
+			require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+			var p problem.Problem
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+			require.Equal(t, problem.CodeTooLarge, p.Code)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package value
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
+)
+
+// AllMetricGeter interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=AllMetricGeter
+type AllMetricGeter interface {
+	// GetAllMetrics retrieves all gauge and counter metrics from the storage.
+	// Parameters:
+	// - ctx: context for managing request deadlines and cancellation signals.
+	// Returns:
+	// - [][]string: gauge metrics as name and value pairs.
+	// - [][]string: counter metrics as name and value pairs.
+	// - error: error if the storage cannot be read.
+	GetAllMetrics(ctx context.Context) ([][]string, [][]string, error)
+}
+
+// NewList returns an HTTP handler function for listing the stored metrics.
+// The metrics are sorted by name and rendered in JSON unless the Accept header asks for
+// another format of the serializer registry. The "type" query parameter limits the list
+// to gauges or counters.
+// Parameters:
+// - log: logger for logging information and errors.
+// - storage: an implementation of the AllMetricGeter interface for accessing metrics.
+// - serializers: the formats the response can be rendered in.
+// - sha256key: a key used for generating SHA256 hash of the response body.
+// Returns:
+// - http.HandlerFunc: the HTTP handler function.
+func NewList(log *zap.Logger, storage AllMetricGeter, serializers *serializer.Registry, sha256key string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.value.NewList"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		typ := r.URL.Query().Get("type")
+		if typ != "" && typ != format.Gauge && typ != format.Counter {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeUnknownType, "metric type must be gauge or counter")
+			return
+		}
+
+		s, err := serializers.Negotiate(r.Header.Get("Accept"), serializer.ContentTypeJSON)
+		if err != nil {
+			log.Info("No acceptable format", zap.String("accept", r.Header.Get("Accept")))
+			problem.Write(w, r, http.StatusNotAcceptable, problem.CodeNotAcceptable, err.Error())
+			return
+		}
+
+		databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		gauges, counters, err := storage.GetAllMetrics(databaseCtx)
+		if err != nil {
+			log.Error("Failed to get all metrics", zap.Error(err))
+			problem.WriteError(w, r, err)
+			return
+		}
+
+		switch typ {
+		case format.Gauge:
+			counters = nil
+		case format.Counter:
+			gauges = nil
+		}
+		metrics := format.NewMetrics(gauges, counters)
+		sort.SliceStable(metrics, func(i, j int) bool { return metrics[i].ID < metrics[j].ID })
+
+		write(w, r, log, s, sha256key, func(w io.Writer) error {
+			return s.WriteMetrics(w, metrics)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package value
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
+	"github.com/mbiwapa/metric/internal/server/handlers/value/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+func TestNewList(t *testing.T) {
+	gauges := [][]string{{"Alloc", "1.5"}, {"Broken", "x"}}
+	counters := [][]string{{"PollCount", "42"}}
+
+	tests := []struct {
+		name            string
+		query           string
+		accept          string
+		mockError       error
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "JSON by default",
+			wantStatus:      http.StatusOK,
+			wantContentType: serializer.ContentTypeJSON,
+			wantBody:        `[{"id":"Alloc","type":"gauge","value":1.5},{"id":"PollCount","type":"counter","delta":42}]`,
+		},
+		{
+			name:            "CSV of counters",
+			query:           "?type=counter",
+			accept:          "text/csv",
+			wantStatus:      http.StatusOK,
+			wantContentType: serializer.ContentTypeCSV,
+			wantBody:        "id,type,value\nPollCount,counter,42\n",
+		},
+		{
+			name:            "Prometheus",
+			accept:          "text/plain; version=0.0.4",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; version=0.0.4; charset=utf-8",
+			wantBody:        "# TYPE Alloc gauge\nAlloc 1.5\n# TYPE PollCount counter\nPollCount 42\n",
+		},
+		{
+			name:       "unknown type",
+			query:      "?type=histogram",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not acceptable",
+			accept:     "image/png",
+			wantStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:       "storage unavailable",
+			mockError:  storageErrors.ErrStorageUnavailable,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewAllMetricGeter(t)
+			if tt.wantStatus == http.StatusOK || tt.mockError != nil {
+				storage.On("GetAllMetrics", mock.Anything).Return(gauges, counters, tt.mockError).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/values/"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			NewList(zap.NewNop(), storage, serializer.NewDefault(), "").ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.wantContentType, rr.Header().Get("Content-Type"))
+			require.Equal(t, tt.wantBody, rr.Body.String())
+		})
+	}
+}
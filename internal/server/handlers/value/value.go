@@ -1,8 +1,10 @@
 package value
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"time"
 
@@ -10,6 +12,9 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"go.uber.org/zap"
 
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
 	"github.com/mbiwapa/metric/internal/lib/signature"
 	storageErrors "github.com/mbiwapa/metric/internal/storage"
 )
@@ -30,19 +35,22 @@ type MetricGeter interface {
 }
 
 // New returns an HTTP handler function for retrieving a metric value.
+// The value is returned as plain text unless the Accept header asks for another
+// format of the serializer registry.
 // Parameters:
 // - log: logger for logging information and errors.
 // - storage: an implementation of the MetricGeter interface for accessing metrics.
-// - sha256key: a key used for generating SHA256 hash of the metric value.
+// - serializers: the formats the response can be rendered in.
+// - sha256key: a key used for generating SHA256 hash of the response body.
 // Returns:
 // - http.HandlerFunc: the HTTP handler function.
-func New(log *zap.Logger, storage MetricGeter, sha256key string) http.HandlerFunc {
+func New(log *zap.Logger, storage MetricGeter, serializers *serializer.Registry, sha256key string) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.value.New"
 
 		ctx := r.Context()
-		log.With(
+		log := log.With(
 			zap.String("op", op),
 			zap.String("request_id", middleware.GetReqID(ctx)),
 		)
@@ -54,7 +62,14 @@ func New(log *zap.Logger, storage MetricGeter, sha256key string) http.HandlerFun
 				"Name or Type is empty!",
 				zap.String("name", name),
 				zap.String("type", typ))
-			w.WriteHeader(http.StatusNotFound)
+			problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "metric name or type is empty")
+			return
+		}
+
+		s, err := serializers.Negotiate(r.Header.Get("Accept"), serializer.ContentTypeText)
+		if err != nil {
+			log.Info("No acceptable format", zap.String("accept", r.Header.Get("Accept")))
+			problem.Write(w, r, http.StatusNotAcceptable, problem.CodeNotAcceptable, err.Error())
 			return
 		}
 
@@ -67,20 +82,44 @@ func New(log *zap.Logger, storage MetricGeter, sha256key string) http.HandlerFun
 				"Metric is not found",
 				zap.String("name", name),
 				zap.String("type", typ))
-			w.WriteHeader(http.StatusNotFound)
+			problem.WriteError(w, r, err)
 			return
 		}
 		if err != nil && !errors.Is(err, storageErrors.ErrMetricNotFound) {
 			log.Error("Failed to get metric", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.WriteError(w, r, err)
 			return
 		}
 
-		if sha256key != "" {
-			hashStr := signature.GetHash(sha256key, string(value), log)
-			w.Header().Set("HashSHA256", hashStr)
+		metric, err := format.NewMetric(typ, name, value)
+		if err != nil {
+			log.Error("Failed to parse stored value", zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "stored value cannot be parsed")
+			return
 		}
 
-		w.Write([]byte(value))
+		write(w, r, log, s, sha256key, func(w io.Writer) error {
+			return s.WriteMetric(w, metric)
+		})
+	}
+}
+
+// write renders the response with the serializer and sends it with its content type
+// and, if a key is set, the hash of the body.
+func write(w http.ResponseWriter, r *http.Request, log *zap.Logger, s serializer.Serializer, sha256key string, render func(w io.Writer) error) {
+	var body bytes.Buffer
+	if err := render(&body); err != nil {
+		log.Error("Error encoding response", zap.Error(err))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "cannot encode response")
+		return
+	}
+
+	w.Header().Set("Content-Type", s.ContentType())
+	w.Header().Add("Vary", "Accept")
+	if sha256key != "" {
+		hashStr := signature.GetHash(sha256key, body.String(), log)
+		w.Header().Set("HashSHA256", hashStr)
 	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
 }
@@ -5,39 +5,49 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"go.uber.org/zap"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
-	"github.com/mbiwapa/metric/internal/lib/signature"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
 	storageErrors "github.com/mbiwapa/metric/internal/storage"
 )
 
 // NewJSON returns an HTTP handler function that processes metric requests and responds with the metric data in JSON format.
 // It logs the request, decodes the JSON body, retrieves the metric from storage, and writes the response.
+// The Accept header may ask for another format of the serializer registry.
 //
 // Parameters:
 // - log: A zap.Logger instance for logging.
 // - storage: An implementation of the MetricGeter interface for retrieving metrics from storage.
+// - serializers: The formats the response can be rendered in.
 // - sha256key: A string key used for generating SHA256 hash of the response body.
 //
 // Returns:
 // - An http.HandlerFunc that handles the HTTP request and response.
-func NewJSON(log *zap.Logger, storage MetricGeter, sha256key string) http.HandlerFunc {
+func NewJSON(log *zap.Logger, storage MetricGeter, serializers *serializer.Registry, sha256key string) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.value.NewJSON"
 
 		ctx := r.Context()
-		log.With(
+		log := log.With(
 			zap.String("op", op),
 			zap.String("request_id", middleware.GetReqID(ctx)),
 		)
 
+		s, err := serializers.Negotiate(r.Header.Get("Accept"), serializer.ContentTypeJSON)
+		if err != nil {
+			log.Info("No acceptable format", zap.String("accept", r.Header.Get("Accept")))
+			problem.Write(w, r, http.StatusNotAcceptable, problem.CodeNotAcceptable, err.Error())
+			return
+		}
+
 		var metricRequest format.Metric
 
 		// Decode the JSON request body into metricRequest
@@ -45,7 +55,7 @@ func NewJSON(log *zap.Logger, storage MetricGeter, sha256key string) http.Handle
 		if err := dec.Decode(&metricRequest); err != nil {
 			log.Error(
 				"Cannot decode request JSON body", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.WriteReadError(w, r, err, problem.CodeInvalidJSON, err.Error())
 			return
 		}
 
@@ -55,7 +65,7 @@ func NewJSON(log *zap.Logger, storage MetricGeter, sha256key string) http.Handle
 				"Name or Type is empty!",
 				zap.String("name", metricRequest.ID),
 				zap.String("type", metricRequest.MType))
-			w.WriteHeader(http.StatusNotFound)
+			problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "metric name or type is empty")
 			return
 		}
 
@@ -70,54 +80,24 @@ func NewJSON(log *zap.Logger, storage MetricGeter, sha256key string) http.Handle
 				"Metric is not found",
 				zap.String("name", metricRequest.ID),
 				zap.String("type", metricRequest.MType))
-			w.WriteHeader(http.StatusNotFound)
+			problem.WriteError(w, r, errStor)
 			return
 		}
 		if errStor != nil && !errors.Is(errStor, storageErrors.ErrMetricNotFound) {
 			log.Error("Failed to get metric", zap.Error(errStor))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.WriteError(w, r, errStor)
 			return
 		}
 
-		// Parse the metric value based on its type
-		switch metricRequest.MType {
-		case format.Gauge:
-			val, err := strconv.ParseFloat(value, 64)
-			if err != nil {
-				log.Error("Failed to parse gauge value", zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
-			metricRequest.Value = &val
-		case format.Counter:
-			val, err := strconv.ParseInt(value, 0, 64)
-			if err != nil {
-				log.Error("Failed to parse counter value", zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
-			metricRequest.Delta = &val
-		default:
-		}
-
-		// Set the response content type to JSON
-		w.Header().Set("Content-Type", "application/json")
-
-		// Marshal the metric request into JSON
-		body, err := json.Marshal(metricRequest)
+		metric, err := format.NewMetric(metricRequest.MType, metricRequest.ID, value)
 		if err != nil {
-			log.Error("Error encoding response", zap.Error(err))
+			log.Error("Failed to parse stored value", zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "stored value cannot be parsed")
 			return
 		}
 
-		// If a SHA256 key is provided, generate and set the hash header
-		if sha256key != "" {
-			hashStr := signature.GetHash(sha256key, string(body), log)
-			w.Header().Set("HashSHA256", hashStr)
-		}
-
-		// Write the response body and set the status code to OK
-		w.Write(body)
-		w.WriteHeader(http.StatusOK)
+		write(w, r, log, s, sha256key, func(w io.Writer) error {
+			return s.WriteMetric(w, metric)
+		})
 	}
 }
@@ -10,7 +10,10 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 
+	"github.com/mbiwapa/metric/internal/lib/api/serializer"
+	"github.com/mbiwapa/metric/internal/lib/signature"
 	"github.com/mbiwapa/metric/internal/logger"
 	"github.com/mbiwapa/metric/internal/server/handlers/value/mocks"
 )
@@ -65,7 +68,7 @@ func TestNew(t *testing.T) {
 
 			r := chi.NewRouter()
 			r.Use(middleware.URLFormat)
-			r.Get("/value/{type}/{name}", New(logger, MetricGeterMock, ""))
+			r.Get("/value/{type}/{name}", New(logger, MetricGeterMock, serializer.NewDefault(), ""))
 			ts := httptest.NewServer(r)
 			defer ts.Close()
 
@@ -82,6 +85,68 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_Accept(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "plain text by default",
+			wantStatus:      http.StatusOK,
+			wantContentType: serializer.ContentTypeText,
+			wantBody:        "42",
+		},
+		{
+			name:            "JSON",
+			accept:          "application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: serializer.ContentTypeJSON,
+			wantBody:        `{"id":"PollCount","type":"counter","delta":42}`,
+		},
+		{
+			name:            "CSV",
+			accept:          "text/csv",
+			wantStatus:      http.StatusOK,
+			wantContentType: serializer.ContentTypeCSV,
+			wantBody:        "id,type,value\nPollCount,counter,42\n",
+		},
+		{
+			name:       "not acceptable",
+			accept:     "image/png",
+			wantStatus: http.StatusNotAcceptable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewMetricGeter(t)
+			if tt.wantStatus == http.StatusOK {
+				storage.On("GetMetric", mock.Anything, "counter", "PollCount").Return("42", nil).Once()
+			}
+
+			r := chi.NewRouter()
+			r.Get("/value/{type}/{name}", New(zap.NewNop(), storage, serializer.NewDefault(), "key"))
+
+			req := httptest.NewRequest(http.MethodGet, "/value/counter/PollCount", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.wantContentType, rr.Header().Get("Content-Type"))
+			require.Equal(t, tt.wantBody, rr.Body.String())
+			require.Equal(t, signature.GetHash("key", tt.wantBody, zap.NewNop()), rr.Header().Get("HashSHA256"))
+		})
+	}
+}
+
 func ExampleNew() {
 	logger, _ := logger.New("info")
 
@@ -90,7 +155,7 @@ func ExampleNew() {
 
 	r := chi.NewRouter()
 	r.Use(middleware.URLFormat)
-	r.Get("/value/{type}/{name}", New(logger, mockMetricGeter, ""))
+	r.Get("/value/{type}/{name}", New(logger, mockMetricGeter, serializer.NewDefault(), ""))
 
 	req, _ := http.NewRequest(http.MethodGet, "/value/gauge/test1", nil)
 	rr := httptest.NewRecorder()
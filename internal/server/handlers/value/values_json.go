@@ -0,0 +1,146 @@
+package value
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/signature"
+)
+
+// MetricsGeter interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=MetricsGeter
+type MetricsGeter interface {
+	// GetMetrics retrieves the metrics with the given names in one query.
+	// Parameters:
+	// - ctx: context for managing request deadlines and cancellation signals.
+	// - gauges: names of the gauge metrics.
+	// - counters: names of the counter metrics.
+	// Returns:
+	// - [][]string: the found gauge metrics as name and value pairs.
+	// - [][]string: the found counter metrics as name and value pairs.
+	// - error: error if the storage cannot be read.
+	GetMetrics(ctx context.Context, gauges []string, counters []string) ([][]string, [][]string, error)
+}
+
+// MetricRef identifies a requested metric.
+type MetricRef struct {
+	ID    string `json:"id"`   // ID is the name of the metric.
+	MType string `json:"type"` // MType is the type of the metric, gauge or counter.
+}
+
+// BulkResponse is the body of a successful bulk read.
+type BulkResponse struct {
+	Metrics []format.Metric `json:"metrics"` // Metrics are the found metrics, in the order of the request.
+	Missing []MetricRef     `json:"missing"` // Missing are the requested metrics that do not exist.
+}
+
+// NewBulkJSON returns an HTTP handler function for reading many metrics at once.
+// The request body is a JSON array of {"id", "type"} objects. All of them are read from
+// the storage in a single call and the response lists the found metrics and the misses.
+// A missing metric is not an error; an item without a name or with an unknown type rejects
+// the request with 400 and the offending items in invalid_params.
+// Parameters:
+// - log: logger for logging information and errors.
+// - storage: an implementation of the MetricsGeter interface for accessing metrics.
+// - sha256key: a key used for generating SHA256 hash of the response body.
+// Returns:
+// - http.HandlerFunc: the HTTP handler function.
+func NewBulkJSON(log *zap.Logger, storage MetricsGeter, sha256key string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.value.NewBulkJSON"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		var refs []MetricRef
+		if err := json.NewDecoder(r.Body).Decode(&refs); err != nil {
+			log.Error("Cannot decode request JSON body", zap.Error(err))
+			problem.WriteReadError(w, r, err, problem.CodeInvalidJSON, err.Error())
+			return
+		}
+
+		var gauges, counters []string
+		var invalid []problem.InvalidParam
+		for i, ref := range refs {
+			switch {
+			case ref.ID == "":
+				invalid = append(invalid, problem.InvalidParam{Name: fmt.Sprintf("[%d]", i), Reason: "metric name is empty"})
+			case ref.MType == format.Gauge:
+				gauges = append(gauges, ref.ID)
+			case ref.MType == format.Counter:
+				counters = append(counters, ref.ID)
+			default:
+				invalid = append(invalid, problem.InvalidParam{Name: fmt.Sprintf("[%d]", i), Reason: "metric type must be gauge or counter"})
+			}
+		}
+		if len(invalid) > 0 {
+			log.Info("Invalid metric references", zap.Int("invalid", len(invalid)))
+			p := problem.New(r, http.StatusBadRequest, problem.CodeBadRequest, "some requested metrics are invalid")
+			p.InvalidParams = invalid
+			problem.Send(w, p)
+			return
+		}
+
+		databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		foundGauges, foundCounters, err := storage.GetMetrics(databaseCtx, gauges, counters)
+		if err != nil {
+			log.Error("Failed to get metrics", zap.Error(err))
+			problem.WriteError(w, r, err)
+			return
+		}
+
+		values := make(map[MetricRef]string, len(foundGauges)+len(foundCounters))
+		for _, m := range foundGauges {
+			values[MetricRef{ID: m[0], MType: format.Gauge}] = m[1]
+		}
+		for _, m := range foundCounters {
+			values[MetricRef{ID: m[0], MType: format.Counter}] = m[1]
+		}
+
+		resp := BulkResponse{Metrics: []format.Metric{}, Missing: []MetricRef{}}
+		for _, ref := range refs {
+			value, ok := values[ref]
+			if !ok {
+				resp.Missing = append(resp.Missing, ref)
+				continue
+			}
+			metric, err := format.NewMetric(ref.MType, ref.ID, value)
+			if err != nil {
+				log.Error("Failed to parse stored value", zap.String("name", ref.ID), zap.Error(err))
+				problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "stored value cannot be parsed")
+				return
+			}
+			resp.Metrics = append(resp.Metrics, metric)
+		}
+
+		body, err := json.Marshal(resp)
+		if err != nil {
+			log.Error("Error encoding response", zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "cannot encode response")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if sha256key != "" {
+			hashStr := signature.GetHash(sha256key, string(body), log)
+			w.Header().Set("HashSHA256", hashStr)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}
+}
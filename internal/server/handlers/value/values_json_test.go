@@ -0,0 +1,87 @@
+package value
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/signature"
+	"github.com/mbiwapa/metric/internal/server/handlers/value/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+func TestNewBulkJSON(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantGauges   []string
+		wantCounters []string
+		mockError    error
+		wantStatus   int
+		wantBody     string
+	}{
+		{
+			name:         "found and missing",
+			body:         `[{"id":"PollCount","type":"counter"},{"id":"Alloc","type":"gauge"},{"id":"Nope","type":"gauge"}]`,
+			wantGauges:   []string{"Alloc", "Nope"},
+			wantCounters: []string{"PollCount"},
+			wantStatus:   http.StatusOK,
+			wantBody: `{"metrics":[{"id":"PollCount","type":"counter","delta":42},{"id":"Alloc","type":"gauge","value":1.5}],` +
+				`"missing":[{"id":"Nope","type":"gauge"}]}`,
+		},
+		{
+			name:       "empty request",
+			body:       `[]`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"metrics":[],"missing":[]}`,
+		},
+		{
+			name:       "invalid items",
+			body:       `[{"id":"","type":"gauge"},{"id":"Alloc","type":"histogram"}]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"invalid_params":[{"name":"[0]","reason":"metric name is empty"},{"name":"[1]","reason":"metric type must be gauge or counter"}]`,
+		},
+		{
+			name:       "invalid JSON",
+			body:       `{"id":"Alloc"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":"invalid_json"`,
+		},
+		{
+			name:       "storage unavailable",
+			body:       `[{"id":"Alloc","type":"gauge"}]`,
+			wantGauges: []string{"Alloc"},
+			mockError:  storageErrors.ErrStorageUnavailable,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"code":"storage_unavailable"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewMetricsGeter(t)
+			if tt.wantStatus == http.StatusOK || tt.mockError != nil {
+				storage.On("GetMetrics", mock.Anything, tt.wantGauges, tt.wantCounters).
+					Return([][]string{{"Alloc", "1.5"}}, [][]string{{"PollCount", "42"}}, tt.mockError).
+					Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/values/", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			NewBulkJSON(zap.NewNop(), storage, "key").ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus != http.StatusOK {
+				require.Contains(t, rr.Body.String(), tt.wantBody)
+				return
+			}
+			require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			require.JSONEq(t, tt.wantBody, rr.Body.String())
+			require.Equal(t, signature.GetHash("key", rr.Body.String(), zap.NewNop()), rr.Header().Get("HashSHA256"))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package watch provides the HTTP handler that streams metric changes as Server-Sent Events.
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/changefeed"
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+// ContentType is the media type of the event stream.
+const ContentType = "text/event-stream"
+
+// keepAlive is the interval of comment lines that keep idle connections open through proxies.
+const keepAlive = 15 * time.Second
+
+// Subscriber interface for the change feed
+type Subscriber interface {
+	// Subscribe registers a subscriber for the changes matching filter.
+	Subscribe(filter changefeed.Filter) *changefeed.Subscription
+
+	// Unsubscribe removes the subscriber.
+	Unsubscribe(s *changefeed.Subscription)
+}
+
+// New returns an HTTP handler function for GET /watch.
+//
+// The handler streams every metric written after the request as a Server-Sent Event.
+// The event name is the metric type and the data is the metric in the JSON format of the
+// /value/ endpoint, with the value after the write (the total for counters).
+// The "type" query parameter limits the stream to gauges or counters and "prefix" to
+// metrics whose names start with it. A client that cannot keep up is sent a "dropped"
+// event and disconnected, so slow clients never block ingestion.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - feed: the change feed the storage publishes to.
+//
+// Returns:
+//   - An http.HandlerFunc that handles watch requests.
+func New(log *zap.Logger, feed Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.watch.New"
+
+		ctx := r.Context()
+		log := log.With(
+			zap.String("op", op),
+			zap.String("request_id", middleware.GetReqID(ctx)),
+		)
+
+		filter := changefeed.Filter{
+			Type:   r.URL.Query().Get("type"),
+			Prefix: r.URL.Query().Get("prefix"),
+		}
+		if filter.Type != "" && filter.Type != format.Gauge && filter.Type != format.Counter {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeUnknownType, "metric type must be gauge or counter")
+			return
+		}
+
+		sub := feed.Subscribe(filter)
+		defer feed.Unsubscribe(sub)
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			log.Error("Streaming is not supported", zap.Error(err))
+			return
+		}
+		log.Info("Watcher connected", zap.String("type", filter.Type), zap.String("prefix", filter.Prefix))
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+
+		var id int64
+		for {
+			select {
+			case c, ok := <-sub.C():
+				if !ok {
+					log.Warn("Watcher dropped, it is too slow")
+					fmt.Fprint(w, "event: dropped\ndata: {}\n\n")
+					_ = rc.Flush()
+					return
+				}
+				data, err := json.Marshal(metric(c))
+				if err != nil {
+					log.Error("Cannot encode change", zap.Error(err))
+					continue
+				}
+				id++
+				if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, c.Type, data); err != nil {
+					return
+				}
+				// Send the events that are already queued in one write.
+				if len(sub.C()) > 0 {
+					continue
+				}
+				if err = rc.Flush(); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// metric converts a change into the API representation of the metric.
+func metric(c storage.Change) format.Metric {
+	m := format.Metric{ID: c.Name, MType: c.Type}
+	if c.Type == format.Counter {
+		if v, err := strconv.ParseInt(c.Value, 10, 64); err == nil {
+			m.Delta = &v
+		}
+	} else if v, err := strconv.ParseFloat(c.Value, 64); err == nil {
+		m.Value = &v
+	}
+	return m
+}
@@ -0,0 +1,94 @@
+package watch
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/changefeed"
+	"github.com/mbiwapa/metric/internal/storage"
+)
+
+// readEvent reads the lines of the next event.
+func readEvent(t *testing.T, sc *bufio.Scanner) []string {
+	t.Helper()
+	var lines []string
+	for sc.Scan() {
+		if sc.Text() == "" {
+			return lines
+		}
+		lines = append(lines, sc.Text())
+	}
+	t.Fatal("event expected")
+	return nil
+}
+
+func TestNew_Stream(t *testing.T) {
+	hub := changefeed.New(zap.NewNop(), 10)
+	srv := httptest.NewServer(New(zap.NewNop(), hub))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?type=counter&prefix=Poll")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, ContentType, resp.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return hub.Len() == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Notify([]storage.Change{
+		{Type: "gauge", Name: "PollInterval", Value: "2"},
+		{Type: "counter", Name: "PollCount", Value: "7"},
+	})
+
+	sc := bufio.NewScanner(resp.Body)
+	require.Equal(t, []string{
+		"id: 1",
+		"event: counter",
+		`data: {"id":"PollCount","type":"counter","delta":7}`,
+	}, readEvent(t, sc))
+}
+
+// overflowingFeed drops every subscriber right after it subscribes.
+type overflowingFeed struct {
+	*changefeed.Hub
+}
+
+func (f overflowingFeed) Subscribe(filter changefeed.Filter) *changefeed.Subscription {
+	sub := f.Hub.Subscribe(filter)
+	f.Notify([]storage.Change{
+		{Type: "gauge", Name: "Alloc", Value: "1"},
+		{Type: "gauge", Name: "Alloc", Value: "2"},
+	})
+	return sub
+}
+
+func TestNew_Dropped(t *testing.T) {
+	hub := changefeed.New(zap.NewNop(), 1)
+	srv := httptest.NewServer(New(zap.NewNop(), overflowingFeed{hub}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// The buffered change is delivered, then the watcher learns it was dropped.
+	sc := bufio.NewScanner(resp.Body)
+	require.Contains(t, readEvent(t, sc), "event: gauge")
+	require.Equal(t, []string{"event: dropped", "data: {}"}, readEvent(t, sc))
+	require.False(t, sc.Scan())
+	require.Equal(t, 0, hub.Len())
+}
+
+func TestNew_InvalidType(t *testing.T) {
+	hub := changefeed.New(zap.NewNop(), 10)
+	rec := httptest.NewRecorder()
+	New(zap.NewNop(), hub)(rec, httptest.NewRequest(http.MethodGet, "/watch?type=histogram", nil))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, 0, hub.Len())
+}
@@ -0,0 +1,67 @@
+// Package auth provides a gRPC interceptor admitting calls carrying an API token with the scope of the method.
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+)
+
+// MetadataKey is the gRPC metadata key with the "Bearer <token>" credential.
+const MetadataKey = "authorization"
+
+// New creates an interceptor that requires an API token granting the scope of the called method.
+// A missing or unknown token is rejected with Unauthenticated, a token without the scope with
+// PermissionDenied. Methods without a scope require the admin scope.
+//
+// Parameters:
+//   - store: the issued tokens.
+//   - scopes: the scope required by every method, by its full name.
+//   - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+//   - grpc.UnaryServerInterceptor: the interceptor.
+func New(store apitoken.Store, scopes map[string]apitoken.Scope, log *zap.Logger) grpc.UnaryServerInterceptor {
+	log = log.With(
+		zap.String("op", "interceptor.auth.Check"),
+	)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		scope, ok := scopes[info.FullMethod]
+		if !ok {
+			scope = apitoken.ScopeAdmin
+		}
+
+		var secret string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(MetadataKey); len(v) > 0 {
+				secret, _ = apitoken.FromAuthorization(v[0])
+			}
+		}
+		if secret == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing API token")
+		}
+
+		token, err := store.Lookup(ctx, apitoken.Hash(secret))
+		if errors.Is(err, apitoken.ErrNotFound) {
+			log.Info("Unknown API token", zap.String("method", info.FullMethod))
+			return nil, status.Error(codes.Unauthenticated, "invalid API token")
+		}
+		if err != nil {
+			log.Error("Cannot look up API token", zap.Error(err))
+			return nil, status.Error(codes.Internal, "cannot check API token")
+		}
+		if !token.Allows(scope) {
+			log.Info("API token lacks the scope", zap.String("token", token.ID), zap.String("method", info.FullMethod))
+			return nil, status.Errorf(codes.PermissionDenied, "API token does not grant the %s scope", scope)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,53 @@
+// Package logger provides a gRPC interceptor for logging calls using zap.Logger.
+package logger
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key with the request ID set by the client.
+const RequestIDKey = "x-request-id"
+
+// New returns a new logger interceptor.
+// It logs the method, the request ID, the status code and the duration of each unary call,
+// the same details the HTTP logger middleware writes for requests.
+//
+// Parameters:
+//   - log: A zap.Logger instance used for logging.
+//
+// Returns:
+//   - grpc.UnaryServerInterceptor: the interceptor.
+func New(log *zap.Logger) grpc.UnaryServerInterceptor {
+	log = log.With(
+		zap.String("component", "interceptor/logger"),
+	)
+
+	log.Info("logger interceptor enabled")
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(RequestIDKey); len(v) > 0 {
+				requestID = v[0]
+			}
+		}
+		entry := log.With(
+			zap.String("method", info.FullMethod),
+			zap.String("request_id", requestID),
+		)
+
+		t1 := time.Now()
+		resp, err := handler(ctx, req)
+		entry.Info("request completed",
+			zap.String("code", status.Code(err).String()),
+			zap.String("duration", time.Since(t1).String()),
+		)
+		return resp, err
+	}
+}
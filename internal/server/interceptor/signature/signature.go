@@ -0,0 +1,68 @@
+// Package signature provides a gRPC interceptor for verifying the signature of incoming messages.
+package signature
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mbiwapa/metric/internal/lib/signature"
+)
+
+// New creates an interceptor that checks the signature of incoming messages.
+// The client sends the hash of the request in the "hashsha256" metadata; if both the hash and
+// the key are set, a mismatch is rejected with InvalidArgument. Responses are signed the same way
+// and the hash is returned in the response header.
+//
+// Parameters:
+//   - key: A secret key used to generate the expected hash of the message.
+//   - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+//   - grpc.UnaryServerInterceptor: the interceptor.
+func New(key string, log *zap.Logger) grpc.UnaryServerInterceptor {
+	log = log.With(
+		zap.String("op", "interceptor.signature.Check"),
+	)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(signature.MetadataKey); len(v) > 0 && v[0] != "" {
+				msg, ok := req.(proto.Message)
+				if !ok {
+					return nil, status.Error(codes.Internal, "request is not a protobuf message")
+				}
+				hashStr, err := signature.GetMessageHash(key, msg, log)
+				if err != nil {
+					log.Error("Cannot marshal request", zap.Error(err))
+					return nil, status.Error(codes.Internal, "cannot marshal request")
+				}
+				if hashStr != v[0] {
+					log.Error("Signature mismatch", zap.String("hashRequest", v[0]))
+					return nil, status.Error(codes.InvalidArgument, "hashsha256 does not match the request")
+				}
+			}
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if msg, ok := resp.(proto.Message); ok {
+			hashStr, errHash := signature.GetMessageHash(key, msg, log)
+			if errHash == nil {
+				_ = grpc.SetHeader(ctx, metadata.Pairs(signature.MetadataKey, hashStr))
+			}
+		}
+		return resp, nil
+	}
+}
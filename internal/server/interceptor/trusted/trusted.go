@@ -0,0 +1,64 @@
+// Package trusted provides a gRPC interceptor admitting writes only from a trusted subnet.
+package trusted
+
+import (
+	"context"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/mbiwapa/metric/internal/lib/realip"
+)
+
+// New creates an interceptor that rejects calls of the given methods from outside the trusted
+// subnet with PermissionDenied. The address of the client is the one the agent reports in the
+// "x-real-ip" metadata, or the address of the peer if the metadata is missing.
+//
+// Parameters:
+//   - subnet: the trusted subnet.
+//   - log: A zap.Logger instance for logging information and errors.
+//   - methods: the full names of the checked methods, every method if empty.
+//
+// Returns:
+//   - grpc.UnaryServerInterceptor: the interceptor.
+func New(subnet *net.IPNet, log *zap.Logger, methods ...string) grpc.UnaryServerInterceptor {
+	log = log.With(
+		zap.String("op", "interceptor.trusted.Check"),
+	)
+	checked := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		checked[m] = true
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if len(checked) > 0 && !checked[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		var reported, remote string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(realip.MetadataKey); len(v) > 0 {
+				reported = v[0]
+			}
+		}
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			remote = p.Addr.String()
+		}
+
+		ip := realip.Resolve(reported, remote)
+		if ip == nil || !subnet.Contains(ip) {
+			log.Info("Client is not trusted",
+				zap.String("method", info.FullMethod),
+				zap.String("real_ip", reported),
+				zap.String("remote_addr", remote),
+			)
+			return nil, status.Error(codes.PermissionDenied, "client address is outside the trusted subnet")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,50 @@
+// Package admin provides middleware that restricts access to administrative endpoints.
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+)
+
+// New creates a middleware function that allows only requests carrying the admin credential
+// in the "Authorization: Bearer <token>" header. Requests without a valid credential are rejected
+// with a 401 Unauthorized status code. If the token is empty, every request is rejected with
+// 403 Forbidden, so administrative endpoints stay closed unless explicitly configured.
+//
+// Parameters:
+// - token: the admin credential.
+// - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+// - A middleware function that can be used with an HTTP handler to protect administrative endpoints.
+func New(token string, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log = log.With(
+			zap.String("op", "middleware.admin.New"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				log.Error("Admin token is not configured")
+				problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "admin access is not configured")
+				return
+			}
+
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				log.Error("Invalid admin credential")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "missing or invalid admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,68 @@
+// Package auth provides middleware admitting requests carrying an API token with a given scope.
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+)
+
+// New creates a middleware that requires an API token granting the scope in the
+// "Authorization: Bearer <token>" header. A missing or unknown token is rejected with
+// 401 Unauthorized, a token without the scope with 403 Forbidden.
+//
+// Parameters:
+// - store: the issued tokens.
+// - scope: the scope required by the endpoints.
+// - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+// - A middleware function that can be used with an HTTP handler.
+func New(store apitoken.Store, scope apitoken.Scope, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log = log.With(
+			zap.String("op", "middleware.auth.New"),
+			zap.String("scope", string(scope)),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			requestID := middleware.GetReqID(r.Context())
+
+			secret, ok := apitoken.FromAuthorization(r.Header.Get("Authorization"))
+			if !ok {
+				unauthorized(w, r, scope, "missing API token")
+				return
+			}
+			token, err := store.Lookup(r.Context(), apitoken.Hash(secret))
+			if errors.Is(err, apitoken.ErrNotFound) {
+				log.Info("Unknown API token", zap.String("request_id", requestID))
+				unauthorized(w, r, scope, "invalid API token")
+				return
+			}
+			if err != nil {
+				log.Error("Cannot look up API token", zap.Error(err), zap.String("request_id", requestID))
+				problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "cannot check API token")
+				return
+			}
+			if !token.Allows(scope) {
+				log.Info("API token lacks the scope", zap.String("token", token.ID), zap.String("request_id", requestID))
+				problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "API token does not grant the "+string(scope)+" scope")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// unauthorized writes a 401 response asking for a token with the scope.
+func unauthorized(w http.ResponseWriter, r *http.Request, scope apitoken.Scope, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="metric", scope="`+string(scope)+`"`)
+	problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, detail)
+}
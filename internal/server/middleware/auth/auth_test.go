@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+)
+
+// brokenStore fails every lookup.
+type brokenStore struct {
+	apitoken.Store
+}
+
+func (brokenStore) Lookup(context.Context, string) (apitoken.Token, error) {
+	return apitoken.Token{}, errors.New("connection refused")
+}
+
+func TestNew(t *testing.T) {
+	store := apitoken.NewFile(filepath.Join(t.TempDir(), "tokens.json"))
+	issue := func(scopes ...apitoken.Scope) string {
+		secret, token, err := apitoken.Generate("test", scopes)
+		require.NoError(t, err)
+		require.NoError(t, store.Add(context.Background(), token))
+		return secret
+	}
+	reader := issue(apitoken.ScopeRead)
+	writer := issue(apitoken.ScopeWrite)
+	admin := issue(apitoken.ScopeAdmin)
+
+	tests := []struct {
+		name          string
+		store         apitoken.Store
+		authorization string
+		wantStatus    int
+		wantCode      string
+	}{
+		{name: "Token with the scope", authorization: "Bearer " + writer, wantStatus: http.StatusOK},
+		{name: "Admin token", authorization: "bearer " + admin, wantStatus: http.StatusOK},
+		{name: "Token without the scope", authorization: "Bearer " + reader, wantStatus: http.StatusForbidden, wantCode: problem.CodeForbidden},
+		{name: "Unknown token", authorization: "Bearer mt_unknown", wantStatus: http.StatusUnauthorized, wantCode: problem.CodeUnauthorized},
+		{name: "Missing token", wantStatus: http.StatusUnauthorized, wantCode: problem.CodeUnauthorized},
+		{name: "Basic credential", authorization: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized, wantCode: problem.CodeUnauthorized},
+		{name: "Store failure", store: brokenStore{}, authorization: "Bearer " + writer, wantStatus: http.StatusInternalServerError, wantCode: problem.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s apitoken.Store = store
+			if tt.store != nil {
+				s = tt.store
+			}
+			handler := New(s, apitoken.ScopeWrite, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantCode != "" {
+				var p problem.Problem
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+				require.Equal(t, tt.wantCode, p.Code)
+			}
+			if tt.wantStatus == http.StatusUnauthorized {
+				require.Contains(t, rr.Header().Get("WWW-Authenticate"), `scope="write"`)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 )
 
 // Decoder is an interface that defines a method for decrypting data.
@@ -19,20 +21,22 @@ type Decoder interface {
 // The middleware reads the encrypted request body, decrypts it using the provided Decoder,
 // and replaces the request body with the decrypted data before passing the request to the next handler.
 //
-// If reading the request body or decrypting the data fails, it responds with an appropriate HTTP error.
+// If reading the request body fails, it responds with a problem+json error, and if the data
+// cannot be decrypted, with 400 decryption_failed.
 func New(decoder Decoder) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			encryptedData, err := io.ReadAll(r.Body)
 			if err != nil {
-				http.Error(w, "failed to read request body", http.StatusBadRequest)
+				problem.WriteReadError(w, r, err, problem.CodeBadRequest, "failed to read request body")
 				return
 			}
 
 			decryptedData, err := decoder.DecryptData(encryptedData)
 			if err != nil {
-				http.Error(w, "failed to decrypt request body", http.StatusInternalServerError)
+				// The body was encrypted with another key or corrupted on the way: a retry won't help.
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeDecryptionFailed, "failed to decrypt request body")
 				return
 			}
 
@@ -0,0 +1,60 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+)
+
+// prefixDecoder strips a fixed prefix and fails on data without it.
+type prefixDecoder struct{}
+
+func (prefixDecoder) DecryptData(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte("enc:")) {
+		return nil, errors.New("crypto/rsa: decryption error")
+	}
+	return data[len("enc:"):], nil
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantBody   string
+	}{
+		{name: "Decrypted", body: "enc:[]", wantStatus: http.StatusOK, wantBody: "[]"},
+		{name: "Wrong key", body: "garbage", wantStatus: http.StatusBadRequest, wantCode: problem.CodeDecryptionFailed},
+	}
+
+	handler := New(prefixDecoder{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantCode == "" {
+				require.Equal(t, tt.wantBody, rr.Body.String())
+				return
+			}
+			var p problem.Problem
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+			require.Equal(t, tt.wantCode, p.Code)
+			require.NotContains(t, p.Detail, "crypto/rsa")
+		})
+	}
+}
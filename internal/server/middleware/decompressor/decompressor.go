@@ -1,25 +1,53 @@
 // Package decompressor provides middleware for decompressing HTTP request bodies
-// that are compressed using gzip.
+// that are compressed with one of the codings of the contentcoding package.
 package decompressor
 
 import (
-	"compress/gzip"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 
 	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/contentcoding"
 )
 
-// New returns a middleware function that decompresses the request body if the
-// request contains the "Content-Encoding: gzip" header.
+// Option configures optional behaviour of the middleware.
+type Option func(*options)
+
+type options struct {
+	maxSize int64
+}
+
+// WithMaxSize limits the decompressed body to maxSize bytes. The body is decompressed as it
+// is read and cut off at the limit with http.MaxBytesReader, so a small, highly compressible
+// payload cannot exhaust the memory; the handler reading past the limit answers 413.
+func WithMaxSize(maxSize int64) Option {
+	return func(o *options) {
+		o.maxSize = maxSize
+	}
+}
+
+// New returns a middleware function that decompresses the request body according to
+// its Content-Encoding header: gzip, deflate, zstd or br, or several of them in the order
+// they were applied. A coding the server does not know is rejected with 415 Unsupported
+// Media Type and an Accept-Encoding header listing the supported ones, so that the client
+// can fall back to one of them. The snappy framing of remote_write is left to its handler.
 //
 // Parameters:
 // - log: A zap.Logger instance for logging.
+// - opts: optional settings.
 //
 // Returns:
 // - A middleware function that can be used with an HTTP handler.
-func New(log *zap.Logger) func(next http.Handler) http.Handler {
+func New(log *zap.Logger, opts ...Option) func(next http.Handler) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(next http.Handler) http.Handler {
 		log = log.With(
 			zap.String("component", "middleware/decompressor"),
@@ -28,25 +56,50 @@ func New(log *zap.Logger) func(next http.Handler) http.Handler {
 		log.Info("decompressor middleware enabled")
 
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			contentEncoding := r.Header.Get("Content-Encoding")
-			sendsGzip := strings.Contains(contentEncoding, "gzip")
+			header := strings.Join(r.Header.Values("Content-Encoding"), ",")
+			if header == "" || strings.EqualFold(strings.TrimSpace(header), passthrough) {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			if sendsGzip {
-				cr, err := newCompressReader(r.Body)
+			codings, err := parse(header)
+			if err != nil {
+				log.Info("unsupported content encoding", zap.String("encoding", header))
+				w.Header().Set("Accept-Encoding", strings.Join(contentcoding.Supported(), ", "))
+				problem.Write(w, r, http.StatusUnsupportedMediaType, problem.CodeUnsupportedEncoding, err.Error())
+				return
+			}
+
+			body := &decodedBody{body: r.Body}
+			var reader io.Reader = r.Body
+			// The codings are listed in the order they were applied and are undone in reverse.
+			for i := len(codings) - 1; i >= 0; i-- {
+				if codings[i] == contentcoding.Identity {
+					continue
+				}
+				dr, err := contentcoding.NewReader(codings[i], reader)
 				if err != nil {
-					log.Error("failed init decompressor", zap.Error(err))
-					w.WriteHeader(http.StatusInternalServerError)
+					body.Close()
+					log.Error("failed init decompressor", zap.String("encoding", codings[i]), zap.Error(err))
+					problem.WriteReadError(w, r, err, problem.CodeBadRequest, "request body is not valid "+codings[i])
 					return
 				}
-				r.Body = cr
-				defer func(cr *compressReader) {
-
-					err := cr.Close()
-					if err != nil {
-						log.Error("failed closing compress reader", zap.Error(err))
-					}
-				}(cr)
+				body.decoders = append(body.decoders, dr)
+				reader = dr
+			}
+			body.Reader = reader
+
+			r.Body = body
+			if o.maxSize > 0 {
+				r.Body = http.MaxBytesReader(w, body, o.maxSize)
 			}
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1
+			defer func() {
+				if err := body.Close(); err != nil {
+					log.Error("failed closing compress reader", zap.Error(err))
+				}
+			}()
 
 			next.ServeHTTP(w, r)
 		}
@@ -54,52 +107,43 @@ func New(log *zap.Logger) func(next http.Handler) http.Handler {
 	}
 }
 
-// compressReader wraps an io.ReadCloser and a gzip.Reader to provide
-// decompression functionality.
-type compressReader struct {
-	r  io.ReadCloser
-	zr *gzip.Reader
-}
+// passthrough is the coding of remote_write bodies, decoded by their handler.
+const passthrough = "snappy"
 
-// newCompressReader creates a new compressReader instance.
-//
-// Parameters:
-// - r: An io.ReadCloser representing the compressed data.
-//
-// Returns:
-// - A pointer to a compressReader instance.
-// - An error if the gzip.Reader could not be created.
-func newCompressReader(r io.ReadCloser) (*compressReader, error) {
-	zr, err := gzip.NewReader(r)
-	if err != nil {
-		return nil, err
+// parse splits the Content-Encoding header into supported codings.
+func parse(header string) ([]string, error) {
+	var codings []string
+	for _, name := range strings.Split(header, ",") {
+		coding, err := contentcoding.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		codings = append(codings, coding)
 	}
-
-	return &compressReader{
-		r:  r,
-		zr: zr,
-	}, nil
+	return codings, nil
 }
 
-// Read reads decompressed data into p.
-//
-// Parameters:
-// - p: A byte slice to store the decompressed data.
-//
-// Returns:
-// - The number of bytes read.
-// - An error if there was an issue during reading.
-func (c *compressReader) Read(p []byte) (n int, err error) {
-	return c.zr.Read(p)
+// decodedBody reads the decoded request body and closes the decoders and the original body.
+type decodedBody struct {
+	io.Reader
+	body     io.ReadCloser
+	decoders []io.ReadCloser
+	closed   bool
 }
 
-// Close closes both the underlying io.ReadCloser and the gzip.Reader.
+// Close closes the decoders and the original body; it may be called more than once.
 //
 // Returns:
 // - An error if there was an issue during closing.
-func (c *compressReader) Close() error {
-	if err := c.r.Close(); err != nil {
-		return err
+func (b *decodedBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	var errs []error
+	for _, d := range b.decoders {
+		errs = append(errs, d.Close())
 	}
-	return c.zr.Close()
+	errs = append(errs, b.body.Close())
+	return errors.Join(errs...)
 }
@@ -0,0 +1,100 @@
+package decompressor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/contentcoding"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	require.NoError(t, err)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func encoded(t *testing.T, coding string, data []byte) []byte {
+	out, err := contentcoding.Encode(coding, 0, data)
+	require.NoError(t, err)
+	return out
+}
+
+func TestNew(t *testing.T) {
+	const limit = 64 * 1024
+	small := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+	// A bomb: 16 MiB of zeros compress to a few KiB.
+	bomb := gzipped(t, make([]byte, 16<<20))
+	require.Less(t, len(bomb), limit, "the compressed bomb fits any wire limit")
+
+	tests := []struct {
+		name       string
+		body       []byte
+		encoding   string
+		wantStatus int
+		wantBody   []byte
+	}{
+		{name: "Plain body", body: small, wantStatus: http.StatusOK, wantBody: small},
+		{name: "Gzip body", body: gzipped(t, small), encoding: "gzip", wantStatus: http.StatusOK, wantBody: small},
+		{name: "Gzip bomb", body: bomb, encoding: "gzip", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Invalid gzip", body: small, encoding: "gzip", wantStatus: http.StatusBadRequest},
+		{name: "Deflate body", body: encoded(t, contentcoding.Deflate, small), encoding: "deflate", wantStatus: http.StatusOK, wantBody: small},
+		{name: "Zstd body", body: encoded(t, contentcoding.Zstd, small), encoding: "zstd", wantStatus: http.StatusOK, wantBody: small},
+		{name: "Brotli body", body: encoded(t, contentcoding.Brotli, small), encoding: "br", wantStatus: http.StatusOK, wantBody: small},
+		{name: "Zstd bomb", body: encoded(t, contentcoding.Zstd, make([]byte, 16<<20)), encoding: "zstd", wantStatus: http.StatusRequestEntityTooLarge},
+		{
+			name:       "Stacked codings",
+			body:       encoded(t, contentcoding.Brotli, encoded(t, contentcoding.Gzip, small)),
+			encoding:   "gzip, br",
+			wantStatus: http.StatusOK,
+			wantBody:   small,
+		},
+		{name: "Identity", body: small, encoding: "identity", wantStatus: http.StatusOK, wantBody: small},
+		{name: "Snappy is left to the handler", body: small, encoding: "snappy", wantStatus: http.StatusOK, wantBody: small},
+		{name: "Unsupported coding", body: small, encoding: "lz4", wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var read int
+			var got []byte
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				read, got = len(body), body
+				if err != nil {
+					problem.WriteReadError(w, r, err, problem.CodeBadRequest, "cannot read request body")
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := New(zap.NewNop(), WithMaxSize(limit))(next)
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				require.Equal(t, "zstd, br, gzip, deflate", rr.Header().Get("Accept-Encoding"))
+			}
+			require.LessOrEqual(t, read, limit, "no more than the limit is decompressed")
+			if tt.wantBody != nil {
+				require.Equal(t, tt.wantBody, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package idempotency provides middleware answering a repeated Idempotency-Key with the original response.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/idempotency"
+)
+
+// New creates a middleware that makes requests carrying an Idempotency-Key header
+// idempotent. The first request with a key is handled and its response is stored; a
+// request repeating the key with the same body gets the stored response with the
+// Idempotent-Replayed header, without reaching the handler. A key reused for another
+// body is rejected with 422. Requests with the same key are handled one at a time, so a
+// retry sent while the original is still running waits for its result. Responses with
+// a 5xx status are not stored, the request may be retried. Requests without the header
+// are passed through.
+//
+// The middleware must be placed after the ones decompressing and decrypting the body,
+// so that the same metrics sent twice have the same fingerprint.
+//
+// Parameters:
+// - store: the store of the responses.
+// - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+// - A middleware function that can be used with an HTTP handler.
+func New(store idempotency.Store, log *zap.Logger) func(next http.Handler) http.Handler {
+	locker := idempotency.NewLocker()
+
+	return func(next http.Handler) http.Handler {
+		log = log.With(
+			zap.String("op", "middleware.idempotency.New"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotency.Header)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotency.MaxKeyLength {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, "Idempotency-Key is too long")
+				return
+			}
+			log := log.With(
+				zap.String("request_id", middleware.GetReqID(r.Context())),
+				zap.String("idempotency_key", key),
+			)
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				problem.WriteReadError(w, r, err, problem.CodeBadRequest, "failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+			fingerprint := hex.EncodeToString(sum[:])
+
+			unlock := locker.Lock(key)
+			defer unlock()
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			defer cancel()
+
+			stored, ok, err := store.Get(ctx, key)
+			cancel()
+			if err != nil {
+				log.Error("Cannot read stored response", zap.Error(err))
+				problem.WriteError(w, r, err)
+				return
+			}
+			if ok {
+				if stored.Fingerprint != fingerprint {
+					log.Info("Idempotency key reused for another request")
+					problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused,
+						"Idempotency-Key was already used for a request with another body")
+					return
+				}
+				log.Info("Replaying stored response", zap.Int("status", stored.Status))
+				replay(w, stored)
+				return
+			}
+
+			var buf bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				return
+			}
+			// The response is stored even if the client has gone, that is when it will retry.
+			ctx, cancel = context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+			defer cancel()
+			err = store.Put(ctx, key, idempotency.Response{
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: ww.Header().Get("Content-Type"),
+				Hash:        ww.Header().Get("HashSHA256"),
+				Body:        buf.Bytes(),
+			})
+			if err != nil {
+				log.Error("Cannot store response", zap.Error(err))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// replay writes the stored response.
+func replay(w http.ResponseWriter, resp idempotency.Response) {
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	if resp.Hash != "" {
+		w.Header().Set("HashSHA256", resp.Hash)
+	}
+	w.Header().Set(idempotency.ReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/idempotency"
+)
+
+func TestNew(t *testing.T) {
+	calls := 0
+	status := http.StatusOK
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("HashSHA256", "hash")
+		w.WriteHeader(status)
+		w.Write([]byte(`[{"id":"PollCount","type":"counter","delta":1}]`))
+	})
+	handler := New(idempotency.NewMemory(10, 0), zap.NewNop())(next)
+
+	send := func(key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	body := `[{"id":"PollCount","type":"counter","delta":1}]`
+
+	t.Run("Repeated key is replayed", func(t *testing.T) {
+		first := send("k1", body)
+		second := send("k1", body)
+
+		require.Equal(t, 1, calls)
+		require.Equal(t, http.StatusOK, second.Code)
+		require.Equal(t, first.Body.String(), second.Body.String())
+		require.Equal(t, "application/json", second.Header().Get("Content-Type"))
+		require.Equal(t, "hash", second.Header().Get("HashSHA256"))
+		require.Equal(t, "true", second.Header().Get(idempotency.ReplayedHeader))
+		require.Empty(t, first.Header().Get(idempotency.ReplayedHeader))
+	})
+
+	t.Run("Key reused for another body", func(t *testing.T) {
+		calls = 0
+		rr := send("k1", `[]`)
+
+		require.Equal(t, 0, calls)
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		var p problem.Problem
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+		require.Equal(t, problem.CodeIdempotencyKeyReused, p.Code)
+	})
+
+	t.Run("Requests without a key are not deduplicated", func(t *testing.T) {
+		calls = 0
+		send("", body)
+		send("", body)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("Server errors are not stored", func(t *testing.T) {
+		calls = 0
+		status = http.StatusInternalServerError
+		send("k2", body)
+		status = http.StatusOK
+		rr := send("k2", body)
+
+		require.Equal(t, 2, calls)
+		require.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Key is too long", func(t *testing.T) {
+		calls = 0
+		rr := send(string(bytes.Repeat([]byte("k"), idempotency.MaxKeyLength+1)), body)
+		require.Equal(t, 0, calls)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
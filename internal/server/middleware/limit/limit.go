@@ -0,0 +1,45 @@
+// Package limit provides middleware bounding the size of request bodies.
+package limit
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+)
+
+// New returns a middleware that limits the request body, as sent on the wire, to maxBytes.
+// A request declaring a larger Content-Length is rejected with 413 before its body is read;
+// otherwise the body is cut off with http.MaxBytesReader, so that whoever reads past the
+// limit gets an *http.MaxBytesError and answers 413 with problem.WriteReadError.
+//
+// Parameters:
+// - maxBytes: the limit in bytes, no limit if not positive.
+// - log: A zap.Logger instance for logging.
+//
+// Returns:
+// - A middleware function that can be used with an HTTP handler.
+func New(maxBytes int64, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+		log = log.With(
+			zap.String("op", "middleware.limit.New"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				log.Info("Request body is too large", zap.Int64("content_length", r.ContentLength))
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeTooLarge,
+					"request body exceeds "+strconv.FormatInt(maxBytes, 10)+" bytes")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
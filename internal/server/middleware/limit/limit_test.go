@@ -0,0 +1,70 @@
+package limit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+)
+
+// readAll is a handler reading the whole body as the handlers of the router do.
+func readAll(read *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		*read = len(body)
+		if err != nil {
+			problem.WriteReadError(w, r, err, problem.CodeBadRequest, "cannot read request body")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          int
+		contentLength bool
+		wantStatus    int
+		wantRead      int
+	}{
+		{name: "Within the limit", size: 1024, contentLength: true, wantStatus: http.StatusOK, wantRead: 1024},
+		{name: "Declared size over the limit", size: 4096, contentLength: true, wantStatus: http.StatusRequestEntityTooLarge, wantRead: -1},
+		{name: "Chunked body over the limit", size: 4096, wantStatus: http.StatusRequestEntityTooLarge, wantRead: 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := -1 // The handler is not called.
+			handler := New(1024, zap.NewNop())(readAll(&read))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(make([]byte, tt.size)))
+			if !tt.contentLength {
+				req.ContentLength = -1
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			require.Equal(t, tt.wantRead, read)
+		})
+	}
+}
+
+func TestNew_NoLimit(t *testing.T) {
+	var read int
+	handler := New(0, zap.NewNop())(readAll(&read))
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(make([]byte, 1<<20)))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, 1<<20, read)
+}
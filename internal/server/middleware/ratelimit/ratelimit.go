@@ -0,0 +1,118 @@
+// Package ratelimit provides middleware protecting the server from clients sending too many requests.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+	"github.com/mbiwapa/metric/internal/lib/ratelimit"
+	"github.com/mbiwapa/metric/internal/lib/realip"
+)
+
+// KeyFunc returns the identity of the client sending the request.
+type KeyFunc func(r *http.Request) string
+
+// RemoteIP identifies clients by the IP address the request comes from.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Client identifies clients by the hash of their bearer token, then by the address the agent
+// reports in X-Real-IP, and finally by the IP address the request comes from. Agents behind
+// a proxy thus get buckets of their own. A request with a forged token or address only moves
+// to another bucket: it is still refused by the authentication or the trusted subnet.
+func Client(r *http.Request) string {
+	if token, ok := apitoken.FromAuthorization(r.Header.Get("Authorization")); ok {
+		return "token:" + apitoken.Hash(token)
+	}
+	if ip := realip.Resolve(r.Header.Get(realip.Header), r.RemoteAddr); ip != nil {
+		return "ip:" + ip.String()
+	}
+	return "ip:" + RemoteIP(r)
+}
+
+// New creates a middleware that limits the rate of requests of every client.
+// A request exceeding the rate is rejected with 429 Too Many Requests and a Retry-After
+// header telling when the client may send the next one.
+//
+// Parameters:
+// - limiter: the token buckets of the clients.
+// - key: the identity of the client, RemoteIP if nil.
+// - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+// - A middleware function that can be used with an HTTP handler.
+func New(limiter *ratelimit.Limiter, key KeyFunc, log *zap.Logger) func(next http.Handler) http.Handler {
+	if key == nil {
+		key = RemoteIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		log = log.With(
+			zap.String("op", "middleware.ratelimit.New"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			client := key(r)
+			ok, wait := limiter.Allow(client)
+			if !ok {
+				log.Info("Rate limit exceeded",
+					zap.String("client", client),
+					zap.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				w.Header().Set("Retry-After", retryAfter(wait))
+				problem.Write(w, r, http.StatusTooManyRequests, problem.CodeRateLimited, "too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// NewConcurrency creates a middleware that bounds the number of requests handled at once.
+// When all the slots are taken, a request is rejected with 503 Service Unavailable and
+// "Retry-After: 1" instead of queueing.
+//
+// Parameters:
+// - sem: the slots of the requests.
+// - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+// - A middleware function that can be used with an HTTP handler.
+func NewConcurrency(sem *ratelimit.Semaphore, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log = log.With(
+			zap.String("op", "middleware.ratelimit.NewConcurrency"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !sem.TryAcquire() {
+				log.Warn("Server is overloaded", zap.String("request_id", middleware.GetReqID(r.Context())))
+				w.Header().Set("Retry-After", "1")
+				problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeOverloaded, "server is overloaded")
+				return
+			}
+			defer sem.Release()
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// retryAfter formats the wait as whole seconds, at least one.
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds()))))
+}
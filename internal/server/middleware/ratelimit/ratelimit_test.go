@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+	"github.com/mbiwapa/metric/internal/lib/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := New(ratelimit.New(0.5, 1), nil, zap.NewNop())(ok)
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, send("10.0.0.1:1000").Code)
+
+	rr := send("10.0.0.1:1001")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "2", rr.Header().Get("Retry-After"))
+	var p problem.Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	require.Equal(t, problem.CodeRateLimited, p.Code)
+
+	require.Equal(t, http.StatusOK, send("10.0.0.2:1000").Code, "another client is not limited")
+}
+
+func TestClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		realIP        string
+		remoteAddr    string
+		want          string
+	}{
+		{name: "Token", authorization: "Bearer mt_secret", realIP: "10.0.0.7", remoteAddr: "192.168.1.1:5000", want: "token:" + apitoken.Hash("mt_secret")},
+		{name: "Reported address", authorization: "Basic dXNlcg==", realIP: "10.0.0.7", remoteAddr: "192.168.1.1:5000", want: "ip:10.0.0.7"},
+		{name: "Invalid reported address", realIP: "agent", remoteAddr: "192.168.1.1:5000", want: "ip:192.168.1.1"},
+		{name: "Peer", remoteAddr: "192.168.1.1:5000", want: "ip:192.168.1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			require.Equal(t, tt.want, Client(req))
+		})
+	}
+}
+
+func TestNewConcurrency(t *testing.T) {
+	sem := ratelimit.NewSemaphore(1)
+	var inner *httptest.ResponseRecorder
+	handler := NewConcurrency(sem, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inner == nil {
+			// A request arriving while this one is handled finds no free slot.
+			inner = httptest.NewRecorder()
+			NewConcurrency(sem, zap.NewNop())(http.NotFoundHandler()).ServeHTTP(inner, r)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, http.StatusServiceUnavailable, inner.Code)
+	require.Equal(t, "1", inner.Header().Get("Retry-After"))
+	require.True(t, sem.TryAcquire(), "the slot is released after the request")
+}
+
+func TestRemoteIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[::1]:5000"
+	require.Equal(t, "::1", RemoteIP(req))
+}
@@ -8,6 +8,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
 	"github.com/mbiwapa/metric/internal/lib/signature"
 )
 
@@ -36,7 +37,7 @@ func New(key string, log *zap.Logger) func(next http.Handler) http.Handler {
 				body, err := io.ReadAll(r.Body)
 				if err != nil {
 					log.Error("Cannot read body", zap.Error(err))
-					w.WriteHeader(http.StatusInternalServerError)
+					problem.WriteReadError(w, r, err, problem.CodeBadRequest, "cannot read request body")
 					return
 				}
 				// Restore the request body for further processing
@@ -45,7 +46,7 @@ func New(key string, log *zap.Logger) func(next http.Handler) http.Handler {
 				hashStr := signature.GetHash(key, string(body), log)
 				if hashStr != sha256Hash {
 					log.Error("Signature mismatch", zap.String("hashRequest", sha256Hash))
-					w.WriteHeader(http.StatusBadRequest)
+					problem.Write(w, r, http.StatusBadRequest, problem.CodeSignatureMismatch, "HashSHA256 does not match the request body")
 					return
 				}
 
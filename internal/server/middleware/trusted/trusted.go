@@ -0,0 +1,46 @@
+// Package trusted provides middleware admitting requests only from a trusted subnet.
+package trusted
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+	"github.com/mbiwapa/metric/internal/lib/realip"
+)
+
+// New creates a middleware that rejects requests from outside the trusted subnet with 403 Forbidden.
+// The address of the client is the one the agent reports in the X-Real-IP header, or the
+// address of the peer if the header is missing.
+//
+// Parameters:
+// - subnet: the trusted subnet.
+// - log: A zap.Logger instance for logging information and errors.
+//
+// Returns:
+// - A middleware function that can be used with an HTTP handler.
+func New(subnet *net.IPNet, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log = log.With(
+			zap.String("op", "middleware.trusted.New"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ip := realip.Resolve(r.Header.Get(realip.Header), r.RemoteAddr)
+			if ip == nil || !subnet.Contains(ip) {
+				log.Info("Client is not trusted",
+					zap.String("real_ip", r.Header.Get(realip.Header)),
+					zap.String("remote_addr", r.RemoteAddr),
+					zap.String("request_id", middleware.GetReqID(r.Context())),
+				)
+				problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "client address is outside the trusted subnet")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
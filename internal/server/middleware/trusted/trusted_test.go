@@ -0,0 +1,54 @@
+package trusted
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/mbiwapa/metric/internal/lib/api/problem"
+)
+
+func TestNew(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		realIP     string
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "Reported address inside", realIP: "10.0.0.7", remoteAddr: "192.168.1.1:5000", wantStatus: http.StatusOK},
+		{name: "Reported address outside", realIP: "10.0.1.7", remoteAddr: "10.0.0.1:5000", wantStatus: http.StatusForbidden},
+		{name: "Invalid reported address", realIP: "agent", remoteAddr: "10.0.0.1:5000", wantStatus: http.StatusForbidden},
+		{name: "Peer inside", remoteAddr: "10.0.0.1:5000", wantStatus: http.StatusOK},
+		{name: "Peer outside", remoteAddr: "192.168.1.1:5000", wantStatus: http.StatusForbidden},
+	}
+
+	handler := New(subnet, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				var p problem.Problem
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+				require.Equal(t, problem.CodeForbidden, p.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Package rpc implements the gRPC Metrics service. It mirrors the JSON batch update
+// and value endpoints of the HTTP API on top of the same storage.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mbiwapa/metric/internal/lib/api/format"
+	pb "github.com/mbiwapa/metric/internal/lib/api/metricpb"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+// Storage interface for storage
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Storage
+type Storage interface {
+	// UpdateBatch updates a batch of gauge and counter metrics in the storage.
+	// It takes a context for cancellation, and slices of gauge and counter metrics.
+	UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error
+
+	// GetMetric retrieves a metric value by its type and name.
+	GetMetric(ctx context.Context, typ string, key string) (string, error)
+
+	// GetAllMetrics retrieves all gauge and counter metrics from the storage.
+	GetAllMetrics(ctx context.Context) ([][]string, [][]string, error)
+}
+
+// Backuper interface for backuper
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=Backuper
+type Backuper interface {
+	// IsSyncMode checks if the backup is in synchronous mode.
+	IsSyncMode() bool
+
+	// Refresh copies the current storage state into the backup structure.
+	Refresh(ctx context.Context) error
+
+	// SaveToFile saves the backup structure to a file.
+	SaveToFile()
+}
+
+// Service implements pb.MetricsServer.
+type Service struct {
+	pb.UnimplementedMetricsServer
+
+	log     *zap.Logger
+	storage Storage
+	backup  Backuper
+}
+
+// New creates the gRPC Metrics service.
+//
+// Parameters:
+//   - log: A zap.Logger instance for logging.
+//   - storage: An implementation of the Storage interface.
+//   - backup: An implementation of the Backuper interface, used in synchronous mode.
+//
+// Returns:
+//   - *Service: the service, registered with pb.RegisterMetricsServer.
+func New(log *zap.Logger, storage Storage, backup Backuper) *Service {
+	return &Service{
+		log:     log.With(zap.String("component", "server/rpc")),
+		storage: storage,
+		backup:  backup,
+	}
+}
+
+// UpdateBatch stores all metrics of the request in a single batch.
+// A metric of unknown type fails the whole request with InvalidArgument.
+func (s *Service) UpdateBatch(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+	const op = "server.rpc.UpdateBatch"
+	log := s.log.With(zap.String("op", op))
+
+	var gauges, counters [][]string
+	for _, m := range req.GetMetrics() {
+		if m.GetId() == "" {
+			return nil, status.Error(codes.InvalidArgument, "metric id is required")
+		}
+		switch m.GetType() {
+		case pb.MType_MTYPE_GAUGE:
+			gauges = append(gauges, []string{m.GetId(), strconv.FormatFloat(m.GetValue(), 'f', -1, 64)})
+		case pb.MType_MTYPE_COUNTER:
+			counters = append(counters, []string{m.GetId(), strconv.FormatInt(m.GetDelta(), 10)})
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "metric %s: type must be gauge or counter", m.GetId())
+		}
+	}
+
+	databaseCtx, cancel := context.WithTimeout(ctx, 11*time.Second)
+	defer cancel()
+	if err := s.storage.UpdateBatch(databaseCtx, gauges, counters); err != nil {
+		log.Error("Failed to batch update", zap.Error(err))
+		return nil, statusFromError(err)
+	}
+
+	if s.backup.IsSyncMode() {
+		if err := s.backup.Refresh(databaseCtx); err != nil {
+			log.Error("Cannot backup metrics", zap.Error(err))
+		} else {
+			s.backup.SaveToFile()
+		}
+	}
+
+	return &pb.UpdateBatchResponse{Accepted: int32(len(gauges) + len(counters))}, nil
+}
+
+// GetMetric returns the current value of a single metric.
+func (s *Service) GetMetric(ctx context.Context, req *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
+	const op = "server.rpc.GetMetric"
+	log := s.log.With(zap.String("op", op))
+
+	typ, ok := typeName(req.GetType())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "metric type must be gauge or counter")
+	}
+
+	databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	value, err := s.storage.GetMetric(databaseCtx, typ, req.GetId())
+	if err != nil {
+		log.Error("Failed to get metric", zap.String("name", req.GetId()), zap.Error(err))
+		return nil, statusFromError(err)
+	}
+
+	m, err := toMetric(req.GetId(), req.GetType(), value)
+	if err != nil {
+		log.Error("Stored value cannot be parsed", zap.String("name", req.GetId()), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.GetMetricResponse{Metric: m}, nil
+}
+
+// List returns all stored gauges followed by all counters.
+func (s *Service) List(ctx context.Context, _ *pb.ListRequest) (*pb.ListResponse, error) {
+	const op = "server.rpc.List"
+	log := s.log.With(zap.String("op", op))
+
+	databaseCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	gauges, counters, err := s.storage.GetAllMetrics(databaseCtx)
+	if err != nil {
+		log.Error("Failed to get metrics", zap.Error(err))
+		return nil, statusFromError(err)
+	}
+
+	resp := &pb.ListResponse{Metrics: make([]*pb.Metric, 0, len(gauges)+len(counters))}
+	for _, group := range []struct {
+		typ    pb.MType
+		values [][]string
+	}{{pb.MType_MTYPE_GAUGE, gauges}, {pb.MType_MTYPE_COUNTER, counters}} {
+		for _, v := range group.values {
+			m, err := toMetric(v[0], group.typ, v[1])
+			if err != nil {
+				log.Error("Stored value cannot be parsed", zap.String("name", v[0]), zap.Error(err))
+				continue
+			}
+			resp.Metrics = append(resp.Metrics, m)
+		}
+	}
+	return resp, nil
+}
+
+// typeName converts the protobuf metric type into the storage type name.
+func typeName(t pb.MType) (string, bool) {
+	switch t {
+	case pb.MType_MTYPE_GAUGE:
+		return format.Gauge, true
+	case pb.MType_MTYPE_COUNTER:
+		return format.Counter, true
+	default:
+		return "", false
+	}
+}
+
+// toMetric builds a protobuf metric from a stored value.
+func toMetric(id string, t pb.MType, value string) (*pb.Metric, error) {
+	m := &pb.Metric{Id: id, Type: t}
+	var err error
+	if t == pb.MType_MTYPE_COUNTER {
+		m.Delta, err = strconv.ParseInt(value, 10, 64)
+	} else {
+		m.Value, err = strconv.ParseFloat(value, 64)
+	}
+	return m, err
+}
+
+// statusFromError maps storage errors to gRPC status codes the same way
+// problem.FromError maps them to HTTP statuses.
+func statusFromError(err error) error {
+	switch {
+	case errors.Is(err, storageErrors.ErrMetricNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storageErrors.ErrInvalidValue):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storageErrors.ErrStorageUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,188 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/mbiwapa/metric/internal/lib/api/metricpb"
+	"github.com/mbiwapa/metric/internal/lib/grpccodec"
+	"github.com/mbiwapa/metric/internal/lib/signature"
+	icLogger "github.com/mbiwapa/metric/internal/server/interceptor/logger"
+	icSignature "github.com/mbiwapa/metric/internal/server/interceptor/signature"
+	"github.com/mbiwapa/metric/internal/server/rpc/mocks"
+	storageErrors "github.com/mbiwapa/metric/internal/storage"
+)
+
+// xorCipher is a reversible stand-in for the RSA encoder and decoder.
+type xorCipher struct{}
+
+func (xorCipher) EncryptData(data []byte) ([]byte, error) { return xor(data), nil }
+func (xorCipher) DecryptData(data []byte) ([]byte, error) { return xor(data), nil }
+
+func xor(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ 0x5a
+	}
+	return out
+}
+
+// startServer serves the service over an in-memory connection the way cmd/server does
+// and returns a connected client.
+func startServer(t *testing.T, storage Storage, backup Backuper, key string, clientOpts ...grpc.DialOption) pb.MetricsClient {
+	t.Helper()
+	log := zap.NewNop()
+
+	ln := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(grpccodec.NewDecrypting(xorCipher{})),
+		grpc.ChainUnaryInterceptor(icLogger.New(log), icSignature.New(key, log)),
+	)
+	pb.RegisterMetricsServer(srv, New(log, storage, backup))
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	opts := append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpccodec.NewEncrypting(xorCipher{}))),
+	}, clientOpts...)
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsClient(conn)
+}
+
+func TestService_UpdateBatch(t *testing.T) {
+	storage := mocks.NewStorage(t)
+	backup := mocks.NewBackuper(t)
+	storage.On("UpdateBatch", mock.Anything,
+		[][]string{{"Alloc", "1.5"}},
+		[][]string{{"PollCount", "3"}}).Return(nil).Once()
+	backup.On("IsSyncMode").Return(true).Once()
+	backup.On("Refresh", mock.Anything).Return(nil).Once()
+	backup.On("SaveToFile").Return().Once()
+
+	client := startServer(t, storage, backup, "")
+	resp, err := client.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{Metrics: []*pb.Metric{
+		{Id: "Alloc", Type: pb.MType_MTYPE_GAUGE, Value: 1.5},
+		{Id: "PollCount", Type: pb.MType_MTYPE_COUNTER, Delta: 3},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), resp.GetAccepted())
+}
+
+func TestService_UpdateBatchErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics []*pb.Metric
+		stored  error
+		code    codes.Code
+	}{
+		{
+			name:    "unknown type",
+			metrics: []*pb.Metric{{Id: "Alloc"}},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "missing id",
+			metrics: []*pb.Metric{{Type: pb.MType_MTYPE_GAUGE, Value: 1}},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "storage unavailable",
+			metrics: []*pb.Metric{{Id: "Alloc", Type: pb.MType_MTYPE_GAUGE, Value: 1}},
+			stored:  storageErrors.ErrStorageUnavailable,
+			code:    codes.Unavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewStorage(t)
+			if tt.stored != nil {
+				storage.On("UpdateBatch", mock.Anything, mock.Anything, mock.Anything).Return(tt.stored).Once()
+			}
+			client := startServer(t, storage, mocks.NewBackuper(t), "")
+
+			_, err := client.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{Metrics: tt.metrics})
+			require.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestService_GetMetric(t *testing.T) {
+	storage := mocks.NewStorage(t)
+	storage.On("GetMetric", mock.Anything, "counter", "PollCount").Return("7", nil).Once()
+	storage.On("GetMetric", mock.Anything, "gauge", "missing").Return("", storageErrors.ErrMetricNotFound).Once()
+	client := startServer(t, storage, mocks.NewBackuper(t), "")
+
+	resp, err := client.GetMetric(context.Background(), &pb.GetMetricRequest{Id: "PollCount", Type: pb.MType_MTYPE_COUNTER})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), resp.GetMetric().GetDelta())
+
+	_, err = client.GetMetric(context.Background(), &pb.GetMetricRequest{Id: "missing", Type: pb.MType_MTYPE_GAUGE})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetMetric(context.Background(), &pb.GetMetricRequest{Id: "PollCount"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestService_List(t *testing.T) {
+	storage := mocks.NewStorage(t)
+	storage.On("GetAllMetrics", mock.Anything).
+		Return([][]string{{"Alloc", "2.5"}}, [][]string{{"PollCount", "4"}}, nil).Once()
+	client := startServer(t, storage, mocks.NewBackuper(t), "")
+
+	resp, err := client.List(context.Background(), &pb.ListRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 2)
+	require.Equal(t, pb.MType_MTYPE_GAUGE, resp.GetMetrics()[0].GetType())
+	require.Equal(t, 2.5, resp.GetMetrics()[0].GetValue())
+	require.Equal(t, pb.MType_MTYPE_COUNTER, resp.GetMetrics()[1].GetType())
+	require.Equal(t, int64(4), resp.GetMetrics()[1].GetDelta())
+}
+
+func TestService_Signature(t *testing.T) {
+	const key = "secret"
+	storage := mocks.NewStorage(t)
+	storage.On("GetMetric", mock.Anything, "gauge", "Alloc").Return("1", nil).Once()
+	client := startServer(t, storage, mocks.NewBackuper(t), key)
+
+	req := &pb.GetMetricRequest{Id: "Alloc", Type: pb.MType_MTYPE_GAUGE}
+	hash, err := signature.GetMessageHash(key, req, zap.NewNop())
+	require.NoError(t, err)
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), signature.MetadataKey, hash)
+	resp, err := client.GetMetric(ctx, req, grpc.Header(&header))
+	require.NoError(t, err)
+	respHash, err := signature.GetMessageHash(key, resp, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, []string{respHash}, header.Get(signature.MetadataKey))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), signature.MetadataKey, "bad")
+	_, err = client.GetMetric(ctx, req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestService_UnencryptedRequest(t *testing.T) {
+	// A client without the encrypting codec sends plain protobuf the server cannot decode.
+	client := startServer(t, mocks.NewStorage(t), mocks.NewBackuper(t), "",
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpccodec.NewEncrypting(nil))))
+
+	_, err := client.GetMetric(context.Background(), &pb.GetMetricRequest{Id: "Alloc", Type: pb.MType_MTYPE_GAUGE})
+	require.Error(t, err)
+}
@@ -4,6 +4,7 @@ package memstorage
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 
 	"github.com/mbiwapa/metric/internal/lib/api/format"
@@ -15,6 +16,18 @@ import (
 type Storage struct {
 	Gauge   []Gauge   // Slice of Gauge metrics
 	Counter []Counter // Slice of Counter metrics
+
+	notifier storage.Notifier // notifier receives the changes after every write, may be nil
+}
+
+// Option configures optional behaviour of the Storage.
+type Option func(*Storage)
+
+// WithNotifier sets the receiver of the changes made by writes.
+func WithNotifier(n storage.Notifier) Option {
+	return func(s *Storage) {
+		s.notifier = n
+	}
 }
 
 // Gauge is a structure for storing a specific type of metric.
@@ -33,11 +46,16 @@ type Counter struct {
 
 // New creates and returns a new instance of Storage.
 // This function initializes a new Storage struct, which is used to store metrics in memory.
+// Parameters:
+// - opts: optional settings.
 // Returns:
 // - *Storage: a pointer to the newly created Storage instance.
 // - error: always returns nil as there are no error conditions in this function.
-func New() (*Storage, error) {
+func New(opts ...Option) (*Storage, error) {
 	var storage Storage
+	for _, opt := range opts {
+		opt(&storage)
+	}
 	return &storage, nil
 }
 
@@ -65,6 +83,7 @@ func (s *Storage) UpdateGauge(_ context.Context, key string, value float64) erro
 		s.Gauge = append(s.Gauge, metric)
 	}
 
+	s.notify([]storage.Change{{Type: format.Gauge, Name: key, Value: strconv.FormatFloat(value, 'f', -1, 64)}})
 	return nil
 }
 
@@ -77,9 +96,11 @@ func (s *Storage) UpdateGauge(_ context.Context, key string, value float64) erro
 // - error: if any error occurs during the update.
 func (s *Storage) UpdateCounter(_ context.Context, key string, value int64) error {
 	changed := false
+	total := value
 	for i := 0; i < len(s.Counter); i++ {
 		if s.Counter[i].Name == key {
 			s.Counter[i].Value = int64(value) + s.Counter[i].Value
+			total = s.Counter[i].Value
 			changed = true
 		}
 	}
@@ -90,6 +111,8 @@ func (s *Storage) UpdateCounter(_ context.Context, key string, value int64) erro
 		metric.Value = int64(value)
 		s.Counter = append(s.Counter, metric)
 	}
+
+	s.notify([]storage.Change{{Type: format.Counter, Name: key, Value: strconv.FormatInt(total, 10)}})
 	return nil
 }
 
@@ -142,7 +165,34 @@ func (s *Storage) GetMetric(_ context.Context, typ string, key string) (string,
 	return "", storage.ErrMetricNotFound
 }
 
+// GetMetrics returns the metrics with the given names that exist in the memory.
+// Parameters:
+// - ctx: context for managing request-scoped values, cancelation, and deadlines.
+// - gauges: names of the gauge metrics.
+// - counters: names of the counter metrics.
+// Returns:
+// - [][]string: the found gauge metrics as [name, value], in the order of the names.
+// - [][]string: the found counter metrics as [name, value], in the order of the names.
+// - error: always nil.
+func (s *Storage) GetMetrics(ctx context.Context, gauges []string, counters []string) ([][]string, [][]string, error) {
+	foundGauges := make([][]string, 0, len(gauges))
+	for _, name := range gauges {
+		if value, err := s.GetMetric(ctx, format.Gauge, name); err == nil {
+			foundGauges = append(foundGauges, []string{name, value})
+		}
+	}
+	foundCounters := make([][]string, 0, len(counters))
+	for _, name := range counters {
+		if value, err := s.GetMetric(ctx, format.Counter, name); err == nil {
+			foundCounters = append(foundCounters, []string{name, value})
+		}
+	}
+	return foundGauges, foundCounters, nil
+}
+
 // UpdateBatch saves the given Gauge and Counter metrics to the memory.
+// All values are parsed before anything is written, so an invalid value leaves the
+// storage unchanged, as a rolled back transaction does in the database storage.
 // Parameters:
 // - ctx: context for managing request-scoped values, cancelation, and deadlines.
 // - gauges: slice of gauge metrics, where each metric is represented as a slice of strings [name, value].
@@ -150,15 +200,35 @@ func (s *Storage) GetMetric(_ context.Context, typ string, key string) (string,
 // Returns:
 // - error: if any error occurs during the update.
 func (s *Storage) UpdateBatch(_ context.Context, gauges [][]string, counters [][]string) error {
-	for _, gauge := range gauges {
+	gaugeValues := make([]float64, len(gauges))
+	for i, gauge := range gauges {
+		val, err := strconv.ParseFloat(gauge[1], 64)
+		if err != nil {
+			return fmt.Errorf("%w: %w", storage.ErrInvalidValue, err)
+		}
+		gaugeValues[i] = val
+	}
+	counterValues := make([]int64, len(counters))
+	for i, counter := range counters {
+		val, err := strconv.ParseInt(counter[1], 0, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %w", storage.ErrInvalidValue, err)
+		}
+		counterValues[i] = val
+	}
+
+	var changes []storage.Change
+	if s.notifier != nil {
+		changes = make([]storage.Change, 0, len(gauges)+len(counters))
+		defer func() { s.notify(changes) }()
+	}
+
+	for i, gauge := range gauges {
+		val := gaugeValues[i]
 		changed := false
-		for i := 0; i < len(s.Gauge); i++ {
-			if s.Gauge[i].Name == gauge[0] {
-				val, err := strconv.ParseFloat(gauge[1], 64)
-				if err != nil {
-					return err
-				}
-				s.Gauge[i].Value = val
+		for j := 0; j < len(s.Gauge); j++ {
+			if s.Gauge[j].Name == gauge[0] {
+				s.Gauge[j].Value = val
 				changed = true
 			}
 		}
@@ -166,26 +236,21 @@ func (s *Storage) UpdateBatch(_ context.Context, gauges [][]string, counters [][
 		if !changed {
 			var metric Gauge
 			metric.Name = gauge[0]
-			val, err := strconv.ParseFloat(gauge[1], 64)
-			if err != nil {
-				return err
-			}
 			metric.Value = val
 			s.Gauge = append(s.Gauge, metric)
 		}
-
+		if s.notifier != nil {
+			changes = append(changes, storage.Change{Type: format.Gauge, Name: gauge[0], Value: strconv.FormatFloat(val, 'f', -1, 64)})
+		}
 	}
 
-	for _, counter := range counters {
-
+	for i, counter := range counters {
+		total := counterValues[i]
 		changed := false
-		for i := 0; i < len(s.Counter); i++ {
-			if s.Counter[i].Name == counter[0] {
-				val, err := strconv.ParseInt(counter[1], 0, 64)
-				if err != nil {
-					return err
-				}
-				s.Counter[i].Value = val + s.Counter[i].Value
+		for j := 0; j < len(s.Counter); j++ {
+			if s.Counter[j].Name == counter[0] {
+				s.Counter[j].Value = counterValues[i] + s.Counter[j].Value
+				total = s.Counter[j].Value
 				changed = true
 			}
 		}
@@ -193,14 +258,20 @@ func (s *Storage) UpdateBatch(_ context.Context, gauges [][]string, counters [][
 		if !changed {
 			var metric Counter
 			metric.Name = counter[0]
-			val, err := strconv.ParseInt(counter[1], 0, 64)
-			if err != nil {
-				return err
-			}
-			metric.Value = int64(val)
+			metric.Value = total
 			s.Counter = append(s.Counter, metric)
 		}
+		if s.notifier != nil {
+			changes = append(changes, storage.Change{Type: format.Counter, Name: counter[0], Value: strconv.FormatInt(total, 10)})
+		}
 	}
 
 	return nil
 }
+
+// notify passes the changes to the notifier, if there is one.
+func (s *Storage) notify(changes []storage.Change) {
+	if s.notifier != nil && len(changes) > 0 {
+		s.notifier.Notify(changes)
+	}
+}
@@ -0,0 +1,132 @@
+package postgre
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mbiwapa/metric/internal/lib/idempotency"
+)
+
+// IdempotencyStore keeps the responses to idempotent requests in the idempotency_key table,
+// so that they survive restarts and are shared by servers using the same database.
+// It implements idempotency.Store.
+type IdempotencyStore struct {
+	db       *sql.DB
+	capacity int
+	ttl      time.Duration
+}
+
+// NewIdempotencyStore creates the idempotency_key table if it does not exist and returns a store using it.
+//
+// Parameters:
+// - ctx: The context for creating the table.
+// - s: The storage whose database connection is used.
+// - capacity: The number of keys remembered, idempotency.DefaultCapacity if not positive.
+// - ttl: How long a key is remembered, idempotency.DefaultTTL if not positive.
+//
+// Returns:
+// - A pointer to the IdempotencyStore instance.
+// - An error if the table cannot be created.
+func NewIdempotencyStore(ctx context.Context, s *Storage, capacity int, ttl time.Duration) (*IdempotencyStore, error) {
+	const op = "storage.postgre.NewIdempotencyStore"
+
+	if capacity <= 0 {
+		capacity = idempotency.DefaultCapacity
+	}
+	if ttl <= 0 {
+		ttl = idempotency.DefaultTTL
+	}
+
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS idempotency_key (
+        key TEXT PRIMARY KEY,
+        fingerprint TEXT NOT NULL,
+        status INTEGER NOT NULL,
+        content_type TEXT NOT NULL DEFAULT '',
+        hash TEXT NOT NULL DEFAULT '',
+        body BYTEA NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now());`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	_, err = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idempotency_key_created_at ON idempotency_key (created_at)`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return &IdempotencyStore{db: s.db, capacity: capacity, ttl: ttl}, nil
+}
+
+// Get returns the response stored for the key, unless it has expired.
+//
+// Parameters:
+// - ctx: The context for the query.
+// - key: The Idempotency-Key of the request.
+//
+// Returns:
+// - The stored response.
+// - Whether a response is stored.
+// - An error if the query fails.
+func (s *IdempotencyStore) Get(ctx context.Context, key string) (idempotency.Response, bool, error) {
+	const op = "storage.postgre.IdempotencyStore.Get"
+
+	var resp idempotency.Response
+	err := s.db.QueryRowContext(ctx,
+		`SELECT fingerprint, status, content_type, hash, body FROM idempotency_key
+        WHERE key = $1 AND created_at > now() - make_interval(secs => $2)`,
+		key, s.ttl.Seconds(),
+	).Scan(&resp.Fingerprint, &resp.Status, &resp.ContentType, &resp.Hash, &resp.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return idempotency.Response{}, false, nil
+	}
+	if err != nil {
+		return idempotency.Response{}, false, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return resp, true, nil
+}
+
+// Put stores the response for the key and, in the same transaction, deletes the expired
+// keys and the oldest ones beyond the capacity.
+//
+// Parameters:
+// - ctx: The context for the query.
+// - key: The Idempotency-Key of the request.
+// - resp: The response to the request.
+//
+// Returns:
+// - An error if the query fails.
+func (s *IdempotencyStore) Put(ctx context.Context, key string, resp idempotency.Response) error {
+	const op = "storage.postgre.IdempotencyStore.Put"
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO idempotency_key (key, fingerprint, status, content_type, hash, body)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = EXCLUDED.status,
+        content_type = EXCLUDED.content_type, hash = EXCLUDED.hash, body = EXCLUDED.body, created_at = now()`,
+		key, resp.Fingerprint, resp.Status, resp.ContentType, resp.Hash, resp.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM idempotency_key WHERE created_at <= now() - make_interval(secs => $1)
+        OR key IN (SELECT key FROM idempotency_key ORDER BY created_at DESC OFFSET $2)`,
+		s.ttl.Seconds(), s.capacity,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return nil
+}
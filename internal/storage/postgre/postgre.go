@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/Rican7/retry"
 	"github.com/Rican7/retry/strategy"
@@ -24,7 +25,18 @@ import (
 // The Storage struct encapsulates a connection to a PostgreSQL database.
 // It provides methods to interact with the database, such as creating, updating, and retrieving metrics.
 type Storage struct {
-	db *sql.DB // db is a pointer to the sql.DB instance representing the database connection.
+	db       *sql.DB          // db is a pointer to the sql.DB instance representing the database connection.
+	notifier storage.Notifier // notifier receives the changes after every committed write, may be nil.
+}
+
+// Option configures optional behaviour of the Storage.
+type Option func(*Storage)
+
+// WithNotifier sets the receiver of the changes made by writes.
+func WithNotifier(n storage.Notifier) Option {
+	return func(s *Storage) {
+		s.notifier = n
+	}
 }
 
 // New returns a new Storage instance.
@@ -34,14 +46,18 @@ type Storage struct {
 //
 // Parameters:
 // - dsn: The Data Source Name for connecting to the PostgreSQL database.
+// - opts: optional settings.
 //
 // Returns:
 // - A pointer to the Storage instance.
 // - An error if the connection or table creation fails.
-func New(dsn string) (*Storage, error) {
+func New(dsn string, opts ...Option) (*Storage, error) {
 	const op = "storage.postgre.New"
 
 	var storage Storage
+	for _, opt := range opts {
+		opt(&storage)
+	}
 
 	action := func(attempt uint) error {
 
@@ -103,7 +119,7 @@ func (s *Storage) Ping(ctx context.Context) error {
 	)
 
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, classify(err))
 	}
 	return nil
 }
@@ -134,7 +150,7 @@ func (s *Storage) UpdateGauge(ctx context.Context, key string, value float64) er
 		if err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) {
-				return fmt.Errorf("%s: %s", op, pgErr.Message)
+				return fmt.Errorf("%s: %w", op, classify(pgErr))
 			}
 			return fmt.Errorf("%s: %w", op, err)
 		}
@@ -147,8 +163,9 @@ func (s *Storage) UpdateGauge(ctx context.Context, key string, value float64) er
 	)
 
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, classify(err))
 	}
+	s.notify([]storage.Change{{Type: format.Gauge, Name: key, Value: strconv.FormatFloat(value, 'f', -1, 64)}})
 	return nil
 }
 
@@ -189,7 +206,7 @@ func (s *Storage) UpdateCounter(ctx context.Context, key string, value int64) er
 		if err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) {
-				return fmt.Errorf("%s: %s", op, pgErr.Message)
+				return fmt.Errorf("%s: %w", op, classify(pgErr))
 			}
 			return fmt.Errorf("%s: %w", op, err)
 		}
@@ -202,8 +219,9 @@ func (s *Storage) UpdateCounter(ctx context.Context, key string, value int64) er
 	)
 
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, classify(err))
 	}
+	s.notify([]storage.Change{{Type: format.Counter, Name: key, Value: strconv.FormatInt(value, 10)}})
 	return nil
 }
 
@@ -266,7 +284,7 @@ func (s *Storage) GetAllMetrics(ctx context.Context) ([][]string, [][]string, er
 		strategy.Backoff(backoff.Backoff()),
 	)
 	if err != nil {
-		return nil, nil, fmt.Errorf("%s: %w", op, err)
+		return nil, nil, fmt.Errorf("%s: %w", op, classify(err))
 	}
 	if notFound {
 		return nil, nil, storage.ErrMetricNotFound
@@ -322,7 +340,7 @@ func (s *Storage) GetMetric(ctx context.Context, typ string, key string) (string
 		strategy.Backoff(backoff.Backoff()),
 	)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, classify(err))
 	}
 	if notFound {
 		return "", storage.ErrMetricNotFound
@@ -330,6 +348,78 @@ func (s *Storage) GetMetric(ctx context.Context, typ string, key string) (string
 	return result, nil
 }
 
+// GetMetrics returns the metrics with the given names from the database in a single query.
+// It retries the retrieval operation up to 4 times with a backoff strategy in case of failure.
+//
+// Parameters:
+// - ctx: The context for the retrieval operation.
+// - gauges: The names of the gauge metrics.
+// - counters: The names of the counter metrics.
+//
+// Returns:
+// - The found gauge metrics as [name, value], in the order of the names.
+// - The found counter metrics as [name, value], in the order of the names.
+// - An error if the retrieval operation fails.
+func (s *Storage) GetMetrics(ctx context.Context, gauges []string, counters []string) ([][]string, [][]string, error) {
+	const op = "storage.postgre.GetMetrics"
+
+	names := make([]string, 0, len(gauges)+len(counters))
+	names = append(append(names, gauges...), counters...)
+	if len(names) == 0 {
+		return [][]string{}, [][]string{}, nil
+	}
+
+	type row struct {
+		gauge   float64
+		counter int64
+	}
+	var found map[string]row
+	action := func(attempt uint) error {
+		found = make(map[string]row, len(names))
+
+		rows, err := s.db.QueryContext(ctx, `SELECT name, gauge, counter FROM metric WHERE name = ANY($1)`, names)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			var r row
+			if err = rows.Scan(&name, &r.gauge, &r.counter); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			found[name] = r
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+	err := retry.Retry(
+		action,
+		strategy.Limit(4),
+		strategy.Backoff(backoff.Backoff()),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	foundGauges := make([][]string, 0, len(gauges))
+	for _, name := range gauges {
+		if r, ok := found[name]; ok {
+			foundGauges = append(foundGauges, []string{name, strconv.FormatFloat(r.gauge, 'f', -1, 64)})
+		}
+	}
+	foundCounters := make([][]string, 0, len(counters))
+	for _, name := range counters {
+		if r, ok := found[name]; ok {
+			foundCounters = append(foundCounters, []string{name, strconv.FormatInt(r.counter, 10)})
+		}
+	}
+	return foundGauges, foundCounters, nil
+}
+
 // UpdateBatch saves the given Gauge and Counter metrics to the PostgreSQL database in a batch operation.
 // It retries the update operation up to 4 times with a backoff strategy in case of failure.
 //
@@ -343,7 +433,9 @@ func (s *Storage) GetMetric(ctx context.Context, typ string, key string) (string
 func (s *Storage) UpdateBatch(ctx context.Context, gauges [][]string, counters [][]string) error {
 	const op = "storage.postgre.UpdateBatch"
 
+	var changes []storage.Change
 	action := func(attempt uint) error {
+		changes = changes[:0]
 
 		tx, errTx := s.db.BeginTx(ctx, &sql.TxOptions{})
 		if errTx != nil {
@@ -358,12 +450,13 @@ func (s *Storage) UpdateBatch(ctx context.Context, gauges [][]string, counters [
 		for _, gauge := range gauges {
 			newVal, err := strconv.ParseFloat(gauge[1], 64)
 			if err != nil {
-				return fmt.Errorf("%s: %w", op, err)
+				return fmt.Errorf("%s: %w: %w", op, storage.ErrInvalidValue, err)
 			}
 			_, err = stmt.ExecContext(ctx, gauge[0], newVal, 0)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
+			changes = append(changes, storage.Change{Type: format.Gauge, Name: gauge[0], Value: strconv.FormatFloat(newVal, 'f', -1, 64)})
 		}
 		for _, counter := range counters {
 			stmtSelect, err := tx.PrepareContext(ctx, `SELECT name, counter FROM metric WHERE name=$1`)
@@ -381,13 +474,14 @@ func (s *Storage) UpdateBatch(ctx context.Context, gauges [][]string, counters [
 
 			newVal, err := strconv.ParseInt(counter[1], 0, 64)
 			if err != nil {
-				return fmt.Errorf("%s: %w", op, err)
+				return fmt.Errorf("%s: %w: %w", op, storage.ErrInvalidValue, err)
 			}
 			newVal = newVal + getCounter
 			_, err = stmt.ExecContext(ctx, counter[0], 0, newVal)
 			if err != nil {
 				return fmt.Errorf("%s: %w", op, err)
 			}
+			changes = append(changes, storage.Change{Type: format.Counter, Name: counter[0], Value: strconv.FormatInt(newVal, 10)})
 		}
 
 		errTx = tx.Commit()
@@ -404,7 +498,31 @@ func (s *Storage) UpdateBatch(ctx context.Context, gauges [][]string, counters [
 	)
 
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, classify(err))
 	}
+	s.notify(changes)
 	return nil
 }
+
+// notify passes the changes to the notifier, if there is one.
+func (s *Storage) notify(changes []storage.Change) {
+	if s.notifier != nil && len(changes) > 0 {
+		s.notifier.Notify(changes)
+	}
+}
+
+// classify marks an error of the database as caused by an invalid value when PostgreSQL reports
+// a data exception or an integrity constraint violation, and as storage unavailability otherwise.
+// Errors that are already classified are returned unchanged.
+func classify(err error) error {
+	if errors.Is(err, storage.ErrMetricNotFound) ||
+		errors.Is(err, storage.ErrInvalidValue) ||
+		errors.Is(err, storage.ErrStorageUnavailable) {
+		return err
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")) {
+		return fmt.Errorf("%w: %w", storage.ErrInvalidValue, err)
+	}
+	return fmt.Errorf("%w: %w", storage.ErrStorageUnavailable, err)
+}
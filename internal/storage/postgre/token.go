@@ -0,0 +1,167 @@
+package postgre
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mbiwapa/metric/internal/lib/apitoken"
+)
+
+// TokenStore keeps the API tokens in the api_token table, so that they are shared by
+// servers using the same database. It implements apitoken.Store.
+type TokenStore struct {
+	db *sql.DB
+}
+
+// NewTokenStore creates the api_token table if it does not exist and returns a store using it.
+//
+// Parameters:
+// - ctx: The context for creating the table.
+// - s: The storage whose database connection is used.
+//
+// Returns:
+// - A pointer to the TokenStore instance.
+// - An error if the table cannot be created.
+func NewTokenStore(ctx context.Context, s *Storage) (*TokenStore, error) {
+	const op = "storage.postgre.NewTokenStore"
+
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS api_token (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL DEFAULT '',
+        hash TEXT NOT NULL UNIQUE,
+        scopes TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now());`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return &TokenStore{db: s.db}, nil
+}
+
+// Lookup returns the token with the given hash.
+//
+// Parameters:
+// - ctx: The context for the query.
+// - hash: The hash of the token.
+//
+// Returns:
+// - The token.
+// - apitoken.ErrNotFound if there is no such token, or an error if the query fails.
+func (s *TokenStore) Lookup(ctx context.Context, hash string) (apitoken.Token, error) {
+	const op = "storage.postgre.TokenStore.Lookup"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, hash, scopes, created_at FROM api_token WHERE hash = $1`, hash)
+	if err != nil {
+		return apitoken.Token{}, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	tokens, err := scanTokens(rows)
+	if err != nil {
+		return apitoken.Token{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(tokens) == 0 {
+		return apitoken.Token{}, apitoken.ErrNotFound
+	}
+	return tokens[0], nil
+}
+
+// Add stores a new token.
+//
+// Parameters:
+// - ctx: The context for the query.
+// - token: The token, with its hash.
+//
+// Returns:
+// - An error if the query fails or the token already exists.
+func (s *TokenStore) Add(ctx context.Context, token apitoken.Token) error {
+	const op = "storage.postgre.TokenStore.Add"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO api_token (id, name, hash, scopes, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		token.ID, token.Name, token.Hash, joinScopes(token.Scopes), token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	return nil
+}
+
+// List returns the stored tokens, the oldest first.
+//
+// Parameters:
+// - ctx: The context for the query.
+//
+// Returns:
+// - The tokens.
+// - An error if the query fails.
+func (s *TokenStore) List(ctx context.Context) ([]apitoken.Token, error) {
+	const op = "storage.postgre.TokenStore.List"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, hash, scopes, created_at FROM api_token ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	tokens, err := scanTokens(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tokens, nil
+}
+
+// Revoke deletes the token with the given ID.
+//
+// Parameters:
+// - ctx: The context for the query.
+// - id: The ID of the token.
+//
+// Returns:
+// - apitoken.ErrNotFound if there is no such token, or an error if the query fails.
+func (s *TokenStore) Revoke(ctx context.Context, id string) error {
+	const op = "storage.postgre.TokenStore.Revoke"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM api_token WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+	if n == 0 {
+		return apitoken.ErrNotFound
+	}
+	return nil
+}
+
+// scanTokens reads the tokens of the rows and closes them.
+func scanTokens(rows *sql.Rows) ([]apitoken.Token, error) {
+	defer rows.Close()
+
+	var tokens []apitoken.Token
+	for rows.Next() {
+		var t apitoken.Token
+		var scopes string
+		if err := rows.Scan(&t.ID, &t.Name, &t.Hash, &scopes, &t.CreatedAt); err != nil {
+			return nil, classify(err)
+		}
+		for _, scope := range strings.Split(scopes, ",") {
+			t.Scopes = append(t.Scopes, apitoken.Scope(scope))
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classify(err)
+	}
+	return tokens, nil
+}
+
+// joinScopes returns the scopes as stored in the scopes column.
+func joinScopes(scopes []apitoken.Scope) string {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ",")
+}
@@ -10,4 +10,33 @@ var (
 	// ErrMetricNotFound is returned when a Metric is not found.
 	// This error is used to indicate that a requested metric does not exist in the storage.
 	ErrMetricNotFound = errors.New("metric not found")
+
+	// ErrInvalidValue is returned when a metric value passed to the storage cannot be parsed or stored.
+	ErrInvalidValue = errors.New("invalid metric value")
+
+	// ErrStorageUnavailable is returned when the storage backend cannot be reached or fails to serve a request.
+	ErrStorageUnavailable = errors.New("storage unavailable")
 )
+
+// Change describes a metric written to the storage.
+type Change struct {
+	Type  string // Type is the metric type, gauge or counter.
+	Name  string // Name is the name of the metric.
+	Value string // Value is the value after the write; for counters it is the accumulated total.
+}
+
+// Notifier receives the changes made by a storage after every successful write.
+// Notify is called synchronously on the write path and must not block.
+type Notifier interface {
+	Notify(changes []Change)
+}
+
+// Notifiers passes the changes to several notifiers in order.
+type Notifiers []Notifier
+
+// Notify implements Notifier.
+func (n Notifiers) Notify(changes []Change) {
+	for _, notifier := range n {
+		notifier.Notify(changes)
+	}
+}